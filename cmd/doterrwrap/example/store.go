@@ -0,0 +1,27 @@
+// Package example shows the decorator doterrwrap generates; see
+// store_doterr.go.
+package example
+
+import (
+	"context"
+	"io"
+)
+
+//go:generate go run .. -type Store
+
+// Store is a repository-style interface.
+type Store interface {
+	// Get returns the value of key.
+	//doterr:meta bucket, key
+	Get(ctx context.Context, bucket, key string) ([]byte, error)
+
+	// Put stores r under key.
+	//doterr:meta key
+	Put(ctx context.Context, key string, r io.Reader) error
+
+	// Delete removes keys; the op alone is recorded.
+	Delete(ctx context.Context, keys ...string) (int, error)
+
+	// Len is forwarded unchanged since it cannot fail.
+	Len() int
+}
@@ -0,0 +1,46 @@
+// Code generated by doterrwrap; DO NOT EDIT.
+
+package example
+
+import (
+	"context"
+	"io"
+
+	"github.com/mikeschinkel/go-doterr"
+)
+
+// StoreErrs wraps a Store so every error it returns carries the method as a
+// doterr.Op and the method's tagged arguments as metadata.
+type StoreErrs struct {
+	Next Store
+}
+
+var _ Store = StoreErrs{}
+
+func (w StoreErrs) Get(ctx context.Context, bucket string, key string) ([]byte, error) {
+	r0, err := w.Next.Get(ctx, bucket, key)
+	if err != nil {
+		err = doterr.WithErrSkip(1, err, doterr.Op("Store.Get"), "bucket", bucket, "key", key)
+	}
+	return r0, err
+}
+
+func (w StoreErrs) Put(ctx context.Context, key string, r io.Reader) error {
+	err := w.Next.Put(ctx, key, r)
+	if err != nil {
+		err = doterr.WithErrSkip(1, err, doterr.Op("Store.Put"), "key", key)
+	}
+	return err
+}
+
+func (w StoreErrs) Delete(ctx context.Context, keys ...string) (int, error) {
+	r0, err := w.Next.Delete(ctx, keys...)
+	if err != nil {
+		err = doterr.WithErrSkip(1, err, doterr.Op("Store.Delete"))
+	}
+	return r0, err
+}
+
+func (w StoreErrs) Len() int {
+	return w.Next.Len()
+}
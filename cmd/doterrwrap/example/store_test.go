@@ -0,0 +1,43 @@
+package example
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/mikeschinkel/go-doterr"
+)
+
+var ErrMissing = errors.New("missing")
+
+type failingStore struct{}
+
+func (failingStore) Get(context.Context, string, string) ([]byte, error) { return nil, ErrMissing }
+func (failingStore) Put(context.Context, string, io.Reader) error        { return nil }
+func (failingStore) Delete(context.Context, ...string) (int, error)      { return 0, ErrMissing }
+func (failingStore) Len() int                                            { return 3 }
+
+func TestStoreErrs(t *testing.T) {
+	s := StoreErrs{Next: failingStore{}}
+
+	_, err := s.Get(context.Background(), "users", "alice")
+	if !errors.Is(err, ErrMissing) {
+		t.Fatalf("expected the original error to be kept: %v", err)
+	}
+	if got := doterr.ErrOpTrace(err); got != "Store.Get" {
+		t.Errorf("unexpected op trace %q", got)
+	}
+	if key, _ := doterr.ErrValue[string](err, "key"); key != "alice" {
+		t.Errorf("expected tagged key metadata, got %q", key)
+	}
+	if err := s.Put(context.Background(), "k", nil); err != nil {
+		t.Errorf("expected nil to pass through, got %v", err)
+	}
+	if _, err := s.Delete(context.Background(), "a", "b"); doterr.ErrMeta(err)[0].Key() != doterr.OpKey {
+		t.Errorf("expected only the op on untagged methods: %v", doterr.ErrMeta(err))
+	}
+	if s.Len() != 3 {
+		t.Error("expected Len to be forwarded")
+	}
+}
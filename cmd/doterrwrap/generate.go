@@ -0,0 +1,345 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/mikeschinkel/go-doterr"
+)
+
+const defaultDoterrPath = "github.com/mikeschinkel/go-doterr"
+
+// metaDirective tags the parameters a method records as metadata.
+const metaDirective = "//doterr:meta"
+
+var (
+	ErrInterfaceNotFound = errors.New("interface not found")
+	ErrUnsupported       = errors.New("unsupported interface")
+	ErrUnknownParam      = errors.New("tagged parameter not found")
+)
+
+// generate reads the package in dir and returns the formatted source of the
+// decorator for the interface typeName.
+func generate(dir, typeName, doterrPath string) ([]byte, error) {
+	fset := token.NewFileSet()
+	files, err := parseDir(fset, dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range files {
+		iface := findInterface(f, typeName)
+		if iface == nil {
+			continue
+		}
+		g := &generator{fset: fset, file: f, typeName: typeName, doterrPath: doterrPath}
+		return g.run(iface)
+	}
+	return nil, doterr.NewErr(ErrInterfaceNotFound, "type", typeName, "dir", dir)
+}
+
+// parseDir parses the non-test, non-generated Go files of dir.
+func parseDir(fset *token.FileSet, dir string) ([]*ast.File, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var files []*ast.File
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		f, err := parser.ParseFile(fset, filepath.Join(dir, name), nil, parser.ParseComments)
+		if err != nil {
+			return nil, err
+		}
+		if ast.IsGenerated(f) {
+			continue
+		}
+		files = append(files, f)
+	}
+	return files, nil
+}
+
+func findInterface(f *ast.File, name string) *ast.InterfaceType {
+	for _, decl := range f.Decls {
+		gd, ok := decl.(*ast.GenDecl)
+		if !ok || gd.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gd.Specs {
+			ts := spec.(*ast.TypeSpec)
+			if ts.Name.Name != name {
+				continue
+			}
+			if it, ok := ts.Type.(*ast.InterfaceType); ok {
+				return it
+			}
+		}
+	}
+	return nil
+}
+
+type generator struct {
+	fset       *token.FileSet
+	file       *ast.File
+	typeName   string
+	doterrPath string
+	body       bytes.Buffer
+	pkgs       []string // import names used by the method signatures
+}
+
+func (g *generator) run(iface *ast.InterfaceType) ([]byte, error) {
+	wrapper := g.typeName + "Errs"
+	fmt.Fprintf(&g.body, "// %s wraps a %s so every error it returns carries the method as a\n", wrapper, g.typeName)
+	fmt.Fprintf(&g.body, "// doterr.Op and the method's tagged arguments as metadata.\n")
+	fmt.Fprintf(&g.body, "type %s struct {\n\tNext %s\n}\n\n", wrapper, g.typeName)
+	fmt.Fprintf(&g.body, "var _ %s = %s{}\n", g.typeName, wrapper)
+
+	for _, m := range iface.Methods.List {
+		ft, ok := m.Type.(*ast.FuncType)
+		if !ok || len(m.Names) == 0 {
+			return nil, doterr.NewErr(ErrUnsupported,
+				"type", g.typeName,
+				"reason", "embedded interfaces are not supported",
+				"pos", g.fset.Position(m.Pos()).String(),
+			)
+		}
+		for _, name := range m.Names {
+			err := g.method(name.Name, ft, m.Doc)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return g.source()
+}
+
+func (g *generator) method(name string, ft *ast.FuncType, doc *ast.CommentGroup) error {
+	var params, args []string
+	// The receiver, the error result and the doterr qualifier are taken;
+	// parameters with those names are renamed in the generated method.
+	reserved := map[string]bool{"w": true, "err": true}
+	if g.doterrPath != "" {
+		reserved["doterr"] = true
+	}
+	used := maps.Clone(reserved)
+	for _, p := range fieldList(ft.Params) {
+		for _, n := range p.names {
+			used[n] = true
+		}
+	}
+	i := 0
+	var names []string
+	vars := map[string]string{} // parameter name → generated variable
+	for _, p := range fieldList(ft.Params) {
+		typ := g.expr(p.typ)
+		for _, n := range p.names {
+			v := n
+			switch {
+			case n == "" || n == "_":
+				v = fresh("p"+strconv.Itoa(i), used)
+			case reserved[n]:
+				v = fresh(n, used)
+			}
+			i++
+			names = append(names, n)
+			vars[n] = v
+			params = append(params, v+" "+typ)
+			arg := v
+			if _, ok := p.typ.(*ast.Ellipsis); ok {
+				arg += "..."
+			}
+			args = append(args, arg)
+		}
+	}
+
+	results := fieldList(ft.Results)
+	var resultTypes []string
+	for _, r := range results {
+		for range r.names {
+			resultTypes = append(resultTypes, g.expr(r.typ))
+		}
+	}
+	call := fmt.Sprintf("w.Next.%s(%s)", name, strings.Join(args, ", "))
+	sig := fmt.Sprintf("func (w %sErrs) %s(%s)", g.typeName, name, strings.Join(params, ", "))
+	switch len(resultTypes) {
+	case 0:
+	case 1:
+		sig += " " + resultTypes[0]
+	default:
+		sig += " (" + strings.Join(resultTypes, ", ") + ")"
+	}
+	fmt.Fprintf(&g.body, "\n%s {\n", sig)
+
+	if len(resultTypes) == 0 || resultTypes[len(resultTypes)-1] != "error" {
+		if len(resultTypes) == 0 {
+			fmt.Fprintf(&g.body, "\t%s\n}\n", call)
+		} else {
+			fmt.Fprintf(&g.body, "\treturn %s\n}\n", call)
+		}
+		return nil
+	}
+
+	tagged, err := g.tagged(name, doc, names)
+	if err != nil {
+		return err
+	}
+	outs := make([]string, len(resultTypes))
+	for i := range outs[:len(outs)-1] {
+		outs[i] = fresh("r"+strconv.Itoa(i), used)
+	}
+	outs[len(outs)-1] = "err"
+	meta := []string{"1", "err", fmt.Sprintf("%s(%q)", g.qualify("Op"), g.typeName+"."+name)}
+	for _, n := range tagged {
+		meta = append(meta, strconv.Quote(n), vars[n])
+	}
+	fmt.Fprintf(&g.body, "\t%s := %s\n", strings.Join(outs, ", "), call)
+	fmt.Fprintf(&g.body, "\tif err != nil {\n\t\terr = %s(%s)\n\t}\n", g.qualify("WithErrSkip"), strings.Join(meta, ", "))
+	fmt.Fprintf(&g.body, "\treturn %s\n}\n", strings.Join(outs, ", "))
+	return nil
+}
+
+// tagged returns the parameters listed by the method's //doterr:meta lines.
+func (g *generator) tagged(method string, doc *ast.CommentGroup, params []string) ([]string, error) {
+	if doc == nil {
+		return nil, nil
+	}
+	var out []string
+	for _, c := range doc.List {
+		rest, ok := strings.CutPrefix(c.Text, metaDirective)
+		if !ok {
+			continue
+		}
+		for _, n := range strings.FieldsFunc(rest, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' }) {
+			if !slices.Contains(params, n) {
+				return nil, doterr.NewErr(ErrUnknownParam,
+					"method", g.typeName+"."+method,
+					"param", n,
+					"pos", g.fset.Position(c.Pos()).String(),
+				)
+			}
+			out = append(out, n)
+		}
+	}
+	return out, nil
+}
+
+// source assembles the file: header, imports used and the generated code.
+func (g *generator) source() ([]byte, error) {
+	var src bytes.Buffer
+	src.WriteString("// Code generated by doterrwrap; DO NOT EDIT.\n\n")
+	fmt.Fprintf(&src, "package %s\n\n", g.file.Name.Name)
+	var imports []string
+	for _, imp := range g.file.Imports {
+		path, _ := strconv.Unquote(imp.Path.Value)
+		name := importName(path)
+		if imp.Name != nil {
+			name = imp.Name.Name
+		}
+		if slices.Contains(g.pkgs, name) {
+			if imp.Name != nil {
+				imports = append(imports, imp.Name.Name+" "+imp.Path.Value)
+			} else {
+				imports = append(imports, imp.Path.Value)
+			}
+		}
+	}
+	if g.doterrPath != "" {
+		if len(imports) > 0 {
+			imports = append(imports, "")
+		}
+		imports = append(imports, strconv.Quote(g.doterrPath))
+	}
+	if len(imports) > 0 {
+		src.WriteString("import (\n")
+		for _, imp := range imports {
+			if imp == "" {
+				src.WriteString("\n")
+				continue
+			}
+			src.WriteString("\t" + imp + "\n")
+		}
+		src.WriteString(")\n\n")
+	}
+	src.Write(g.body.Bytes())
+	return format.Source(src.Bytes())
+}
+
+// qualify returns the doterr identifier name as referenced from the file.
+func (g *generator) qualify(name string) string {
+	if g.doterrPath == "" {
+		return name
+	}
+	return "doterr." + name
+}
+
+// expr renders a type expression, noting the packages it references.
+func (g *generator) expr(e ast.Expr) string {
+	ast.Inspect(e, func(n ast.Node) bool {
+		sel, ok := n.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		if id, ok := sel.X.(*ast.Ident); ok && !slices.Contains(g.pkgs, id.Name) {
+			g.pkgs = append(g.pkgs, id.Name)
+		}
+		return false
+	})
+	var buf bytes.Buffer
+	_ = format.Node(&buf, g.fset, e)
+	return buf.String()
+}
+
+type field struct {
+	names []string // a single "" for an unnamed field
+	typ   ast.Expr
+}
+
+func fieldList(fl *ast.FieldList) []field {
+	if fl == nil {
+		return nil
+	}
+	out := make([]field, 0, len(fl.List))
+	for _, f := range fl.List {
+		names := []string{""}
+		if len(f.Names) > 0 {
+			names = names[:0]
+			for _, n := range f.Names {
+				names = append(names, n.Name)
+			}
+		}
+		out = append(out, field{names: names, typ: f.Type})
+	}
+	return out
+}
+
+// importName guesses the package name of path: its last element, skipping a
+// major-version suffix such as "/v2".
+func importName(path string) string {
+	name := filepath.Base(path)
+	if len(name) > 1 && name[0] == 'v' && strings.Trim(name[1:], "0123456789") == "" {
+		name = filepath.Base(filepath.Dir(path))
+	}
+	return name
+}
+
+// fresh returns name, or name with underscores appended, unused so far.
+func fresh(name string, used map[string]bool) string {
+	for used[name] {
+		name += "_"
+	}
+	used[name] = true
+	return name
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"errors"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGenerate_MatchesExample(t *testing.T) {
+	got, err := generate("example", "Store", defaultDoterrPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := os.ReadFile(filepath.Join("example", "store_doterr.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(want) {
+		t.Errorf("example/store_doterr.go is stale; run go generate ./cmd/doterrwrap/example\ngot:\n%s", got)
+	}
+}
+
+func TestGenerate_Errors(t *testing.T) {
+	dir := t.TempDir()
+	src := "package p\n\ntype I interface {\n\t//doterr:meta nope\n\tF(id int) error\n}\n"
+	if err := os.WriteFile(filepath.Join(dir, "p.go"), []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := generate(dir, "I", ""); !errors.Is(err, ErrUnknownParam) {
+		t.Errorf("expected ErrUnknownParam, got %v", err)
+	}
+	if _, err := generate(dir, "Missing", ""); !errors.Is(err, ErrInterfaceNotFound) {
+		t.Errorf("expected ErrInterfaceNotFound, got %v", err)
+	}
+}
+
+func TestGenerate_Compiles(t *testing.T) {
+	dir := t.TempDir()
+	src := `package p
+
+import "context"
+
+type I interface {
+	//doterr:meta w, err, doterr
+	F(ctx context.Context, w string, err error, doterr int, _ bool, p4 int) (int, error)
+	G(r0 string) (string, int, error)
+	H(w ...string)
+}
+`
+	if err := os.WriteFile(filepath.Join(dir, "p.go"), []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := generate(dir, "I", defaultDoterrPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(got), `"w", w_, "err", err_, "doterr", doterr_`) {
+		t.Errorf("expected colliding parameters renamed:\n%s", got)
+	}
+
+	fset := token.NewFileSet()
+	var files []*ast.File
+	for name, text := range map[string]string{"p.go": src, "i_doterr.go": string(got)} {
+		f, err := parser.ParseFile(fset, name, text, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, f)
+	}
+	conf := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	if _, err := conf.Check("p", fset, files, nil); err != nil {
+		t.Errorf("generated code does not compile: %v\n%s", err, got)
+	}
+}
@@ -0,0 +1,74 @@
+// Command doterrwrap generates doterr decorators for interfaces. Given an
+// interface, it emits a struct implementing it by calling Next, where every
+// error returned is wrapped with the method as a doterr.Op and the method's
+// tagged arguments as metadata:
+//
+//	//go:generate go run github.com/mikeschinkel/go-doterr/cmd/doterrwrap -type Repo
+//
+//	type Repo interface {
+//	  //doterr:meta id
+//	  Find(ctx context.Context, id string) (*User, error)
+//	}
+//
+// generates, in repo_doterr.go,
+//
+//	type RepoErrs struct{ Next Repo }
+//
+//	func (w RepoErrs) Find(ctx context.Context, id string) (*User, error) {
+//	  r0, err := w.Next.Find(ctx, id)
+//	  if err != nil {
+//	    err = doterr.WithErrSkip(1, err, doterr.Op("Repo.Find"), "id", id)
+//	  }
+//	  return r0, err
+//	}
+//
+// A "//doterr:meta" line in a method's doc comment lists, separated by
+// commas or spaces, the parameters recorded as metadata; untagged methods
+// record only the Op. With caller capture enabled (doterr.SetCaptureCaller)
+// the call site recorded is the decorator's caller. Methods whose last
+// result is not an error are forwarded unchanged. Interfaces embedding other
+// interfaces are not supported.
+//
+// Flags:
+//
+//	-type    interface to wrap (required)
+//	-dir     package directory to read (default ".")
+//	-out     output file (default "<type>_doterr.go", lowercased)
+//	-doterr  import path of doterr, or "" when doterr.go is embedded in the
+//	         package itself (default "github.com/mikeschinkel/go-doterr")
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func main() {
+	typeName := flag.String("type", "", "interface to wrap")
+	dir := flag.String("dir", ".", "package directory to read")
+	out := flag.String("out", "", "output file")
+	doterrPath := flag.String("doterr", defaultDoterrPath, `doterr import path, or "" if embedded`)
+	flag.Parse()
+
+	if *typeName == "" {
+		fmt.Fprintln(os.Stderr, "doterrwrap: -type is required")
+		flag.Usage()
+		os.Exit(2)
+	}
+	src, err := generate(*dir, *typeName, *doterrPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "doterrwrap:", err)
+		os.Exit(1)
+	}
+	if *out == "" {
+		*out = filepath.Join(*dir, strings.ToLower(*typeName)+"_doterr.go")
+	}
+	err = os.WriteFile(*out, src, 0o644)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "doterrwrap:", err)
+		os.Exit(1)
+	}
+}
@@ -40,10 +40,24 @@
 package doterr
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"hash"
+	"hash/fnv"
+	"iter"
+	"maps"
 	"math/rand"
+	"os"
+	"reflect"
+	"runtime"
+	"runtime/debug"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // KV represents a key/value metadata pair. Keys are preserved in
@@ -53,6 +67,33 @@ type KV interface {
 	Value() any
 }
 
+// Pair is the KV entries store their metadata as. It is exported so an
+// Allocator can supply storage for it; build metadata from "key", value
+// parts or KVs such as Severity instead.
+type Pair struct {
+	k string
+	v any
+}
+
+func (p Pair) Key() string { return p.k }
+func (p Pair) Value() any  { return p.v }
+
+// ErrNode is the protocol every copy of doterr.go implements on its entries,
+// so that copies embedded in different packages of one binary can read each
+// other's sentinels and metadata. It uses only predeclared types because each
+// copy has its own KV type. Accessors such as ErrMeta(), Errors() and
+// ErrSentinels() accept any ErrNode, not just this copy's entries.
+type ErrNode interface {
+	error
+	// DoterrID identifies the doterr copy that created the node.
+	DoterrID() int
+	// DoterrSentinels returns a copy of the node's sentinels.
+	DoterrSentinels() []error
+	// DoterrMeta returns the node's metadata as parallel key/value slices,
+	// in insertion order.
+	DoterrMeta() (keys []string, values []any)
+}
+
 // Sentinel errors for validation failures
 var (
 	ErrMissingSentinel     = errors.New("missing required sentinel error")
@@ -62,8 +103,61 @@ var (
 	ErrOddKeyValueCount    = errors.New("odd number of key-value arguments")
 	ErrCrossPackageError   = errors.New("error from different doterr package")
 	ErrFailedTypeAssertion = errors.New("failed type assertion")
+	ErrAmbiguousErrors     = errors.New("ambiguous error arguments")
+)
+
+// ErrRetryable classifies an error as transient; include it alongside the
+// entry's other sentinels and check it with IsRetryable().
+var ErrRetryable = errors.New("retryable")
+
+// Canonical sentinels for overload conditions. Transports map these to
+// protocol-level signals, e.g. HTTP 429/503 with a Retry-After header.
+var (
+	ErrRateLimited = errors.New("rate limited")
+	ErrUnavailable = errors.New("unavailable")
+)
+
+// RetryAfterKey is the canonical metadata key for how long a caller should
+// wait before retrying. Its value is always a time.Duration.
+const RetryAfterKey = "retry_after"
+
+// Severity classifies how serious an error is. A Severity is itself a KV
+// whose key is SeverityKey, so it can be passed directly to NewErr/WithErr:
+//
+//	return NewErr(ErrCache, SeverityWarn, "key", k, err)
+type Severity int
+
+const (
+	SeverityUnset Severity = iota
+	SeverityDebug
+	SeverityInfo
+	SeverityWarn
+	SeverityError
+	SeverityCritical
 )
 
+// SeverityKey is the metadata key under which a Severity is stored.
+const SeverityKey = "severity"
+
+func (s Severity) Key() string { return SeverityKey }
+func (s Severity) Value() any  { return s }
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityDebug:
+		return "debug"
+	case SeverityInfo:
+		return "info"
+	case SeverityWarn:
+		return "warn"
+	case SeverityError:
+		return "error"
+	case SeverityCritical:
+		return "critical"
+	}
+	return "unset"
+}
+
 // NewErr builds a standalone structured entry (no primary cause inside).
 // Accepted parts:
 //   - error         — sentinel/tag (required: at least one, must be first)
@@ -71,35 +165,72 @@ var (
 //   - "key", value  — implicit pair (value can be any type, including error)
 //   - error         — optional trailing cause (joined last via errors.Join)
 //
+// A nil trailing cause is treated as absent, including a typed nil (a nil
+// pointer stored in an error interface, as returned by interface-returning
+// functions), so NewErr(ErrX, "k", v, err) never yields a node with a nil
+// cause. Typed-nil sentinels are dropped the same way.
+//
 // Pattern: one or more sentinels (error), then zero or more key-value pairs,
 // then optional trailing cause (error). After the first string key, all
 // remaining args must form valid pairs, except for an optional final error.
 // Returns nil if no meaningful parts are provided after validation.
 // Returns a validation error joined with the partial entry if validation fails.
 func NewErr(parts ...any) error {
+	return newErr(nil, 1, parts)
+}
+
+// NewErrSkip is NewErr for thin helpers wrapping doterr: when caller capture
+// is enabled (see SetCaptureCaller) it records the call site skip frames above
+// its own caller, so NewErrSkip(1, ...) inside a helper reports the helper's
+// caller, analogous to testing.T.Helper.
+func NewErrSkip(skip int, parts ...any) error {
+	return newErr(nil, skip+1, parts)
+}
+
+// RawErr builds an entry from parts exactly as given, for decoders restoring
+// an error built in another process: unlike NewErr it records no caller or
+// time, runs no Enricher and does not treat a trailing error as a cause, so
+// every error in parts is held by the entry. Parts need not include a
+// sentinel. Returns nil if they hold nothing.
+func RawErr(parts ...any) error {
+	return buildEntry(parts...)
+}
+
+// newErr implements NewErr; skip counts frames above newErr's caller, so 1
+// means the caller of the exported function. ctx, which may be nil, is given
+// to the Enricher.
+func newErr(ctx context.Context, skip int, parts []any) error {
+	return newErrIn(nil, ctx, skip+1, parts)
+}
+
+// newErrIn is newErr allocating the entry's storage from alloc, which may be
+// nil.
+func newErrIn(alloc Allocator, ctx context.Context, skip int, parts []any) error {
 	// Separate optional trailing cause from the parts
 	cause, coreParts := extractTrailingCause(parts)
+	cause = summarizeCause(cause)
 
 	if validationErr := validateNewParts(coreParts); validationErr != nil {
 		// Return validation error joined as first error
-		var e entry
-		e.id = uniqueId
+		e := allocEntry(alloc, coreParts)
 		appendEntry(&e, coreParts...)
 		if e.empty() {
 			return validationErr
 		}
+		addOrigin(&e, originAt(ctx, skip+1))
 		return errors.Join(validationErr, e)
 	}
 
-	var e entry
-	e.id = uniqueId
+	e := allocEntry(alloc, coreParts)
 	appendEntry(&e, coreParts...)
 	if e.empty() {
 		return cause // if we only had a cause, return it
 	}
+	addOrigin(&e, originAt(ctx, skip+1))
 
 	// Join entry with optional cause (cause last)
 	if cause != nil {
+		e.cause = cause
 		return errors.Join(e, cause)
 	}
 	return e
@@ -129,21 +260,58 @@ func NewErr(parts ...any) error {
 //     otherwise, a fresh entry is created. If there is a trailing CAUSE from step 2,
 //     the result is errors.Join(entry, cause). If there is no cause, the entry is returned.
 //
+// A typed-nil base or cause (a nil pointer stored in an error interface) is
+// treated as absent, exactly like an untyped nil.
+//
+// Metadata merging: when step 1 enriches an existing entry, keys that entry
+// already holds are resolved by the package's MergePolicy (see
+// SetMergePolicy). The default, MergeLayer, keeps both values with the
+// original first, so ErrValue() keeps returning the original. Metadata on the
+// CAUSE is never merged; it stays on the cause's own entries.
+//
+// When every argument is an error, WithErr(a, b) means "b is the cause of a".
+// WithCause and WithBase state the intent explicitly, and SetStrictWithErr
+// rejects the all-errors form altogether.
+//
 // Note: For inter-function composition, prefer New() with trailing cause:
 //
 //	return doterr.New(ErrRepo, "key", val, cause) // cause last
 func WithErr(parts ...any) error {
+	return withErrParts(nil, 1, parts)
+}
+
+// WithErrSkip is WithErr for thin helpers wrapping doterr; skip works as for
+// NewErrSkip.
+func WithErrSkip(skip int, parts ...any) error {
+	return withErrParts(nil, skip+1, parts)
+}
+
+// withErrParts implements WithErr; skip and ctx work as for newErr.
+func withErrParts(ctx context.Context, skip int, parts []any) error {
 	if len(parts) == 0 {
 		return nil
 	}
 
+	if strictWithErr.Load() && ambiguousErrs(parts) {
+		// Build manually: the usual constructors would recurse into WithErr rules.
+		v := newEntry([]error{ErrAmbiguousErrors}, []Pair{
+			{k: "count", v: len(parts)},
+			{k: "message", v: "use WithCause or WithBase when all arguments are errors"},
+		})
+		errs := []error{v}
+		for _, part := range parts {
+			errs = append(errs, part.(error))
+		}
+		return errors.Join(errs...)
+	}
+
 	i, j := 0, len(parts)-1
 
 	// Optional base (first arg)
 	var baseErr error
 	firstErr, ok := parts[i].(error)
 	if ok {
-		baseErr = checkCrossPackage(firstErr)
+		baseErr = firstErr
 		i++
 	}
 
@@ -152,267 +320,2541 @@ func WithErr(parts ...any) error {
 	if j >= i {
 		lastErr, ok := parts[j].(error)
 		if ok {
-			cause = checkCrossPackage(lastErr)
+			cause = lastErr
 			j--
 		}
 	}
 
 	// Middle segment are the metadata/sentinels to apply.
-	middle := parts[i : j+1]
+	return withErr(baseErr, parts[i:j+1], cause, originAt(ctx, skip+1))
+}
 
-	// No base error: build entry from middle, then (if present) join cause LAST.
-	if baseErr == nil {
-		return handleCause(buildEntry(middle...), cause)
-	}
+// WithCause is the unambiguous form of WithErr(base, parts..., cause): it
+// enriches base with parts and joins cause LAST. Either error may be nil.
+func WithCause(base, cause error, parts ...any) error {
+	return withErr(base, parts, cause, originAt(nil, 1))
+}
 
-	// Have a base error: try to enrich rightmost entry or join a fresh entry.
-	err := buildErr(baseErr, middle)
+// WithBase is the unambiguous form of WithErr(base, parts...) with no cause:
+// every error in parts is merged into base as a sentinel, including a final
+// one that WithErr would have treated as the cause.
+func WithBase(base error, parts ...any) error {
+	return withErr(base, parts, nil, originAt(nil, 1))
+}
 
-	// Now handle the cause
-	return handleCause(err, cause)
+var strictWithErr atomic.Bool
 
+// SetStrictWithErr controls how WithErr treats calls whose arguments are all
+// errors, such as WithErr(a, b): by default b is the cause of a, but it is
+// easy to mean "add sentinel b to a" instead. In strict mode such calls
+// return an ErrAmbiguousErrors validation error joined with the arguments,
+// steering callers to WithCause or WithBase. Returns the previous setting.
+func SetStrictWithErr(strict bool) bool {
+	return strictWithErr.Swap(strict)
 }
 
-// CombineErrs bundles a slice of errors into a single composite error that unwraps
-// to its members. Order is preserved and nils are skipped. Returns nil for an
-// empty/fully-nil slice, or the sole error when there is exactly one.
-func CombineErrs(errs []error) error {
-	filtered := make([]error, 0, len(errs))
-	for _, e := range errs {
-		if e != nil {
-			filtered = append(filtered, e)
-		}
-	}
-	switch len(filtered) {
-	case 0:
-		return nil
-	case 1:
-		return filtered[0]
-	default:
-		return combined{errs: filtered}
-	}
+// CallerKey holds the "dir/file.go:line" call site recorded on new entries
+// while caller capture is enabled.
+const CallerKey = "caller"
+
+var captureCaller atomic.Bool
+
+// SetCaptureCaller enables recording the call site of NewErr, WithErr and the
+// other constructors under CallerKey on each entry they create. It is off by
+// default because runtime.Caller is comparatively expensive. Helpers that wrap
+// doterr should use NewErrSkip/WithErrSkip so the site reported is their
+// caller's. Returns the previous setting.
+func SetCaptureCaller(on bool) bool {
+	return captureCaller.Swap(on)
 }
 
-// ErrMeta returns the key/value pairs stored on a doterr entry.
-// If err is a doterr entry, returns its metadata.
-// If err is a joined error (has Unwrap() []error), scans immediate children
-// left-to-right and returns metadata from the first doterr entry found.
-// Otherwise returns nil.
-// The returned slice preserves insertion order and is a copy.
-func ErrMeta(err error) []KV {
-	var ok bool
-	var e, ce entry
-	var cePtr *entry
+// TimeKey holds the time.Time at which an entry was created while time
+// capture is enabled.
+const TimeKey = "time"
 
-	// Case (a): err is an entry → return its metadata
-	//goland:noinspection GoTypeAssertionOnErrors,DuplicatedCode
-	e, ok = err.(entry)
-	if ok {
-		out := make([]KV, len(e.kvs))
-		for i, pair := range e.kvs {
-			out[i] = pair
-		}
-		return out
-	}
+var captureTime atomic.Bool
 
-	// Case (b): err is a join (multi-unwrap) → scan immediate children left-to-right
-	type unwrapper interface{ Unwrap() []error }
-	u, ok := err.(unwrapper)
-	if !ok {
-		return nil
-	}
-	children := u.Unwrap()
-	for _, child := range children {
-		//goland:noinspection GoTypeAssertionOnErrors
-		ce, ok = child.(entry)
-		if !ok {
-			//goland:noinspection GoTypeAssertionOnErrors
-			cePtr, ok = child.(*entry)
-			if ok {
-				ce = *cePtr
-			}
-		}
-		if ok {
-			out := make([]KV, len(ce.kvs))
-			for i, pair := range ce.kvs {
-				out[i] = pair
-			}
-			return out
-		}
-	}
+// SetCaptureTime enables recording the creation time of each entry made by
+// NewErr, WithErr and the other constructors under TimeKey, so the chain can
+// be read as a timeline of when each layer failed. It is off by default.
+// Returns the previous setting.
+func SetCaptureTime(on bool) bool {
+	return captureTime.Swap(on)
+}
 
-	return nil
+// DiagLevel is a diagnostic level for verbose metadata (see Diag). Higher
+// levels are more verbose.
+type DiagLevel int32
+
+const (
+	DiagOff   DiagLevel = iota // no diagnostic metadata is retained (the default)
+	DiagDebug                  // retain Debug metadata
+	DiagTrace                  // retain Debug and Trace metadata
+)
+
+var diagLevel atomic.Int32
+
+// SetDiagLevel sets the diagnostic level at runtime, e.g. from an operator
+// endpoint, and returns the previous one.
+func SetDiagLevel(level DiagLevel) DiagLevel {
+	return DiagLevel(diagLevel.Swap(int32(level)))
 }
 
-// ErrValue extracts a single metadata value by key with type safety.
-// Returns the value and true if found and the value is of type T.
-// Returns the zero value of T and false if not found or type mismatch.
-//
-// Example:
+// DiagEnabled reports whether metadata at level is currently retained, to
+// guard computing an expensive value:
 //
-//	status, ok := doterr.ErrValue[int](err, "http_status")
-//	if ok {
-//	    fmt.Printf("Status: %d\n", status)
+//	if doterr.DiagEnabled(doterr.DiagDebug) {
+//	    parts = append(parts, doterr.Debug("query_plan", explain(q)))
 //	}
-//
-//	name, ok := doterr.ErrValue[string](err, "parameter_name")
-func ErrValue[T any](err error, key string) (T, bool) {
-	var zero T
-	kvs := ErrMeta(err)
-	if kvs == nil {
-		return zero, false
-	}
+func DiagEnabled(level DiagLevel) bool {
+	return level <= DiagLevel(diagLevel.Load())
+}
 
-	for _, pair := range kvs {
-		if pair.Key() == key {
-			if val, ok := pair.Value().(T); ok {
-				return val, true
-			}
-			return zero, false
-		}
-	}
-	return zero, false
+// Diag returns a KV for key and value that constructors keep only while the
+// diagnostic level is at least level (see SetDiagLevel), so verbose context
+// can ship in the code but stay dormant until an operator turns it on.
+// Metadata dropped at creation does not reappear when the level is raised.
+func Diag(level DiagLevel, key string, value any) KV {
+	return diagKV{level: level, Pair: Pair{k: key, v: value}}
 }
 
-// Errors returns the errors stored on a doterr entry.
-// If err is a doterr entry, returns its errors.
-// If err is a joined error (has Unwrap() []error), scans immediate children
-// left-to-right and returns errors from the first doterr entry found.
-// Otherwise returns nil.
-// The returned slice preserves insertion order and is a copy.
-//
-// Note: These errors may be sentinel errors (e.g., ErrRepo), custom error types
-// (e.g., *rfc9457.Error), or any other error type stored in the entry.
-func Errors(err error) []error {
-	// Case (a): err is an entry → return its errors
-	//goland:noinspection GoTypeAssertionOnErrors
-	e, ok := err.(entry)
-	if ok {
-		out := make([]error, len(e.errors))
-		copy(out, e.errors)
-		return out
-	}
+// Debug is Diag(DiagDebug, key, value).
+func Debug(key string, value any) KV { return Diag(DiagDebug, key, value) }
 
-	// Case (b): err is a join (multi-unwrap) → scan immediate children left-to-right
-	type unwrapper interface{ Unwrap() []error }
-	u, ok := err.(unwrapper)
-	if !ok {
-		return nil
+// Trace is Diag(DiagTrace, key, value).
+func Trace(key string, value any) KV { return Diag(DiagTrace, key, value) }
+
+// Metadata keys of the entry that stands in for a cause summarized under
+// SetCauseSizeLimit.
+const (
+	CauseTypeKey = "cause_type" // Go type of the dropped cause
+	CauseHashKey = "cause_hash" // FNV-64a of its message, in hex
+	CauseCodeKey = "cause_code" // its registered code, if any
+	CauseSizeKey = "cause_size" // length of its message in bytes
+)
+
+var causeSizeLimit atomic.Int64
+
+// SetCauseSizeLimit makes NewErr, WithErr and the other constructors
+// summarize the text of a trailing cause whose message is longer than n
+// bytes, so large causes do not flood logs and messages. The cause renders
+// as an entry holding its doterr sentinels and CauseTypeKey, CauseHashKey,
+// CauseCodeKey and CauseSizeKey, which also comes first when unwrapping; the
+// cause itself stays reachable, so errors.Is and errors.As keep matching it
+// and anything it wraps. The message is measured when the error is first
+// rendered or unwrapped, not at construction. n <= 0, the default, disables
+// summarizing. Returns the previous limit.
+func SetCauseSizeLimit(n int) int {
+	return int(causeSizeLimit.Swap(int64(max(n, 0))))
+}
+
+// Enricher injects metadata into every entry doterr creates, so platform
+// teams can enforce tagging policy (region, shard, tenant ID) in one place
+// instead of in every service. Enrich returns alternating string keys and
+// values; keys the entry already has are left alone, so call sites can
+// override the policy. It runs on every creation, so it must be cheap and
+// safe for concurrent use. ctx is the one given to NewErrCtx or WithErrCtx,
+// or context.Background() for the other constructors. A panic or a malformed
+// pair is reported as an ErrHookFailed error (see SetHookErrHandler).
+type Enricher interface {
+	Enrich(ctx context.Context) []any
+}
+
+// EnricherFunc adapts a function to an Enricher.
+type EnricherFunc func(ctx context.Context) []any
+
+func (f EnricherFunc) Enrich(ctx context.Context) []any { return f(ctx) }
+
+var enricher atomic.Pointer[Enricher]
+
+// SetEnricher installs e as the Enricher consulted by every constructor and
+// returns the previous one. Passing nil removes it. Compose several policies
+// inside one Enricher.
+func SetEnricher(e Enricher) Enricher {
+	var next *Enricher
+	if e != nil {
+		next = &e
 	}
-	children := u.Unwrap()
-	for _, child := range children {
-		//goland:noinspection GoTypeAssertionOnErrors
-		if ce, ok := child.(entry); ok {
-			out := make([]error, len(ce.errors))
-			copy(out, ce.errors)
-			return out
-		}
+	prev := enricher.Swap(next)
+	if prev == nil {
+		return nil
 	}
+	return *prev
+}
 
-	return nil
+// NewErrCtx is NewErr giving ctx to the Enricher, e.g. to tag the entry with
+// the tenant of the request being served.
+func NewErrCtx(ctx context.Context, parts ...any) error {
+	return newErr(ctx, 1, parts)
 }
 
-// FindErr walks an error tree (including errors.Join trees)
-// and returns the first match for target (via errors.As).
-func FindErr[T error](err error) (out T, ok bool) {
-	var t T
-	if errors.As(err, &t) {
-		return t, true
-	}
-	return out, false
+// WithErrCtx is WithErr giving ctx to the Enricher for any entry it creates.
+func WithErrCtx(ctx context.Context, parts ...any) error {
+	return withErrParts(ctx, 1, parts)
 }
 
-func AppendErr(errs []error, err error) []error {
-	if err == nil {
-		return errs
+// WrapErr is NewErr with cause as the trailing cause, except that it returns
+// nil when cause is nil, including a typed nil. It keeps the happy path of
+// interface-returning calls a one-liner:
+//
+//	return doterr.WrapErr(store.Load(key), ErrLoad, "key", key)
+func WrapErr(cause error, parts ...any) error {
+	if isNilErr(cause) {
+		return nil
 	}
-	return append(errs, err)
+	return newErr(nil, 1, append(slices.Clip(parts), cause))
 }
 
-//--------------------------------
-// Unexported implementation types
-//--------------------------------
+// Sentinel is a sentinel error with constructors scoped to it, so the most
+// common single-sentinel construction cannot forget the sentinel:
+//
+//	var ErrNotFound = doterr.NewSentinel("not found")
+//
+//	return ErrNotFound.New("key", k)
+//	return ErrNotFound.Wrap(err, "key", k)
+//
+// A *Sentinel is an ordinary comparable error: match it with errors.Is and
+// register it with RegisterSentinel like any other.
+type Sentinel struct {
+	msg   string
+	match func(error) bool
+}
 
-// kv is the internal implementation of the KV interface.
-type kv struct {
-	k string
-	v any
+// NewSentinel returns a new Sentinel with the given message. Like
+// errors.New, each call yields a distinct sentinel even for equal messages.
+func NewSentinel(msg string) *Sentinel {
+	return &Sentinel{msg: msg}
 }
 
-func (p kv) Key() string { return p.k }
-func (p kv) Value() any  { return p.v }
+// NewSentinelFunc returns a Sentinel that also matches, under errors.Is, any
+// doterr entry for which match reports true, expressing class membership
+// rather than identity:
+//
+//	var ErrServerSide = doterr.NewSentinelFunc("server-side failure", func(err error) bool {
+//	    status, _ := doterr.ErrValue[int](err, "status")
+//	    return status >= 500
+//	})
+//
+// match is called with each entry in the tree and with every error in the
+// trees of that entry's sentinels and of the cause it was built with, so it
+// sees entry metadata through ErrValue and foreign errors at any depth, such
+// as the syscall.Errno inside an *os.PathError. A cause joined on by WithErr
+// enriching an existing error, and an error outside any entry, are only
+// matched by identity. match must not call errors.Is with the Sentinel
+// itself.
+func NewSentinelFunc(msg string, match func(error) bool) *Sentinel {
+	return &Sentinel{msg: msg, match: match}
+}
 
-var uniqueId = rand.Int()
+func (s *Sentinel) Error() string { return s.msg }
 
-// entry represents one function's contribution to an error chain.
-// Each function creates one entry with errors (sentinels, custom typed errors) and metadata.
-// It implements error and Unwrap() []error.
-type entry struct {
-	id     int     // Unique ID
-	errors []error // sentinels, custom typed errors (NOT the primary cause)
-	kvs    []kv    // metadata in insertion order
+// New is NewErr(s, parts...); further sentinels may lead parts.
+func (s *Sentinel) New(parts ...any) error {
+	return newErr(nil, 1, append([]any{s}, parts...))
 }
 
-func newEntry(errors []error, kvs []kv) *entry {
-	return &entry{
-		id:     uniqueId,
-		errors: errors,
-		kvs:    kvs,
+// Wrap is WrapErr(cause, s, parts...): it returns nil for a nil cause.
+func (s *Sentinel) Wrap(cause error, parts ...any) error {
+	if isNilErr(cause) {
+		return nil
 	}
+	return newErr(nil, 1, append(append([]any{s}, parts...), cause))
 }
 
-func (e entry) Error() string {
-	if len(e.errors) == 0 && len(e.kvs) == 0 {
-		return "doterr{}"
+// Of is a sentinel belonging to the closed set of sentinels of kind K, so a
+// family of failures can be declared as a typed enum:
+//
+//	type authKind int
+//
+//	const (
+//	    authBadPassword authKind = iota
+//	    authLocked
+//	)
+//
+//	type AuthErr = doterr.Of[authKind]
+//
+//	var (
+//	    ErrBadPassword = doterr.NewOf(authBadPassword, "bad password")
+//	    ErrLocked      = doterr.NewOf(authLocked, "account locked")
+//	)
+//
+// Each *Of is an ordinary comparable sentinel for NewErr and errors.Is;
+// KindOf recovers the kind from an error and HandleAll dispatches on it
+// (doterrtest.CheckHandlers verifies in tests that the dispatch is
+// exhaustive).
+type Of[K comparable] struct {
+	kind K
+	msg  string
+}
+
+var (
+	enumMu      sync.Mutex
+	enumMembers = make(map[reflect.Type][]any) // kind type → its *Of members
+)
+
+// NewOf returns the member of K's set with the given kind and message. It
+// panics if kind already has a member, since members are declared once in
+// package-level vars.
+func NewOf[K comparable](kind K, msg string) *Of[K] {
+	enumMu.Lock()
+	defer enumMu.Unlock()
+	t := reflect.TypeFor[K]()
+	for _, m := range enumMembers[t] {
+		if m.(*Of[K]).kind == kind {
+			panic(fmt.Sprintf("doterr: duplicate %v member %v", t, kind))
+		}
 	}
+	m := &Of[K]{kind: kind, msg: msg}
+	enumMembers[t] = append(enumMembers[t], m)
+	return m
+}
 
-	var parts []string
+func (o *Of[K]) Error() string { return o.msg }
 
-	// Include sentinel errors first
-	for _, err := range e.errors {
-		parts = append(parts, err.Error())
+// Kind returns o's kind.
+func (o *Of[K]) Kind() K { return o.kind }
+
+// Members returns the members of K's set in declaration order.
+func Members[K comparable]() []*Of[K] {
+	enumMu.Lock()
+	defer enumMu.Unlock()
+	members := enumMembers[reflect.TypeFor[K]()]
+	out := make([]*Of[K], len(members))
+	for i, m := range members {
+		out[i] = m.(*Of[K])
 	}
+	return out
+}
 
-	// Then include metadata
-	if len(e.kvs) > 0 {
-		meta := "meta:"
-		for _, pair := range e.kvs {
-			meta += " " + fmt.Sprintf("%s=%v", pair.k, pair.v)
+// KindOf returns the kind of the first member of K's set in the tree of err
+// (in ErrSentinels() order).
+func KindOf[K comparable](err error) (kind K, ok bool) {
+	for _, s := range ErrSentinels(err) {
+		//goland:noinspection GoTypeAssertionOnErrors
+		if m, isMember := s.(*Of[K]); isMember {
+			return m.kind, true
 		}
-		parts = append(parts, meta)
 	}
+	return kind, false
+}
 
-	return strings.Join(parts, "; ")
+// HandleAll calls the handler for err's kind (see KindOf) and returns its
+// result, or returns err unchanged if it has no kind of K or no handler for
+// that kind. Use doterrtest.CheckHandlers in a test to catch members added
+// without a handler.
+func HandleAll[K comparable](err error, handlers map[K]func(error) error) error {
+	kind, ok := KindOf[K](err)
+	if !ok || handlers[kind] == nil {
+		return err
+	}
+	return handlers[kind](err)
 }
 
-func (e entry) Unwrap() []error {
-	if len(e.errors) == 0 {
+// ErrSealed is joined in front of a sealed error when WithErr is asked to
+// enrich it; see Seal().
+var ErrSealed = errors.New("error is sealed")
+
+// Seal marks err as final, e.g. at a trust boundary where a user-facing error
+// has been finalized and lower layers must not alter it. A sealed error
+// behaves exactly like err for Error(), errors.Is/As, ErrMeta() and Errors(),
+// but WithErr(sealed, ...) discards the new parts and returns the sealed error
+// joined behind a read-only ErrSealed entry instead of merging into it. The
+// same holds for any error whose tree holds a sealed error outside an entry,
+// such as a join or fmt.Errorf wrap of one, and enriching the result again
+// returns it unchanged. Using a sealed error as the trailing cause of
+// NewErr/WithErr is allowed, but the result is then final in turn.
+// Returns nil for nil; sealing a sealed error returns it as is.
+func Seal(err error) error {
+	if err == nil {
 		return nil
 	}
-	cp := make([]error, len(e.errors))
-	copy(cp, e.errors)
-	return cp
+	//goland:noinspection GoTypeAssertionOnErrors
+	if _, ok := err.(*sealed); ok {
+		return err
+	}
+	return &sealed{err: err}
 }
 
-func (e entry) empty() bool { return len(e.errors) == 0 && len(e.kvs) == 0 }
-
+// IsSealed reports whether err itself (not its causes) was returned by Seal.
+func IsSealed(err error) bool {
+	//goland:noinspection GoTypeAssertionOnErrors
+	_, ok := err.(*sealed)
+	return ok
+}
+
+// SharedErr holds a base error template, typically in a package-level var,
+// that many goroutines enrich concurrently:
+//
+//	var errQuota = doterr.NewSharedErr(doterr.NewErr(ErrQuota, "service", "billing"))
+//
+//	return errQuota.With("tenant", id, "used", n)
+//
+// With never modifies the template, and Update replaces it copy-on-write, so
+// readers always see a complete template and no goroutine observes another's
+// enrichment. A SharedErr must not be copied after first use.
+type SharedErr struct {
+	base atomic.Pointer[sharedBase]
+}
+
+// sharedBase boxes a SharedErr template, which may be nil.
+type sharedBase struct{ err error }
+
+// NewSharedErr returns a SharedErr holding base.
+func NewSharedErr(base error) *SharedErr {
+	s := &SharedErr{}
+	s.base.Store(&sharedBase{err: base})
+	return s
+}
+
+// Err returns the current template.
+func (s *SharedErr) Err() error {
+	if b := s.base.Load(); b != nil {
+		return b.err
+	}
+	return nil
+}
+
+// With returns WithErr(template, parts...), leaving the template unchanged.
+func (s *SharedErr) With(parts ...any) error {
+	return withErrParts(nil, 1, withBasePart(s.Err(), parts))
+}
+
+// Update atomically replaces the template with WithErr(template, parts...),
+// retrying if another goroutine replaced it first, and returns the new
+// template.
+func (s *SharedErr) Update(parts ...any) error {
+	for {
+		old := s.base.Load()
+		var base error
+		if old != nil {
+			base = old.err
+		}
+		next := &sharedBase{err: withErrParts(nil, 1, withBasePart(base, parts))}
+		if s.base.CompareAndSwap(old, next) {
+			return next.err
+		}
+	}
+}
+
+// Allocator supplies the storage of the entries NewErrWith builds, for batch
+// processors that create thousands of short-lived errors and want them
+// carved from an arena they release together (see doterrarena). Each method
+// returns an empty slice with room for n elements; appending past the room
+// copies to the heap.
+type Allocator interface {
+	Sentinels(n int) []error
+	Pairs(n int) []Pair
+}
+
+// NewErrWith is NewErr with the entry's sentinel and metadata slices taken
+// from alloc; a nil alloc allocates as NewErr does. Everything else, such as
+// the error value itself and the errors.Join that attaches a trailing cause,
+// still allocates.
+func NewErrWith(alloc Allocator, parts ...any) error {
+	return newErrIn(alloc, nil, 1, parts)
+}
+
+// allocEntry returns an empty entry whose errors and kvs have room for parts,
+// taken from alloc. A nil Allocator returns a plain entry.
+func allocEntry(alloc Allocator, parts []any) entry {
+	e := entry{id: uniqueId}
+	if alloc == nil {
+		return e
+	}
+	nErrs, nKVs := partCounts(parts)
+	if nErrs > 0 {
+		e.errors = alloc.Sentinels(nErrs)
+	}
+	if nKVs > 0 {
+		e.kvs = alloc.Pairs(nKVs)
+	}
+	return e
+}
+
+// partCounts returns how many sentinels and metadata pairs appendEntry
+// would take from parts, at most.
+func partCounts(parts []any) (nErrs, nKVs int) {
+	for i := 0; i < len(parts); i++ {
+		switch parts[i].(type) {
+		case KV:
+			nKVs++
+		case string:
+			nKVs++
+			i++
+		case error:
+			nErrs++
+		}
+	}
+	return nErrs, nKVs
+}
+
+// SentinelInfo describes a sentinel registered with RegisterSentinel.
+type SentinelInfo struct {
+	Sentinel error
+	Code     string       // stable, externally quoted code, e.g. "E1001"
+	Name     string       // stable identifier, e.g. "not_found"
+	Severity Severity     // default severity; see SetSentinelDefaults
+	Retry    Retryability // default retryability; see SetSentinelDefaults
+}
+
+// Retryability is a sentinel's default retry classification.
+type Retryability int
+
+const (
+	RetryUnset     Retryability = iota // no opinion
+	RetryAllowed                       // errors carrying the sentinel are retryable
+	RetryForbidden                     // never retry, whatever else is in the chain
+)
+
+// Sentinels for registry failures.
+var (
+	ErrDuplicateCode     = errors.New("duplicate sentinel code")
+	ErrDuplicateName     = errors.New("duplicate sentinel name")
+	ErrDuplicateSentinel = errors.New("sentinel already registered")
+	ErrInvalidSentinel   = errors.New("invalid sentinel registration")
+)
+
+// sentinelRegistry indexes registered sentinels by identity, code and name.
+type sentinelRegistry struct {
+	mu         sync.RWMutex
+	infos      []SentinelInfo // registration order
+	byCode     map[string]int
+	byName     map[string]int
+	bySentinel map[error]int
+}
+
+var registry = &sentinelRegistry{
+	byCode:     make(map[string]int),
+	byName:     make(map[string]int),
+	bySentinel: make(map[error]int),
+}
+
+// RegisterSentinel records sentinel under code and name so that codes can be
+// attached to rendered errors and looked up later. If name is empty the
+// sentinel's message is used. Registering the same sentinel again with the
+// same code and name is a no-op. Otherwise it returns a doterr error with
+// ErrDuplicateCode, ErrDuplicateName or ErrDuplicateSentinel (and "code",
+// "name" and "existing" metadata) if the registration would collide, so two
+// teams independently claiming "E1001" are caught at init time.
+//
+// The sentinel must be comparable, as errors.New values are. When doterr.go
+// is embedded, each package has its own registry.
+func RegisterSentinel(sentinel error, code, name string) error {
+	if name == "" && sentinel != nil {
+		name = sentinel.Error()
+	}
+	if !hashableErr(sentinel) || code == "" {
+		return NewErr(ErrInvalidSentinel, "code", code, "name", name)
+	}
+	return registry.add(SentinelInfo{Sentinel: sentinel, Code: code, Name: name})
+}
+
+// MustRegisterSentinel is RegisterSentinel for package-level vars; it returns
+// sentinel and panics with the structured registration error on failure:
+//
+//	var ErrNotFound = doterr.MustRegisterSentinel(errors.New("not found"), "E1001", "not_found")
+func MustRegisterSentinel(sentinel error, code, name string) error {
+	err := RegisterSentinel(sentinel, code, name)
+	if err != nil {
+		panic(err)
+	}
+	return sentinel
+}
+
+// SetSentinelDefaults records the default severity and retryability of a
+// registered sentinel. They propagate through wrapping: ErrSeverity() falls
+// back to the most severe default found anywhere in the chain, and
+// IsRetryable() honors the least retryable one. Returns an ErrInvalidSentinel
+// error if sentinel is not registered.
+func SetSentinelDefaults(sentinel error, sev Severity, retry Retryability) error {
+	if !hashableErr(sentinel) {
+		return NewErr(ErrInvalidSentinel, "reason", "not comparable", "sentinel", fmt.Sprintf("%v", sentinel))
+	}
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	i, ok := registry.bySentinel[sentinel]
+	if !ok {
+		return NewErr(ErrInvalidSentinel, "reason", "not registered", "sentinel", fmt.Sprintf("%v", sentinel))
+	}
+	registry.infos[i].Severity = sev
+	registry.infos[i].Retry = retry
+	return nil
+}
+
+// LookupSentinel returns the registration for sentinel, if any.
+func LookupSentinel(sentinel error) (SentinelInfo, bool) {
+	if !hashableErr(sentinel) {
+		// Entries and other non-comparable errors can sit among an entry's
+		// sentinels but can never be registered.
+		return SentinelInfo{}, false
+	}
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+	i, ok := registry.bySentinel[sentinel]
+	if !ok {
+		return SentinelInfo{}, false
+	}
+	return registry.infos[i], true
+}
+
+// LookupCode returns the registration for code, if any.
+func LookupCode(code string) (SentinelInfo, bool) {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+	i, ok := registry.byCode[code]
+	if !ok {
+		return SentinelInfo{}, false
+	}
+	return registry.infos[i], true
+}
+
+// RegisteredSentinels returns all registrations in registration order.
+func RegisteredSentinels() []SentinelInfo {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+	return slices.Clone(registry.infos)
+}
+
+// ErrCode returns the code of the first registered sentinel found in the tree
+// of err (in ErrSentinels() order), or "" if none is registered.
+func ErrCode(err error) string {
+	for _, s := range ErrSentinels(err) {
+		info, ok := LookupSentinel(s)
+		if ok {
+			return info.Code
+		}
+	}
+	return ""
+}
+
+var codePrefix atomic.Bool
+
+// SetCodePrefix enables prefixing the message of every entry that holds a
+// registered sentinel with its code in brackets, e.g. "[E1042] not found;
+// meta: key=k", so plain-text logs and runbooks can key off a stable
+// identifier (see ParseCode). It is off by default. Returns the previous
+// setting.
+func SetCodePrefix(on bool) bool {
+	return codePrefix.Swap(on)
+}
+
+// ParseCode returns the first bracketed code, as written by SetCodePrefix, in
+// line, e.g. "E1042" from "12:00:01 [INFO] [E1042] not found". Only
+// registered codes (see LookupCode) count, so bracketed log levels and other
+// tags are skipped.
+func ParseCode(line string) (code string, ok bool) {
+	for {
+		open := strings.IndexByte(line, '[')
+		if open < 0 {
+			return "", false
+		}
+		line = line[open+1:]
+		end := strings.IndexFunc(line, func(r rune) bool { return !isCodeRune(r) })
+		if end <= 0 || line[end] != ']' {
+			continue
+		}
+		if _, registered := LookupCode(line[:end]); registered {
+			return line[:end], true
+		}
+	}
+}
+
+// AliasSentinel makes errors.Is(err, old) report true for doterr entries that
+// carry replacement, so a catalog can rename or consolidate sentinels in
+// stages: new code creates errors with replacement while callers still
+// checking for old keep working. Aliases chain (old → mid → replacement) and
+// only apply to sentinels held directly by doterr entries. Returns an
+// ErrInvalidSentinel error if either sentinel is nil or not comparable, or if
+// they are the same.
+func AliasSentinel(old, replacement error) error {
+	//goland:noinspection GoDirectComparisonOfErrors
+	if !hashableErr(old) || !hashableErr(replacement) || old == replacement {
+		return NewErr(ErrInvalidSentinel,
+			"reason", "invalid alias",
+			"old", fmt.Sprintf("%v", old),
+			"replacement", fmt.Sprintf("%v", replacement),
+		)
+	}
+	aliasMu.Lock()
+	defer aliasMu.Unlock()
+	if !containsErr(aliases[old], replacement) {
+		aliases[old] = append(aliases[old], replacement)
+	}
+	return nil
+}
+
+// SentinelAliases returns the replacements old was given with
+// AliasSentinel, in the order they were added; those aliased in turn are not
+// followed.
+func SentinelAliases(old error) []error {
+	if !hashableErr(old) {
+		return nil
+	}
+	aliasMu.RLock()
+	defer aliasMu.RUnlock()
+	return slices.Clone(aliases[old])
+}
+
+var (
+	aliasMu sync.RWMutex
+	aliases = make(map[error][]error) // old sentinel → its replacements
+)
+
+// Namespace scopes metadata keys for reusable libraries built on doterr, so
+// their keys don't collide with application keys like "id" and "name":
+//
+//	var errs = doterr.Namespace("mylib")
+//	return errs.NewErr(ErrParse, "line", n, err) // key is "mylib.line"
+//
+// Only implicit "key", value string keys are prefixed; explicit KVs such as
+// Severity and Op keep their canonical keys so the package's accessors still
+// find them.
+type Namespace string
+
+// Key returns k prefixed with the namespace, e.g. "mylib.line".
+func (ns Namespace) Key(k string) string {
+	return string(ns) + "." + k
+}
+
+// NewErr is NewErr with string keys prefixed by the namespace.
+func (ns Namespace) NewErr(parts ...any) error {
+	return newErr(nil, 1, ns.prefixKeys(parts))
+}
+
+// WithErr is WithErr with string keys prefixed by the namespace.
+func (ns Namespace) WithErr(parts ...any) error {
+	return withErrParts(nil, 1, ns.prefixKeys(parts))
+}
+
+// NewOpErr is NewOpErr with string keys prefixed by the namespace.
+func (ns Namespace) NewOpErr(op Op, parts ...any) error {
+	return newOpErr(1, op, ns.prefixKeys(parts))
+}
+
+// prefixKeys returns a copy of parts with every string in key position
+// prefixed, using the same pairing rules as appendEntry.
+func (ns Namespace) prefixKeys(parts []any) []any {
+	out := make([]any, len(parts))
+	copy(out, parts)
+	for i := 0; i < len(out); i++ {
+		k, ok := out[i].(string)
+		if !ok {
+			continue
+		}
+		out[i] = ns.Key(k)
+		i++ // skip the value
+	}
+	return out
+}
+
+// CrossPackageMode controls what WithErr does when its base or cause is an
+// entry created by a different copy of doterr.go (see ErrNode).
+type CrossPackageMode int32
+
+const (
+	// CrossPackageFlag joins an ErrCrossPackageError entry (with "package_id"
+	// and "expected_id") in front of the foreign error.
+	CrossPackageFlag CrossPackageMode = iota
+	// CrossPackageAdopt joins a local, read-only copy of the foreign entry's
+	// sentinels and metadata, marked ForeignKey=true and "package_id", in
+	// front of the foreign error. The adopted copy is never enriched; new
+	// metadata goes into a fresh local entry.
+	CrossPackageAdopt
+)
+
+// ForeignKey marks entries adopted from another doterr copy.
+const ForeignKey = "foreign"
+
+var crossPackageMode atomic.Int32
+
+// SetCrossPackageMode sets how WithErr treats foreign entries and returns
+// the previous mode.
+func SetCrossPackageMode(m CrossPackageMode) CrossPackageMode {
+	return CrossPackageMode(crossPackageMode.Swap(int32(m)))
+}
+
+// MergePolicy controls how WithErr merges new metadata into an existing
+// doterr entry that already has a value for the same key.
+type MergePolicy int32
+
+const (
+	// MergeLayer appends the new pair after the existing one. Both are kept
+	// (and rendered); ErrValue() returns the existing, earlier value.
+	MergeLayer MergePolicy = iota
+	// MergeOverride replaces the existing value in place, keeping the key's
+	// original position.
+	MergeOverride
+	// MergeReject keeps the existing value, drops the new one, and joins an
+	// ErrMetaConflict entry (with "key") in front of the result.
+	MergeReject
+)
+
+// ErrMetaConflict is joined by WithErr under MergeReject when new metadata
+// uses a key the enriched entry already has.
+var ErrMetaConflict = errors.New("conflicting metadata key")
+
+var mergePolicy atomic.Int32
+
+// SetMergePolicy sets how WithErr resolves duplicate metadata keys and
+// returns the previous policy. When doterr.go is embedded, each package has
+// its own policy.
+func SetMergePolicy(p MergePolicy) MergePolicy {
+	return MergePolicy(mergePolicy.Swap(int32(p)))
+}
+
+// CombineErrs bundles a slice of errors into a single composite error that unwraps
+// to its members. Order is preserved and nils are skipped. Returns nil for an
+// empty/fully-nil slice, or the sole error when there is exactly one.
+// Options apply to the composite only.
+func CombineErrs(errs []error, opts ...CombineOption) error {
+	filtered := make([]error, 0, len(errs))
+	for _, e := range errs {
+		if !isNilErr(e) {
+			filtered = append(filtered, e)
+		}
+	}
+	switch len(filtered) {
+	case 0:
+		return nil
+	case 1:
+		return filtered[0]
+	default:
+		c := combined{errs: filtered}
+		for _, opt := range opts {
+			opt(&c)
+		}
+		return c
+	}
+}
+
+// CombineOption adjusts the composite built by CombineErrs.
+type CombineOption func(*combined)
+
+// WithRenderer makes the composite's Error() text use r instead of
+// AggregateFull; nil keeps AggregateFull. A panic in r is reported with
+// RunHook and AggregateFull used instead.
+func WithRenderer(r AggregateRenderer) CombineOption {
+	return func(c *combined) { c.render = r }
+}
+
+// AggregateRenderer renders the members of a CombineErrs aggregate as text.
+// Members are never nil.
+type AggregateRenderer func(errs []error) string
+
+// AggregateFull renders every member, one per line; it is the default.
+// Pass another renderer to CombineErrs with WithRenderer, or render any
+// aggregate with RenderAggregate.
+func AggregateFull() AggregateRenderer {
+	return func(errs []error) string {
+		messages := make([]string, len(errs))
+		for i, err := range errs {
+			messages[i] = err.Error()
+		}
+		return strings.Join(messages, "\n")
+	}
+}
+
+// AggregateFirst renders the first member on one line followed by the count
+// of the others, e.g. "db timeout (and 41 more errors)", for single-line
+// logs.
+func AggregateFirst() AggregateRenderer {
+	return func(errs []error) string {
+		return renderSome(errs[:min(len(errs), 1)], len(errs))
+	}
+}
+
+// AggregateTopN renders on one line the n most severe members (see
+// ErrSeverity), in their original order when equally severe, followed by the
+// count of the others.
+func AggregateTopN(n int) AggregateRenderer {
+	n = max(n, 1)
+	return func(errs []error) string {
+		top := slices.Clone(errs)
+		slices.SortStableFunc(top, func(a, b error) int {
+			return int(ErrSeverity(b)) - int(ErrSeverity(a))
+		})
+		return renderSome(top[:min(len(top), n)], len(errs))
+	}
+}
+
+// AggregateMaxBytes renders members one per line, like AggregateFull, until
+// the next would take the text past n bytes, then ends with a count of the
+// rest, e.g. "(and 3812 more errors)". Members past the cap are never
+// rendered, so huge aggregates cost O(n) to print. At least one member is
+// always shown.
+func AggregateMaxBytes(n int) AggregateRenderer {
+	return func(errs []error) string {
+		var sb strings.Builder
+		shown := 0
+		for _, err := range errs {
+			msg := err.Error()
+			if shown > 0 && sb.Len()+1+len(msg) > n {
+				break
+			}
+			if shown > 0 {
+				sb.WriteByte('\n')
+			}
+			sb.WriteString(msg)
+			shown++
+		}
+		switch rest := len(errs) - shown; rest {
+		case 0:
+		case 1:
+			sb.WriteString("\n(and 1 more error)")
+		default:
+			sb.WriteString("\n(and " + strconv.Itoa(rest) + " more errors)")
+		}
+		return sb.String()
+	}
+}
+
+// RenderAggregate renders err with r if it is a CombineErrs aggregate, and
+// returns err.Error() otherwise, whatever renderer the aggregate was built
+// with. Returns "" for nil.
+func RenderAggregate(err error, r AggregateRenderer) string {
+	if err == nil {
+		return ""
+	}
+	//goland:noinspection GoTypeAssertionOnErrors
+	c, ok := unseal(err).(combined)
+	if !ok || r == nil {
+		return err.Error()
+	}
+	return r(c.members())
+}
+
+// CauseRank orders two members of an aggregate for PrimaryCause and
+// RankCauses: negative if a is the more informative, positive if b is, and
+// 0 to defer to the next CauseRank.
+type CauseRank func(a, b error) int
+
+// RankBySeverity ranks more severe members (see ErrSeverity) first.
+func RankBySeverity() CauseRank {
+	return func(a, b error) int {
+		return int(ErrSeverity(b)) - int(ErrSeverity(a))
+	}
+}
+
+// RankNonRetryable ranks members that are not retryable (see IsRetryable)
+// before retryable ones, since a permanent failure explains more than a
+// blip that a retry would have cleared.
+func RankNonRetryable() CauseRank {
+	return func(a, b error) int {
+		ra, rb := IsRetryable(a), IsRetryable(b)
+		switch {
+		case ra == rb:
+			return 0
+		case rb:
+			return -1
+		}
+		return 1
+	}
+}
+
+var causeRanking atomic.Pointer[[]CauseRank]
+
+// SetCauseRanking sets the CauseRanks PrimaryCause and RankCauses apply, in
+// order, and returns the previous ones. Members they leave tied keep their
+// original order. Passing none restores the default: RankBySeverity, then
+// RankNonRetryable.
+func SetCauseRanking(ranks ...CauseRank) []CauseRank {
+	var next *[]CauseRank
+	if len(ranks) > 0 {
+		ranks = slices.Clone(ranks)
+		next = &ranks
+	}
+	prev := causeRanking.Swap(next)
+	if prev == nil {
+		return []CauseRank{RankBySeverity(), RankNonRetryable()}
+	}
+	return *prev
+}
+
+// RankCauses returns the members of the aggregate err, most informative
+// first according to SetCauseRanking, limited to the best n when n > 0.
+// Aggregates are CombineErrs results and errors.Join trees, except joins led
+// by a doterr entry: NewErr and WithErr build those to attach a cause, so
+// they are a chain, not a set of alternatives (use CombineErrs to aggregate
+// doterr errors). Returns nil if err is not an aggregate.
+func RankCauses(err error, n int) []error {
+	members := aggregateMembers(err)
+	if len(members) == 0 {
+		return nil
+	}
+	ranks := []CauseRank{RankBySeverity(), RankNonRetryable()}
+	if r := causeRanking.Load(); r != nil {
+		ranks = *r
+	}
+	slices.SortStableFunc(members, func(a, b error) int {
+		for _, rank := range ranks {
+			if c := rank(a, b); c != 0 {
+				return c
+			}
+		}
+		return 0
+	})
+	if n > 0 && n < len(members) {
+		members = members[:n]
+	}
+	return members
+}
+
+// PrimaryCause returns the most informative member of the aggregate err
+// (see RankCauses) to surface in single-line contexts, instead of whichever
+// member happens to be first. A member that is itself an aggregate is ranked
+// in turn. Returns err if it is not an aggregate, and nil for nil.
+func PrimaryCause(err error) error {
+	for {
+		best := RankCauses(err, 1)
+		if best == nil {
+			return err
+		}
+		err = best[0]
+	}
+}
+
+// aggregateMembers returns a fresh slice of the members of err if it is an
+// aggregate as RankCauses defines it, and nil otherwise.
+func aggregateMembers(err error) []error {
+	//goland:noinspection GoTypeAssertionOnErrors
+	_, combinedErrs := unseal(err).(combined)
+	var members []error
+	for member := range ChildrenSeq(err) {
+		if _, ok := nodeEntry(member); ok && !combinedErrs && len(members) == 0 {
+			return nil // a doterr entry joined with its cause
+		}
+		members = append(members, member)
+	}
+	return members
+}
+
+// ErrMeta returns the key/value pairs stored on a doterr entry.
+// If err is a doterr entry, returns its metadata.
+// If err is a joined error (has Unwrap() []error), scans immediate children
+// left-to-right and returns metadata from the first doterr entry found.
+// Otherwise returns nil.
+// The returned slice preserves insertion order and is a copy.
+func ErrMeta(err error) []KV {
+	// Sealed errors are transparent to inspection.
+	err = unseal(err)
+
+	// Case (a): err is an entry → return its metadata
+	e, ok := nodeEntry(err)
+	if ok {
+		out := make([]KV, len(e.kvs))
+		for i, pair := range e.kvs {
+			out[i] = pair
+		}
+		return out
+	}
+
+	// Case (b): err is a join (multi-unwrap) → scan immediate children left-to-right
+	type unwrapper interface{ Unwrap() []error }
+	u, ok := err.(unwrapper)
+	if !ok {
+		return nil
+	}
+	children := u.Unwrap()
+	for _, child := range children {
+		ce, ok := nodeEntry(child)
+		if ok {
+			out := make([]KV, len(ce.kvs))
+			for i, pair := range ce.kvs {
+				out[i] = pair
+			}
+			return out
+		}
+	}
+
+	return nil
+}
+
+// ErrValue extracts a single metadata value by key with type safety.
+// Returns the value and true if found and the value is of type T.
+// Returns the zero value of T and false if not found or type mismatch.
+//
+// Example:
+//
+//	status, ok := doterr.ErrValue[int](err, "http_status")
+//	if ok {
+//	    fmt.Printf("Status: %d\n", status)
+//	}
+//
+//	name, ok := doterr.ErrValue[string](err, "parameter_name")
+func ErrValue[T any](err error, key string) (T, bool) {
+	var zero T
+	kvs := ErrMeta(err)
+	if kvs == nil {
+		return zero, false
+	}
+
+	for _, pair := range kvs {
+		if pair.Key() == key {
+			if val, ok := pair.Value().(T); ok {
+				return val, true
+			}
+			return zero, false
+		}
+	}
+	return zero, false
+}
+
+// Errors returns the errors stored on a doterr entry.
+// If err is a doterr entry, returns its errors.
+// If err is a joined error (has Unwrap() []error), scans immediate children
+// left-to-right and returns errors from the first doterr entry found.
+// Otherwise returns nil.
+// The returned slice preserves insertion order and is a copy.
+//
+// Note: These errors may be sentinel errors (e.g., ErrRepo), custom error types
+// (e.g., *rfc9457.Error), or any other error type stored in the entry.
+func Errors(err error) []error {
+	// Sealed errors are transparent to inspection.
+	err = unseal(err)
+
+	// Case (a): err is an entry → return its errors
+	e, ok := nodeEntry(err)
+	if ok {
+		out := make([]error, len(e.errors))
+		copy(out, e.errors)
+		return out
+	}
+
+	// Case (b): err is a join (multi-unwrap) → scan immediate children left-to-right
+	type unwrapper interface{ Unwrap() []error }
+	u, ok := err.(unwrapper)
+	if !ok {
+		return nil
+	}
+	children := u.Unwrap()
+	for _, child := range children {
+		if ce, ok := nodeEntry(child); ok {
+			out := make([]error, len(ce.errors))
+			copy(out, ce.errors)
+			return out
+		}
+	}
+
+	return nil
+}
+
+// FindErr walks an error tree (including errors.Join trees)
+// and returns the first match for target (via errors.As).
+func FindErr[T error](err error) (out T, ok bool) {
+	var t T
+	if errors.As(err, &t) {
+		return t, true
+	}
+	return out, false
+}
+
+func AppendErr(errs []error, err error) []error {
+	if isNilErr(err) {
+		return errs
+	}
+	return append(errs, err)
+}
+
+// ErrSentinels returns the sentinel errors from every doterr entry found
+// anywhere in the tree of err, walking errors.Join trees and single-unwrap
+// chains depth-first, left-to-right. Unlike Errors(), which only looks at
+// the first entry, this sees entries nested at any depth. Duplicates (by
+// identity) are returned once, in first-seen order. Returns nil if none.
+func ErrSentinels(err error) []error {
+	var out []error
+	walkEntries(err, func(e entry) {
+		for _, s := range e.errors {
+			if !containsErr(out, s) {
+				out = append(out, s)
+			}
+		}
+	})
+	return out
+}
+
+// IsRetryable reports whether err is classified as retryable: ErrRetryable
+// appears anywhere in its tree, or a sentinel in it defaults to RetryAllowed.
+// The least retryable classification wins, so any sentinel defaulting to
+// RetryForbidden makes err non-retryable, ErrRetryable included. See
+// SetSentinelDefaults.
+func IsRetryable(err error) bool {
+	allowed := false
+	for _, s := range ErrSentinels(err) {
+		info, _ := LookupSentinel(s)
+		switch info.Retry {
+		case RetryForbidden:
+			return false
+		case RetryAllowed:
+			allowed = true
+		}
+	}
+	return allowed || errors.Is(err, ErrRetryable)
+}
+
+// ErrSeverity returns the Severity of err: the one stored on the first doterr
+// entry in the tree that has one, searching depth-first, left-to-right (so
+// the outermost layer wins over inner explicit severities), raised to the
+// most severe sentinel default found anywhere in the tree (see
+// SetSentinelDefaults). An explicit Severity can escalate a classification
+// but never lower a sentinel's default, just as ErrRetryable cannot make a
+// RetryForbidden sentinel retryable. Returns SeverityUnset if there is none.
+func ErrSeverity(err error) Severity {
+	sev := SeverityUnset
+	walkEntries(err, func(e entry) {
+		if sev != SeverityUnset {
+			return
+		}
+		for _, pair := range e.kvs {
+			s, ok := pair.v.(Severity)
+			if ok && pair.k == SeverityKey {
+				sev = s
+				return
+			}
+		}
+	})
+	for _, s := range ErrSentinels(err) {
+		info, _ := LookupSentinel(s)
+		sev = max(sev, info.Severity)
+	}
+	return sev
+}
+
+// WithRetryAfter enriches err with RetryAfterKey set to d, following the
+// same merge rules as WithErr. Returns nil if err is nil.
+func WithRetryAfter(err error, d time.Duration) error {
+	if isNilErr(err) {
+		return nil
+	}
+	return WithErr(err, RetryAfterKey, d)
+}
+
+// RetryAfter returns the RetryAfterKey duration from the first doterr entry
+// in the tree of err that carries one. Returns false if none is found.
+func RetryAfter(err error) (time.Duration, bool) {
+	v, ok := findValue(err, RetryAfterKey)
+	if !ok {
+		return 0, false
+	}
+	d, ok := v.(time.Duration)
+	return d, ok
+}
+
+// Metadata keys recorded by WithJob.
+const (
+	JobQueueKey   = "job_queue"   // queue or topic the job was taken from
+	JobIDKey      = "job_id"      // the queue's identifier for the job
+	JobAttemptKey = "job_attempt" // int, 1 for the first delivery
+)
+
+// Job identifies the background job a failure belongs to, as recorded by
+// WithJob and read back by ErrJob.
+type Job struct {
+	Queue   string
+	ID      string
+	Attempt int
+}
+
+// WithJob enriches err with JobQueueKey, JobIDKey and JobAttemptKey, giving
+// background-job failures one shape that DLQ tooling and retry dashboards
+// can rely on across services:
+//
+//	return doterr.WithJob(err, "billing.invoices", msg.ID, msg.Deliveries)
+//
+// It follows the same merge rules as WithErr. Returns nil if err is nil.
+func WithJob(err error, queue, jobID string, attempt int) error {
+	if isNilErr(err) {
+		return nil
+	}
+	return withErrParts(nil, 1, []any{err, JobQueueKey, queue, JobIDKey, jobID, JobAttemptKey, attempt})
+}
+
+// ErrJob returns the Job recorded on the first doterr entry in the tree of
+// err that carries a JobIDKey. Queue and Attempt are read from that same
+// entry, so the three never mix across jobs. Returns false if none is found.
+func ErrJob(err error) (job Job, found bool) {
+	walkEntriesUntil(err, func(e entry) bool {
+		for _, pair := range e.kvs {
+			if pair.k == JobIDKey {
+				job.ID, found = pair.v.(string)
+			}
+		}
+		if !found {
+			return true
+		}
+		for _, pair := range e.kvs {
+			switch pair.k {
+			case JobQueueKey:
+				job.Queue, _ = pair.v.(string)
+			case JobAttemptKey:
+				switch n := pair.v.(type) {
+				case int:
+					job.Attempt = n
+				case int64:
+					job.Attempt = int(n)
+				}
+			}
+		}
+		return false
+	})
+	return job, found
+}
+
+// BlobRef is an opaque reference to a payload held in a BlobStore. Only the
+// reference travels in the error, so large evidence (request and response
+// bodies, dumps) is preserved without bloating logs and wire encodings.
+type BlobRef string
+
+// BlobStore stores large payloads out of band for Attach. Implementations
+// must be safe for concurrent use.
+type BlobStore interface {
+	Put(name string, data []byte) (BlobRef, error)
+	Get(ref BlobRef) ([]byte, error)
+}
+
+// AttachmentKeyPrefix prefixes the metadata key Attach stores a reference under.
+const AttachmentKeyPrefix = "attachment."
+
+// Attachment is a named BlobRef found on an error by Attachments.
+type Attachment struct {
+	Name string
+	Ref  BlobRef
+}
+
+// Attach enriches err with a reference to a payload previously stored in a
+// BlobStore, under AttachmentKeyPrefix+name, following the same merge rules
+// as WithErr. Returns nil if err is nil.
+func Attach(err error, name string, ref BlobRef) error {
+	if isNilErr(err) {
+		return nil
+	}
+	return WithErr(err, AttachmentKeyPrefix+name, ref)
+}
+
+// Attachments returns every attachment found anywhere in the tree of err,
+// depth-first, left-to-right. References arriving as plain strings (e.g.
+// from a decoded wire encoding) are recognized too. Returns nil if none.
+func Attachments(err error) []Attachment {
+	var out []Attachment
+	walkEntries(err, func(e entry) {
+		for _, pair := range e.kvs {
+			name, ok := strings.CutPrefix(pair.k, AttachmentKeyPrefix)
+			if !ok {
+				continue
+			}
+			switch ref := pair.v.(type) {
+			case BlobRef:
+				out = append(out, Attachment{Name: name, Ref: ref})
+			case string:
+				out = append(out, Attachment{Name: name, Ref: BlobRef(ref)})
+			}
+		}
+	})
+	return out
+}
+
+// Op names a logical operation, conventionally "package.Method", e.g.
+// Op("store.Save"). An Op is itself a KV whose key is OpKey, so it can be
+// passed to NewErr/WithErr; NewOpErr() is the shorthand that puts it first.
+// ErrOps() and ErrOpTrace() read the ops back as a logical trace that is far
+// more readable than a stack trace.
+type Op string
+
+// OpKey is the metadata key under which an Op is stored.
+const OpKey = "op"
+
+func (op Op) Key() string { return OpKey }
+func (op Op) Value() any  { return op }
+
+// NewOpErr is NewErr with op recorded on the entry:
+//
+//	return doterr.NewOpErr(Op("store.Save"), ErrIO, "key", k, err)
+//
+// The remaining parts follow the NewErr rules (sentinels first, then
+// metadata, then optional trailing cause).
+func NewOpErr(op Op, parts ...any) error {
+	return newOpErr(1, op, parts)
+}
+
+// newOpErr implements NewOpErr; skip works as for newErr.
+func newOpErr(skip int, op Op, parts []any) error {
+	i := 0
+	for i < len(parts) {
+		if _, ok := parts[i].(error); !ok {
+			break
+		}
+		i++
+	}
+	withOp := make([]any, 0, len(parts)+1)
+	withOp = append(withOp, parts[:i]...)
+	withOp = append(withOp, op)
+	withOp = append(withOp, parts[i:]...)
+	return newErr(nil, skip+1, withOp)
+}
+
+// ErrOps returns the Op of every doterr entry in the tree of err, outermost
+// first. Returns nil if no entry carries an Op.
+func ErrOps(err error) []Op {
+	var ops []Op
+	walkEntriesDeep(err, func(e entry) bool {
+		for _, pair := range e.kvs {
+			op, ok := pair.v.(Op)
+			if ok && pair.k == OpKey {
+				ops = append(ops, op)
+				break
+			}
+		}
+		return true
+	})
+	return ops
+}
+
+// ErrOpTrace renders the operation path of err from outermost to innermost,
+// e.g. "api.Upload → store.Save → s3.Put". Returns "" if err has no ops.
+func ErrOpTrace(err error) string {
+	ops := ErrOps(err)
+	names := make([]string, len(ops))
+	for i, op := range ops {
+		names[i] = string(op)
+	}
+	return strings.Join(names, " → ")
+}
+
+// Metadata keys recorded by Timed.
+const (
+	DurationKey = "duration_ms" // int64 milliseconds the failing call took
+	StartKey    = "start"       // time.Time the failing call started
+)
+
+// Timed runs fn and, if it fails, returns its error as the cause of a new
+// entry carrying op as an Op, DurationKey and StartKey, answering "how long did the
+// failing call take" the same way everywhere:
+//
+//	err := doterr.Timed("billing.Charge", func() error { return client.Charge(ctx, req) })
+//
+// Returns nil if fn succeeds.
+func Timed(op string, fn func() error) error {
+	start := time.Now()
+	err := fn()
+	if err == nil {
+		return nil
+	}
+	return withErrParts(nil, 1, []any{Op(op), DurationKey, time.Since(start).Milliseconds(), StartKey, start, err})
+}
+
+// Causes returns an iterator over the primary cause chain of err, starting
+// with err itself and lazily following each node's cause:
+//
+//	for e := range doterr.Causes(err) {
+//	  fmt.Println(doterr.ErrMeta(e)) // one layer's metadata per iteration
+//	}
+//
+// A node's cause is the error it wraps via Unwrap() error, or the LAST member
+// of an errors.Join tree, since NewErr/WithErr always join the cause last.
+// The chain ends at a doterr entry (its sentinels are not causes), at a
+// CombineErrs aggregate (its members are independent, not causal), or at a
+// leaf error.
+func Causes(err error) iter.Seq[error] {
+	return func(yield func(error) bool) {
+		for err != nil {
+			if !yield(err) {
+				return
+			}
+			err = nextCause(err)
+		}
+	}
+}
+
+// ChildrenSeq returns an iterator over the members of an aggregate (a
+// CombineErrs result or any error with Unwrap() []error), skipping nils. For
+// a CombineErrs aggregate the members are read in place rather than copied,
+// so a bulk import's thousands of failures can be inspected one at a time.
+// It yields nothing for other errors, including doterr entries.
+func ChildrenSeq(err error) iter.Seq[error] {
+	return func(yield func(error) bool) {
+		var members []error
+		//goland:noinspection GoTypeAssertionOnErrors
+		switch u := unseal(err).(type) {
+		case ErrNode:
+			// An entry's Unwrap lists its sentinels, not members.
+		case combined:
+			members = u.errs
+		case interface{ Unwrap() []error }:
+			members = u.Unwrap()
+		}
+		for _, m := range members {
+			if m != nil && !yield(m) {
+				return
+			}
+		}
+	}
+}
+
+// MetaStream returns an iterator over the metadata of every doterr entry in
+// the tree of err, depth-first and left-to-right, without collecting it:
+//
+//	for k, v := range doterr.MetaStream(err) {
+//	    if k == "row" { ... }
+//	}
+//
+// Unlike ErrMeta, which reads one entry, it sees every entry, so keys may
+// repeat. Stopping early stops the walk.
+func MetaStream(err error) iter.Seq2[string, any] {
+	return func(yield func(string, any) bool) {
+		walkEntriesUntil(err, func(e entry) bool {
+			for _, pair := range e.kvs {
+				if !yield(pair.k, pair.v) {
+					return false
+				}
+			}
+			return true
+		})
+	}
+}
+
+// ErrFingerprint returns a stable identifier for the shape of err, suitable
+// for grouping and deduplicating reported errors. It hashes the sentinel
+// messages and metadata keys of every doterr entry in the tree, plus the
+// concrete types of any non-doterr errors, walking errors.Join trees and
+// single-unwrap chains. Metadata values are deliberately excluded so errors
+// that differ only by IDs or timestamps share a fingerprint.
+// Returns "" for a nil error.
+func ErrFingerprint(err error) string {
+	if err == nil {
+		return ""
+	}
+	h := fnv.New64a()
+	fingerprintErr(h, err)
+	return fmt.Sprintf("%016x", h.Sum64())
+}
+
+// EqualOption adjusts the comparison made by ErrEqual.
+type EqualOption func(*equalConfig)
+
+// IgnoreKeys makes ErrEqual skip metadata with the given keys, typically
+// intentionally volatile fields such as "timestamp" or "instance_id".
+func IgnoreKeys(keys ...string) EqualOption {
+	return func(c *equalConfig) {
+		c.ignore = append(c.ignore, keys...)
+	}
+}
+
+// IgnoreStacks makes ErrEqual skip captured call sites and stacks
+// (CallerKey and PanicStackKey).
+func IgnoreStacks() EqualOption {
+	return IgnoreKeys(CallerKey, PanicStackKey)
+}
+
+// ErrEqual reports whether a and b are structurally equal: the same shape of
+// entries, joins and wrapping errors, with entries holding the same sentinels
+// (by identity, or structurally when a sentinel is itself a composite error)
+// and the same metadata in the same order (values compared with
+// reflect.DeepEqual). Other errors must have the same type and message. It
+// is meant for tests and deduplication, where errors built separately should
+// compare equal; use opts to exclude volatile metadata.
+func ErrEqual(a, b error, opts ...EqualOption) bool {
+	var c equalConfig
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return c.equal(a, b)
+}
+
+// Diff is the metadata difference between two errors; see MetaDiff. Each
+// slice is in the key order of the error it came from.
+type Diff struct {
+	Added   []KV         // keys only in b
+	Removed []KV         // keys only in a
+	Changed []MetaChange // keys in both, with differing values
+}
+
+// MetaChange is a key whose value differs between the errors given MetaDiff.
+type MetaChange struct {
+	Key      string
+	Old, New any
+}
+
+// Empty reports whether d records no differences.
+func (d Diff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// String renders d one key per line as "+key=value", "-key=value" or
+// "~key: old -> new". Returns "" for an empty Diff.
+func (d Diff) String() string {
+	var sb strings.Builder
+	for _, p := range d.Added {
+		fmt.Fprintf(&sb, "+%s=%v\n", p.Key(), p.Value())
+	}
+	for _, p := range d.Removed {
+		fmt.Fprintf(&sb, "-%s=%v\n", p.Key(), p.Value())
+	}
+	for _, c := range d.Changed {
+		fmt.Fprintf(&sb, "~%s: %v -> %v\n", c.Key, c.Old, c.New)
+	}
+	return sb.String()
+}
+
+// MetaDiff reports the metadata keys added, removed or changed going from a
+// to b, across every doterr entry in each tree. As with ErrValue, a key found
+// on several entries takes its outermost value. Values are compared with
+// reflect.DeepEqual. It is meant for triaging flaky tests and for checking
+// that a refactor kept an error's diagnostic context:
+//
+//	if d := doterr.MetaDiff(before, after); !d.Empty() {
+//	  t.Errorf("metadata changed:\n%s", d)
+//	}
+func MetaDiff(a, b error) Diff {
+	ka, va := flatMeta(a)
+	kb, vb := flatMeta(b)
+	var d Diff
+	for _, k := range ka {
+		nv, ok := vb[k]
+		switch {
+		case !ok:
+			d.Removed = append(d.Removed, Pair{k: k, v: va[k]})
+		case !reflect.DeepEqual(va[k], nv):
+			d.Changed = append(d.Changed, MetaChange{Key: k, Old: va[k], New: nv})
+		}
+	}
+	for _, k := range kb {
+		if _, ok := va[k]; !ok {
+			d.Added = append(d.Added, Pair{k: k, v: vb[k]})
+		}
+	}
+	return d
+}
+
+// Prune returns err without the doterr entries for which drop reports true,
+// e.g. to strip debug-severity annotations before an error leaves the
+// service:
+//
+//	err = doterr.Prune(err, func(n doterr.ErrNode) bool {
+//	  return doterr.ErrSeverity(n) == doterr.SeverityDebug
+//	})
+//
+// A dropped entry is replaced by its bare sentinels, so errors.Is still
+// matches them while its metadata and the entry itself are gone. Joins and
+// fmt.Errorf wraps are rebuilt around what changed, keeping a wrap's own
+// text. Entries beneath any other wrapping error are left as they are, so
+// that error keeps its identity for errors.As and its own Is and As methods.
+// Sealed errors stay sealed. Returns err itself when nothing matched.
+func Prune(err error, drop func(ErrNode) bool) error {
+	if err == nil || drop == nil {
+		return err
+	}
+	pruned, _ := pruneErr(err, drop)
+	return pruned
+}
+
+// RewriteEntries returns err with the sentinels and metadata of every doterr
+// entry in its tree passed through fn, for policies applied where errors
+// leave a layer (see doterrboundary). fn is given copies it may modify, and
+// returns the sentinels and metadata to keep and whether they differ from
+// what it was given. Causes stored among the sentinels are rewritten in turn
+// before fn sees them. Structure is kept as for Prune, an entry left with
+// neither sentinels nor metadata disappears, and a rewritten foreign entry
+// stays read-only. Returns err itself when nothing changed, and nil when
+// nothing is left.
+func RewriteEntries(err error, fn func(sentinels []error, meta []KV) ([]error, []KV, bool)) error {
+	if err == nil || fn == nil {
+		return err
+	}
+	rewritten, _ := rewriteErr(err, func(e entry) (error, bool) {
+		meta := make([]KV, len(e.kvs))
+		for i, pair := range e.kvs {
+			meta[i] = pair
+		}
+		errs, kvs, changed := fn(slices.Clone(e.errors), meta)
+		if !changed {
+			return e, false
+		}
+		e.errors = errs
+		e.kvs = make([]Pair, len(kvs))
+		for i, pair := range kvs {
+			e.kvs[i] = Pair{k: pair.Key(), v: pair.Value()}
+		}
+		return e, true
+	})
+	return rewritten
+}
+
+// Condense returns err to render, should its Error() text be longer than
+// maxBytes, as the stand-in SetCauseSizeLimit gives a long cause: its doterr
+// sentinels plus CauseTypeKey, CauseHashKey, CauseCodeKey and CauseSizeKey.
+// err stays reachable through Unwrap, so errors.Is and errors.As keep
+// matching it. Returns err itself if it is short enough or maxBytes <= 0.
+func Condense(err error, maxBytes int) error {
+	if isNilErr(err) || maxBytes <= 0 || len(err.Error()) <= maxBytes {
+		return err
+	}
+	return &summarized{cause: err, limit: maxBytes}
+}
+
+// ArrivalKey is the metadata key CollectChan uses to record the 1-based order
+// in which each error arrived on the channel.
+const ArrivalKey = "arrival"
+
+// CollectChan drains errs until it is closed, enriching each non-nil error
+// with ArrivalKey, and returns them combined via CombineErrs in arrival
+// order. It is meant for fan-out/fan-in code where N producers send to one
+// channel that is closed once they are all done (e.g. after a WaitGroup).
+//
+// If ctx is done before errs is closed, CollectChan stops draining and
+// ctx.Err() is appended to the result. Producers must then not block on
+// sending, e.g. by also selecting on ctx.Done().
+func CollectChan(ctx context.Context, errs <-chan error) error {
+	var collected []error
+	for {
+		select {
+		case <-ctx.Done():
+			return CombineErrs(append(collected, ctx.Err()))
+		case err, ok := <-errs:
+			if !ok {
+				return CombineErrs(collected)
+			}
+			if err != nil {
+				collected = append(collected, WithErr(err, ArrivalKey, len(collected)+1))
+			}
+		}
+	}
+}
+
+// PartialResult records the outcome of a bulk operation where some items
+// succeeded and others failed, e.g. a bulk API call or a sync job. The zero
+// value is ready to use; it is not safe for concurrent use.
+//
+//	var pr doterr.PartialResult
+//	for _, u := range users {
+//	  err := save(u)
+//	  if err != nil {
+//	    pr.Fail(u.ID, err)
+//	    continue
+//	  }
+//	  pr.Succeed(u.ID)
+//	}
+//	log.Print(pr.Summary()) // "42 succeeded, 3 failed"
+//	return pr.Err()
+type PartialResult struct {
+	Succeeded []string
+	Failed    []ItemErr
+}
+
+// ItemErr is one failed item of a PartialResult.
+type ItemErr struct {
+	ID  string
+	Err error
+}
+
+// ItemDetail is the structured, serializable rendering of one ItemErr.
+type ItemDetail struct {
+	ID        string         `json:"id"`
+	Error     string         `json:"error"`
+	Sentinels []string       `json:"sentinels,omitempty"`
+	Meta      map[string]any `json:"meta,omitempty"`
+}
+
+// ErrPartialFailure marks the summary entry of PartialResult.Err().
+var ErrPartialFailure = errors.New("partial failure")
+
+// Succeed records id as having succeeded.
+func (p *PartialResult) Succeed(id string) {
+	p.Succeeded = append(p.Succeeded, id)
+}
+
+// Fail records id as having failed with err. A nil err is recorded as a
+// success, matching the usual `if err != nil` flow.
+func (p *PartialResult) Fail(id string, err error) {
+	if err == nil {
+		p.Succeed(id)
+		return
+	}
+	p.Failed = append(p.Failed, ItemErr{ID: id, Err: err})
+}
+
+// Summary returns a one-line human summary, e.g. "42 succeeded, 3 failed".
+func (p PartialResult) Summary() string {
+	return fmt.Sprintf("%d succeeded, %d failed", len(p.Succeeded), len(p.Failed))
+}
+
+// Details returns one ItemDetail per failed item, in the order they failed.
+// Sentinels come from ErrSentinels() and Meta from ErrMeta() of each item.
+func (p PartialResult) Details() []ItemDetail {
+	out := make([]ItemDetail, len(p.Failed))
+	for i, f := range p.Failed {
+		d := ItemDetail{ID: f.ID, Error: f.Err.Error()}
+		for _, s := range ErrSentinels(f.Err) {
+			d.Sentinels = append(d.Sentinels, s.Error())
+		}
+		kvs := ErrMeta(f.Err)
+		if len(kvs) > 0 {
+			d.Meta = make(map[string]any, len(kvs))
+			for _, pair := range kvs {
+				d.Meta[pair.Key()] = pair.Value()
+			}
+		}
+		out[i] = d
+	}
+	return out
+}
+
+// Err returns nil if no item failed. Otherwise it returns an aggregate whose
+// first member is an ErrPartialFailure entry with "succeeded" and "failed"
+// counts, followed by each item's error enriched with "item_id".
+func (p PartialResult) Err() error {
+	if len(p.Failed) == 0 {
+		return nil
+	}
+	errs := make([]error, 0, len(p.Failed)+1)
+	errs = append(errs, newErr(nil, 1, []any{ErrPartialFailure,
+		"succeeded", len(p.Succeeded),
+		"failed", len(p.Failed),
+	}))
+	for _, f := range p.Failed {
+		errs = append(errs, WithErr(f.Err, "item_id", f.ID))
+	}
+	return CombineErrs(errs)
+}
+
+// ErrInvalidField marks each per-field child of NewValidationErr.
+var ErrInvalidField = errors.New("invalid field")
+
+// Metadata keys used by NewValidationErr.
+const (
+	FieldKey        = "field"
+	ReasonKey       = "reason"
+	InvalidCountKey = "invalid_count"
+)
+
+// FieldErr is one invalid field of a validation error, shaped for the
+// "fields" array of a problem+json response.
+type FieldErr struct {
+	Field  string `json:"field"`
+	Reason string `json:"reason"`
+}
+
+// NewValidationErr builds an aggregate from validation results: its first
+// member is a sentinel entry with the InvalidCountKey count, followed by one
+// ErrInvalidField entry per field (sorted by field name) carrying FieldKey
+// and ReasonKey. Returns nil if fields is empty.
+func NewValidationErr(sentinel error, fields map[string]string) error {
+	if len(fields) == 0 {
+		return nil
+	}
+	names := slices.Sorted(maps.Keys(fields))
+	errs := make([]error, 0, len(fields)+1)
+	errs = append(errs, newErr(nil, 1, []any{sentinel, InvalidCountKey, len(fields)}))
+	for _, name := range names {
+		errs = append(errs, newErr(nil, 1, []any{ErrInvalidField, FieldKey, name, ReasonKey, fields[name]}))
+	}
+	return CombineErrs(errs)
+}
+
+// ValidationFields returns the invalid fields of every ErrInvalidField entry
+// found anywhere in the tree of err, in order. Returns nil if there are none.
+func ValidationFields(err error) []FieldErr {
+	var out []FieldErr
+	walkEntries(err, func(e entry) {
+		if !containsErr(e.errors, ErrInvalidField) {
+			return
+		}
+		var f FieldErr
+		for _, pair := range e.kvs {
+			switch pair.k {
+			case FieldKey:
+				f.Field, _ = pair.v.(string)
+			case ReasonKey:
+				f.Reason, _ = pair.v.(string)
+			}
+		}
+		out = append(out, f)
+	})
+	return out
+}
+
+// ErrPanic marks errors converted from a recovered panic by RecoverErr.
+var ErrPanic = errors.New("panic")
+
+// Metadata keys attached by RecoverErr.
+const (
+	PanicTypeKey  = "panic_type"  // string: %T of the recovered value
+	PanicValueKey = "panic_value" // the recovered value itself, for non-errors
+	PanicStackKey = "panic_stack" // string: goroutine stack at recovery
+)
+
+// RecoverErr converts a value returned by recover() into an ErrPanic error,
+// or returns nil for nil. If the value is an error (including a doterr error
+// or a runtime.Error), it becomes the trailing cause with its chain intact,
+// so errors.Is/As and ErrMeta keep working; any other value is attached
+// unchanged as PanicValueKey. Call it from the deferred function itself:
+//
+//	defer func() {
+//	  if perr := doterr.RecoverErr(recover()); perr != nil {
+//	    err = perr
+//	  }
+//	}()
+//
+// A value panicked by PanicErr is returned as the original error, unchanged.
+func RecoverErr(recovered any) error {
+	if recovered == nil {
+		return nil
+	}
+	//goland:noinspection GoTypeAssertionOnErrors
+	if p, ok := recovered.(*panicked); ok {
+		return p.err
+	}
+	parts := []any{ErrPanic,
+		PanicTypeKey, fmt.Sprintf("%T", recovered),
+		PanicStackKey, string(debug.Stack()),
+	}
+	cause, ok := recovered.(error)
+	if !ok {
+		return newErr(nil, 1, append(parts, PanicValueKey, recovered))
+	}
+	if isNilErr(cause) {
+		// A typed-nil error has no chain to preserve; keep the value instead.
+		return newErr(nil, 1, append(parts, PanicValueKey, recovered))
+	}
+	return newErr(nil, 1, append(parts, cause))
+}
+
+// PanicErr panics with err so that RecoverErr restores it intact, for code
+// that unwinds with panic/recover (parsers, routers) where the structured
+// error would otherwise be flattened to a string. The panic value is an
+// error wrapping err, so recoverers that use errors.Is/As also see the
+// chain. PanicErr does nothing for a nil err.
+func PanicErr(err error) {
+	if isNilErr(err) {
+		return
+	}
+	panic(&panicked{err: err})
+}
+
+// ErrsSoFarKey is attached by TrackErr: how many errors the request had
+// already produced before this one.
+const ErrsSoFarKey = "errors_so_far"
+
+// ErrBudgetExceeded marks the error returned by ErrBudget.Err once a request
+// has produced more errors than its budget allows.
+var ErrBudgetExceeded = errors.New("error budget exceeded")
+
+// ErrBudget counts the errors produced while handling one request, so
+// services can detect an error storm and bail early. Create one per request
+// with WithErrBudget and feed errors through TrackErr. A nil *ErrBudget is
+// valid and counts nothing. It is safe for concurrent use.
+type ErrBudget struct {
+	limit int64
+	count atomic.Int64
+}
+
+type errBudgetKey struct{}
+
+// WithErrBudget returns a child of ctx carrying a new ErrBudget that allows
+// limit errors; limit <= 0 means count without limit.
+func WithErrBudget(ctx context.Context, limit int) (context.Context, *ErrBudget) {
+	b := &ErrBudget{limit: int64(limit)}
+	return context.WithValue(ctx, errBudgetKey{}, b), b
+}
+
+// ErrBudgetFrom returns the ErrBudget carried by ctx, or nil if there is none.
+func ErrBudgetFrom(ctx context.Context) *ErrBudget {
+	b, _ := ctx.Value(errBudgetKey{}).(*ErrBudget)
+	return b
+}
+
+// TrackErr counts err against the ErrBudget in ctx and, if the request had
+// already produced errors, enriches err with ErrsSoFarKey. It returns err
+// unchanged when it is nil or ctx carries no budget, so it can wrap every
+// return: return doterr.TrackErr(ctx, err).
+func TrackErr(ctx context.Context, err error) error {
+	b := ErrBudgetFrom(ctx)
+	if err == nil || b == nil {
+		return err
+	}
+	prior := b.count.Add(1) - 1
+	if prior == 0 {
+		return err
+	}
+	return WithErr(err, ErrsSoFarKey, int(prior))
+}
+
+// Count returns the number of errors tracked so far.
+func (b *ErrBudget) Count() int {
+	if b == nil {
+		return 0
+	}
+	return int(b.count.Load())
+}
+
+// Exceeded reports whether more errors than the limit have been tracked.
+func (b *ErrBudget) Exceeded() bool {
+	return b != nil && b.limit > 0 && b.count.Load() > b.limit
+}
+
+// Err returns nil while the budget holds, and an ErrBudgetExceeded error with
+// "errors" and "limit" metadata once it is exceeded.
+func (b *ErrBudget) Err() error {
+	if !b.Exceeded() {
+		return nil
+	}
+	return newErr(nil, 1, []any{ErrBudgetExceeded, "errors", b.Count(), "limit", int(b.limit)})
+}
+
+// RedactedValue replaces sensitive metadata values when redaction is on.
+const RedactedValue = "[REDACTED]"
+
+// RedactionMode controls what happens when a sensitive metadata value (see
+// MarkSensitive) is emitted: rendered by Error() or by an encoder that calls
+// EmitValue. Only encoders report RedactAudit events; Error() renders too
+// often, in logs and %v formatting, for its output to be audited per call.
+type RedactionMode int32
+
+const (
+	// RedactOff emits sensitive values as they are; it is the default.
+	RedactOff RedactionMode = iota
+	// RedactOn replaces sensitive values with RedactedValue.
+	RedactOn
+	// RedactAudit emits sensitive values as they are but reports each
+	// emission by an encoder (see EmitValue) to the SetRedactionAuditHook
+	// hook, so redaction coverage can be verified before it is turned on in
+	// production.
+	RedactAudit
+)
+
+var (
+	redactionMode atomic.Int32
+	redactionHook atomic.Pointer[func(RedactionEvent)]
+	sensitiveMu   sync.RWMutex
+	sensitiveKeys = map[string]bool{}
+)
+
+// SetRedactionMode sets the RedactionMode and returns the previous one.
+func SetRedactionMode(m RedactionMode) RedactionMode {
+	return RedactionMode(redactionMode.Swap(int32(m)))
+}
+
+// MarkSensitive classifies metadata keys as sensitive, e.g. "email" or
+// "card_number". The returned func unmarks the keys this call marked; keys
+// that were already sensitive stay so.
+func MarkSensitive(keys ...string) (unmark func()) {
+	sensitiveMu.Lock()
+	defer sensitiveMu.Unlock()
+	var added []string
+	for _, k := range keys {
+		if !sensitiveKeys[k] {
+			sensitiveKeys[k] = true
+			added = append(added, k)
+		}
+	}
+	return func() {
+		sensitiveMu.Lock()
+		defer sensitiveMu.Unlock()
+		for _, k := range added {
+			delete(sensitiveKeys, k)
+		}
+	}
+}
+
+// IsSensitive reports whether key was classified with MarkSensitive.
+func IsSensitive(key string) bool {
+	sensitiveMu.RLock()
+	defer sensitiveMu.RUnlock()
+	return sensitiveKeys[key]
+}
+
+// sensitiveAmong reports, by index, which of kvs have sensitive keys, taking
+// the lock once for all of them.
+func sensitiveAmong(kvs []Pair) []bool {
+	sensitiveMu.RLock()
+	defer sensitiveMu.RUnlock()
+	marks := make([]bool, len(kvs))
+	for i, pair := range kvs {
+		marks[i] = sensitiveKeys[pair.k]
+	}
+	return marks
+}
+
+// RedactionEvent is one emission of a sensitive value in RedactAudit mode.
+// The value itself is deliberately not included.
+type RedactionEvent struct {
+	Emitter string // the encoder that emitted the value, e.g. "doterrslog"
+	Key     string
+}
+
+// SetRedactionAuditHook sets the hook called for every RedactionEvent in
+// RedactAudit mode and returns the previous one; nil removes it. Use a
+// RedactionReport's Record method to aggregate events into a report.
+func SetRedactionAuditHook(hook func(RedactionEvent)) func(RedactionEvent) {
+	var next *func(RedactionEvent)
+	if hook != nil {
+		next = &hook
+	}
+	prev := redactionHook.Swap(next)
+	if prev == nil {
+		return nil
+	}
+	return *prev
+}
+
+// EmitValue returns the value an encoder named emitter should output for
+// metadata key: v itself, or RedactedValue if key is sensitive and
+// redaction is on. In RedactAudit mode it reports the emission of a
+// sensitive key. Encoders outside doterr call it for every metadata value
+// they write.
+func EmitValue(emitter, key string, v any) any {
+	mode := RedactionMode(redactionMode.Load())
+	if mode == RedactOff || !IsSensitive(key) {
+		return v
+	}
+	if mode == RedactOn {
+		return RedactedValue
+	}
+	if hook := redactionHook.Load(); hook != nil {
+		RunHook("RedactionAuditHook", nil, func() { (*hook)(RedactionEvent{Emitter: emitter, Key: key}) })
+	}
+	return v
+}
+
+// RedactionSite is one emitter and key in a RedactionReport.
+type RedactionSite struct {
+	Emitter string
+	Key     string
+	Count   int
+}
+
+// RedactionReport aggregates RedactionEvents. The zero value is ready to use
+// and safe for concurrent use:
+//
+//	var report doterr.RedactionReport
+//	doterr.SetRedactionAuditHook(report.Record)
+//	doterr.SetRedactionMode(doterr.RedactAudit)
+type RedactionReport struct {
+	mu     sync.Mutex
+	counts map[RedactionEvent]int
+}
+
+// Record adds e to the report.
+func (r *RedactionReport) Record(e RedactionEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.counts == nil {
+		r.counts = make(map[RedactionEvent]int)
+	}
+	r.counts[e]++
+}
+
+// Sites returns the emitters and keys recorded so far, most frequent first.
+func (r *RedactionReport) Sites() []RedactionSite {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	sites := make([]RedactionSite, 0, len(r.counts))
+	for e, n := range r.counts {
+		sites = append(sites, RedactionSite{Emitter: e.Emitter, Key: e.Key, Count: n})
+	}
+	slices.SortFunc(sites, func(a, b RedactionSite) int {
+		if a.Count != b.Count {
+			return b.Count - a.Count
+		}
+		if c := strings.Compare(a.Emitter, b.Emitter); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Key, b.Key)
+	})
+	return sites
+}
+
+// ErrHookFailed marks a failure of a user-registered hook, reporter or
+// formatter (an Enricher, audit hook, AggregateRenderer, NewSentinelFunc
+// predicate, or one run with RunHook), delivered to the hook error handler
+// rather than swallowed; see SetHookErrHandler.
+var ErrHookFailed = errors.New("doterr hook failed")
+
+// Metadata keys on ErrHookFailed errors. A panic is recorded with the
+// RecoverErr keys PanicTypeKey and PanicValueKey (or as the cause if it is an
+// error).
+const (
+	HookKey            = "hook"        // name of the failed hook, e.g. "Enricher"
+	HookFingerprintKey = "fingerprint" // ErrFingerprint of the error the hook was handling, if any
+)
+
+// Metadata keys doterr adds to ErrHookFailed errors when they apply.
+const (
+	HookDepthKey      = "hook_depth" // int, hook failures the reported one happened while handling
+	HookSuppressedKey = "suppressed" // int, failures of the same hook dropped by the rate limit since the last report
+)
+
+// maxHookErrDepth bounds a chain of hook failures, each raised while handling
+// the report of the one before, so a hook that fails on hook failures cannot
+// recurse without end.
+const maxHookErrDepth = 16
+
+// Each hook name may report hookErrBurst failures at once, then one per
+// hookErrInterval, so a broken Enricher does not report on every NewErr.
+const (
+	hookErrBurst    = 10
+	hookErrInterval = time.Second
+)
+
+// hookErrLimit is the rate-limit state of one hook name.
+type hookErrLimit struct {
+	tokens     float64
+	last       time.Time
+	suppressed int
+}
+
+var (
+	hookErrHandler atomic.Pointer[func(error)]
+	hookErrMu      sync.Mutex
+	hookErrLimits  = make(map[string]*hookErrLimit) // by hook name
+)
+
+// SetHookErrHandler sets the function that receives ErrHookFailed errors and
+// returns the previous one. The default writes them to standard error; nil
+// restores it, and a func that does nothing silences them. The handler must
+// be safe for concurrent use and must not panic.
+//
+// Reports are rate-limited per hook name: past a burst of 10, one a second
+// is delivered, carrying HookSuppressedKey with the count dropped since the
+// last one.
+func SetHookErrHandler(fn func(error)) func(error) {
+	var next *func(error)
+	if fn != nil {
+		next = &fn
+	}
+	prev := hookErrHandler.Swap(next)
+	if prev == nil {
+		return writeHookErr
+	}
+	return *prev
+}
+
+// RunHook runs fn, a hook named name handling subject (which may be nil),
+// and reports a panic in fn to the hook error handler as an ErrHookFailed
+// error instead of letting it unwind. It reports whether fn returned
+// normally. Packages built on doterr use it to run their own callbacks.
+func RunHook(name string, subject error, fn func()) (ok bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			reportHookErr(name, subject, r)
+			ok = false
+		}
+	}()
+	fn()
+	return true
+}
+
+// GroupKey derives the category of an error for GroupBy.
+type GroupKey func(error) string
+
+// GroupBy buckets errs by key, e.g. to summarize a batch's failures per
+// category; nil errors are skipped and each bucket keeps input order:
+//
+//	for code, errs := range doterr.GroupBy(failures, doterr.ByCode) {
+//	    log.Printf("%s: %d failures", code, len(errs))
+//	}
+func GroupBy(errs []error, key GroupKey) map[string][]error {
+	groups := make(map[string][]error)
+	for _, err := range errs {
+		if isNilErr(err) {
+			continue
+		}
+		k := key(err)
+		groups[k] = append(groups[k], err)
+	}
+	return groups
+}
+
+// BySentinel groups by the message of an error's first sentinel (see
+// ErrSentinels), or by err.Error() if it has none.
+func BySentinel(err error) string {
+	sentinels := ErrSentinels(err)
+	if len(sentinels) == 0 {
+		return err.Error()
+	}
+	return sentinels[0].Error()
+}
+
+// ByCode groups by registered code (see ErrCode); "" collects the rest.
+func ByCode(err error) string { return ErrCode(err) }
+
+// ByFingerprint groups by ErrFingerprint.
+func ByFingerprint(err error) string { return ErrFingerprint(err) }
+
+// ByMetaKey returns a GroupKey grouping by the value of metadata key (see
+// ErrValue) formatted with %v; "" collects errors without the key.
+func ByMetaKey(key string) GroupKey {
+	return func(err error) string {
+		v, ok := ErrValue[any](err, key)
+		if !ok {
+			return ""
+		}
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+// Metadata keys read by Summarize for text meant for end users.
+const (
+	UserMessageKey = "user_message" // string shown in place of the technical message
+	HintKey        = "hint"         // string suggesting a remedy; may appear on several entries
+)
+
+// ErrSummary is a detached, serializable view of an error for template
+// engines, API serializers and frontend clients. It holds no error values,
+// so it is safe to hand out. Its Meta and Hints make it incomparable with
+// ==; compare summaries with reflect.DeepEqual or field by field.
+type ErrSummary struct {
+	Code        string         `json:"code,omitempty"`
+	Message     string         `json:"message"`
+	UserMessage string         `json:"user_message,omitempty"`
+	Meta        map[string]any `json:"meta,omitempty"`
+	Retryable   bool           `json:"retryable"`
+	Hints       []string       `json:"hints,omitempty"`
+}
+
+// InternalErrMessage is the ErrSummary Message of an error without
+// sentinels, whose own text is not assumed safe to show.
+const InternalErrMessage = "internal error"
+
+// Summarize returns the ErrSummary of err, or the zero ErrSummary for nil.
+// Message joins the messages of err's sentinels with "; " (InternalErrMessage
+// if it has none), so neither metadata values nor the text of foreign errors
+// leak into it. UserMessage is the outermost UserMessageKey value and Hints
+// every distinct HintKey value, in order. Meta holds the outermost value of
+// every other key except those marked with MarkSensitive and the
+// diagnostics CallerKey, TimeKey and PanicStackKey.
+func Summarize(err error) ErrSummary {
+	if err == nil {
+		return ErrSummary{}
+	}
+	sum := ErrSummary{
+		Code:      ErrCode(err),
+		Retryable: IsRetryable(err),
+	}
+	var msgs []string
+	for _, s := range ErrSentinels(err) {
+		if !isComposite(s) {
+			msgs = append(msgs, s.Error())
+		}
+	}
+	sum.Message = strings.Join(msgs, "; ")
+	if len(msgs) == 0 {
+		sum.Message = InternalErrMessage
+	}
+	walkEntries(err, func(e entry) {
+		for _, pair := range e.kvs {
+			switch {
+			case pair.k == HintKey:
+				if h, ok := pair.v.(string); ok && !slices.Contains(sum.Hints, h) {
+					sum.Hints = append(sum.Hints, h)
+				}
+			case pair.k == UserMessageKey:
+				if m, ok := pair.v.(string); ok && sum.UserMessage == "" {
+					sum.UserMessage = m
+				}
+			case IsSensitive(pair.k), pair.k == CallerKey, pair.k == TimeKey, pair.k == PanicStackKey:
+			default:
+				if _, seen := sum.Meta[pair.k]; seen {
+					continue
+				}
+				if sum.Meta == nil {
+					sum.Meta = make(map[string]any)
+				}
+				sum.Meta[pair.k] = pair.v
+			}
+		}
+	})
+	return sum
+}
+
+//--------------------------------
+// Unexported implementation types
+//--------------------------------
+
+// diagKV is a KV retained only at or above its diagnostic level; see Diag.
+type diagKV struct {
+	Pair
+	level DiagLevel
+}
+
+var uniqueId = rand.Int()
+
+// entry represents one function's contribution to an error chain.
+// Each function creates one entry with errors (sentinels, custom typed errors) and metadata.
+// It implements error and Unwrap() []error.
+type entry struct {
+	id      int     // Unique ID
+	errors  []error // sentinels, custom typed errors (NOT the primary cause)
+	kvs     []Pair  // metadata in insertion order
+	adopted bool    // read-only copy of a foreign entry; never enriched
+	cause   error   // trailing cause joined after the entry, for matches
+}
+
+func newEntry(errors []error, kvs []Pair) *entry {
+	return &entry{
+		id:     uniqueId,
+		errors: errors,
+		kvs:    kvs,
+	}
+}
+
+func (e entry) Error() string {
+	if len(e.errors) == 0 && len(e.kvs) == 0 {
+		return "doterr{}"
+	}
+
+	var parts []string
+
+	// Include sentinel errors first
+	for _, err := range e.errors {
+		parts = append(parts, err.Error())
+	}
+
+	// Then include metadata. The redaction state is read once per render,
+	// and rendering reports no RedactAudit events; those come from encoders
+	// via EmitValue.
+	var redacted []bool
+	if RedactionMode(redactionMode.Load()) == RedactOn {
+		redacted = sensitiveAmong(e.kvs)
+	}
+	meta := "meta:"
+	for i, pair := range e.kvs {
+		v := pair.v
+		if redacted != nil && redacted[i] {
+			v = RedactedValue
+		}
+		meta += " " + fmt.Sprintf("%s=%v", pair.k, v)
+	}
+	if meta != "meta:" {
+		parts = append(parts, meta)
+	}
+	msg := strings.Join(parts, "; ")
+	if codePrefix.Load() {
+		if code := e.code(); code != "" {
+			msg = "[" + code + "] " + msg
+		}
+	}
+	return msg
+}
+
+func (e entry) Unwrap() []error {
+	if len(e.errors) == 0 {
+		return nil
+	}
+	cp := make([]error, len(e.errors))
+	copy(cp, e.errors)
+	return cp
+}
+
+// Is reports whether target is a Sentinel whose match function accepts e
+// (see NewSentinelFunc) or an old sentinel aliased (see AliasSentinel) to one
+// of e's sentinels; errors.Is already handles direct matches.
+func (e entry) Is(target error) bool {
+	//goland:noinspection GoTypeAssertionOnErrors
+	if s, ok := target.(*Sentinel); ok && s.match != nil && e.matches(s.match) {
+		return true
+	}
+	if !hashableErr(target) {
+		return false
+	}
+	aliasMu.RLock()
+	defer aliasMu.RUnlock()
+	if len(aliases) == 0 {
+		return false
+	}
+	return e.hasAlias(target, nil)
+}
+
+// matches reports whether match accepts e, or any error in the trees of its
+// sentinels or of its trailing cause. A panicking match is reported as a hook
+// failure and does not match.
+func (e entry) matches(match func(error) bool) (found bool) {
+	RunHook("SentinelFunc", e, func() {
+		found = match(e) ||
+			slices.ContainsFunc(e.errors, func(s error) bool { return anyErr(s, match) }) ||
+			e.cause != nil && anyErr(e.cause, match)
+	})
+	return found
+}
+
+// anyErr reports whether fn accepts err or any error it wraps, depth-first.
+func anyErr(err error, fn func(error) bool) bool {
+	if err == nil {
+		return false
+	}
+	if fn(err) {
+		return true
+	}
+	switch u := err.(type) {
+	case interface{ Unwrap() []error }:
+		return slices.ContainsFunc(u.Unwrap(), func(kid error) bool { return anyErr(kid, fn) })
+	case interface{ Unwrap() error }:
+		return anyErr(u.Unwrap(), fn)
+	}
+	return false
+}
+
+// hasAlias walks the replacements of old, guarding against alias cycles.
+// Caller must hold aliasMu.
+func (e entry) hasAlias(old error, seen []error) bool {
+	for _, replacement := range aliases[old] {
+		if containsErr(e.errors, replacement) {
+			return true
+		}
+		if containsErr(seen, replacement) {
+			continue
+		}
+		if e.hasAlias(replacement, append(seen, old)) {
+			return true
+		}
+	}
+	return false
+}
+
+// code returns the code of e's first registered sentinel, or "".
+func (e entry) code() string {
+	for _, s := range e.errors {
+		if info, ok := LookupSentinel(s); ok {
+			return info.Code
+		}
+	}
+	return ""
+}
+
+func (e entry) DoterrID() int { return e.id }
+
+func (e entry) DoterrSentinels() []error {
+	return slices.Clone(e.errors)
+}
+
+func (e entry) DoterrMeta() (keys []string, values []any) {
+	keys = make([]string, len(e.kvs))
+	values = make([]any, len(e.kvs))
+	for i, pair := range e.kvs {
+		keys[i], values[i] = pair.k, pair.v
+	}
+	return keys, values
+}
+
+func (e entry) empty() bool { return len(e.errors) == 0 && len(e.kvs) == 0 }
+
 func appendEntry(e *entry, parts ...any) {
 	for i := 0; i < len(parts); {
 		switch v := parts[i].(type) {
 		case KV:
-			// Convert interface to internal kv
-			e.kvs = append(e.kvs, kv{k: v.Key(), v: v.Value()})
+			// Convert interface to a Pair, leaving out dormant Diag pairs
+			if d, isDiag := v.(diagKV); !isDiag || DiagEnabled(d.level) {
+				e.kvs = append(e.kvs, Pair{k: v.Key(), v: v.Value()})
+			}
 			i++
 		case string:
 			if i+1 < len(parts) {
-				e.kvs = append(e.kvs, kv{k: v, v: parts[i+1]})
+				e.kvs = append(e.kvs, Pair{k: v, v: parts[i+1]})
 				i += 2
 			} else {
 				// Trailing key without value: skip it (validation should have caught this)
 				i++
 			}
 		case error:
-			if v != nil {
+			if !isNilErr(v) {
 				e.errors = append(e.errors, v) // sentinel/tag
 			}
 			i++
@@ -424,16 +2866,41 @@ func appendEntry(e *entry, parts ...any) {
 }
 
 // combined implements a composite error for Combine().
-type combined struct{ errs []error }
+type combined struct {
+	errs   []error
+	render AggregateRenderer // nil for AggregateFull
+}
+
+// Both aggregate types implement the Go 1.20 multi-unwrap contract, so
+// errors.Is/As and third-party tools traverse them exactly like the result
+// of errors.Join. Each Unwrap returns a fresh copy that callers may modify.
+var (
+	_ interface{ Unwrap() []error } = entry{}
+	_ interface{ Unwrap() []error } = combined{}
+	_ ErrNode                       = entry{}
+)
 
 func (c combined) Error() string {
-	var messages []string
+	members := c.members()
+	if c.render == nil {
+		return AggregateFull()(members)
+	}
+	var out string
+	if !RunHook("AggregateRenderer", c, func() { out = c.render(members) }) {
+		return AggregateFull()(members)
+	}
+	return out
+}
+
+// members returns the non-nil errors of c.
+func (c combined) members() []error {
+	out := make([]error, 0, len(c.errs))
 	for _, err := range c.errs {
 		if err != nil {
-			messages = append(messages, err.Error())
+			out = append(out, err)
 		}
 	}
-	return strings.Join(messages, "\n")
+	return out
 }
 
 func (c combined) Unwrap() []error {
@@ -442,16 +2909,158 @@ func (c combined) Unwrap() []error {
 	return cp
 }
 
+func (r *sentinelRegistry) add(info SentinelInfo) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if i, ok := r.bySentinel[info.Sentinel]; ok {
+		existing := r.infos[i]
+		if existing.Code == info.Code && existing.Name == info.Name {
+			return nil
+		}
+		return NewErr(ErrDuplicateSentinel,
+			"code", info.Code,
+			"name", info.Name,
+			"existing", existing.Code,
+		)
+	}
+	if i, ok := r.byCode[info.Code]; ok {
+		return NewErr(ErrDuplicateCode,
+			"code", info.Code,
+			"name", info.Name,
+			"existing", r.infos[i].Name,
+		)
+	}
+	if i, ok := r.byName[info.Name]; ok {
+		return NewErr(ErrDuplicateName,
+			"code", info.Code,
+			"name", info.Name,
+			"existing", r.infos[i].Code,
+		)
+	}
+	r.infos = append(r.infos, info)
+	i := len(r.infos) - 1
+	r.bySentinel[info.Sentinel] = i
+	r.byCode[info.Code] = i
+	r.byName[info.Name] = i
+	return nil
+}
+
+// panicked is the panic value of PanicErr.
+type panicked struct{ err error }
+
+func (p *panicked) Error() string { return p.err.Error() }
+func (p *panicked) Unwrap() error { return p.err }
+
+// reportHookErr delivers an ErrHookFailed error for the hook named name to
+// the hook error handler, recording recovered, if non-nil, as a panic. The
+// error is built directly, without origin or Enricher metadata, so a failing
+// Enricher cannot fail again while being reported. Its depth is carried by
+// the errors the hook was handling: one more than any hook failure among
+// subject and recovered.
+func reportHookErr(name string, subject error, recovered any, extra ...Pair) {
+	depth := hookErrDepthOf(subject)
+	if err, ok := recovered.(error); ok {
+		depth = max(depth, hookErrDepthOf(err))
+	}
+	if depth > maxHookErrDepth {
+		return
+	}
+	suppressed, ok := allowHookErr(name)
+	if !ok {
+		return
+	}
+	kvs := []Pair{{k: HookKey, v: name}}
+	if subject != nil {
+		kvs = append(kvs, Pair{k: HookFingerprintKey, v: ErrFingerprint(subject)})
+	}
+	if depth > 0 {
+		kvs = append(kvs, Pair{k: HookDepthKey, v: depth})
+	}
+	if suppressed > 0 {
+		kvs = append(kvs, Pair{k: HookSuppressedKey, v: suppressed})
+	}
+	kvs = append(kvs, extra...)
+	var cause error
+	if recovered != nil {
+		kvs = append(kvs, Pair{k: PanicTypeKey, v: fmt.Sprintf("%T", recovered)})
+		if err, ok := recovered.(error); ok && !isNilErr(err) {
+			cause = err
+		} else {
+			kvs = append(kvs, Pair{k: PanicValueKey, v: recovered})
+		}
+	}
+	handler := writeHookErr
+	if p := hookErrHandler.Load(); p != nil {
+		handler = *p
+	}
+	handler(handleCause(*newEntry([]error{ErrHookFailed}, kvs), cause))
+}
+
+// hookErrDepthOf returns how many hook failures deep err is: 0 if it is not
+// a hook failure, else one more than its HookDepthKey.
+func hookErrDepthOf(err error) int {
+	if isNilErr(err) || !errors.Is(err, ErrHookFailed) {
+		return 0
+	}
+	depth, _ := ErrValue[int](err, HookDepthKey)
+	return depth + 1
+}
+
+// allowHookErr takes a token from the rate limit of the hook named name. On
+// success it returns, and resets, the number of reports suppressed since the
+// last allowed one.
+func allowHookErr(name string) (suppressed int, ok bool) {
+	hookErrMu.Lock()
+	defer hookErrMu.Unlock()
+	now := time.Now()
+	l := hookErrLimits[name]
+	if l == nil {
+		l = &hookErrLimit{tokens: hookErrBurst, last: now}
+		hookErrLimits[name] = l
+	}
+	l.tokens = min(hookErrBurst, l.tokens+float64(now.Sub(l.last))/float64(hookErrInterval))
+	l.last = now
+	if l.tokens < 1 {
+		l.suppressed++
+		return 0, false
+	}
+	l.tokens--
+	suppressed, l.suppressed = l.suppressed, 0
+	return suppressed, true
+}
+
+// writeHookErr is the default hook error handler.
+func writeHookErr(err error) {
+	_, _ = fmt.Fprintf(os.Stderr, "doterr: %v\n", err)
+}
+
+// sealed marks an error as final; see Seal().
+type sealed struct{ err error }
+
+func (s *sealed) Error() string { return s.err.Error() }
+func (s *sealed) Unwrap() error { return s.err }
+
 //------------------------
 // Unexported helper funcs
 //------------------------
 
+// unseal returns the error wrapped by Seal(), or err itself if not sealed.
+func unseal(err error) error {
+	//goland:noinspection GoTypeAssertionOnErrors
+	s, ok := err.(*sealed)
+	if ok {
+		return s.err
+	}
+	return err
+}
+
 // extractTrailingCause checks if the last element in parts is an error that should
 // be treated as a trailing cause. Returns (cause, remaining parts).
 // A trailing error is considered a cause if:
 //   - It's the last element, AND
 //   - It comes after at least one sentinel, AND
-//   - It's not part of an incomplete key-value pair (would leave odd count)
+//   - It's not part of an incomplete key-value pair (would leave odd count);
+//     an explicit KV counts as a complete pair on its own
 func extractTrailingCause(parts []any) (error, []any) {
 	if len(parts) == 0 {
 		return nil, parts
@@ -459,7 +3068,7 @@ func extractTrailingCause(parts []any) (error, []any) {
 
 	lastIdx := len(parts) - 1
 	lastErr, isErr := parts[lastIdx].(error)
-	if !isErr {
+	if !isErr && parts[lastIdx] != nil {
 		return nil, parts
 	}
 
@@ -483,14 +3092,25 @@ func extractTrailingCause(parts []any) (error, []any) {
 	}
 
 	// Check if removing the last error would leave an odd number of non-sentinel args
-	// (which would mean the error is actually a value for a key)
-	nonSentinelCount := len(parts) - sentinelCount
-	if (nonSentinelCount-1)%2 != 0 {
+	// (which would mean the error is actually a value for a key). An explicit KV
+	// stands for a whole pair, so it counts as two.
+	nonSentinelCount := 0
+	for _, part := range parts[sentinelCount:lastIdx] {
+		if _, isKV := part.(KV); isKV {
+			nonSentinelCount += 2
+			continue
+		}
+		nonSentinelCount++
+	}
+	if nonSentinelCount%2 != 0 {
 		// Removing last error leaves odd count - it's a value, not a cause
 		return nil, parts
 	}
 
-	// Last error is a trailing cause
+	// Last error is a trailing cause; a nil one (typed or not) is dropped.
+	if isNilErr(lastErr) {
+		return nil, parts[:lastIdx]
+	}
 	return lastErr, parts[:lastIdx]
 }
 
@@ -502,105 +3122,402 @@ func extractTrailingCause(parts []any) (error, []any) {
 func validateNewParts(parts []any) error {
 	if len(parts) == 0 {
 		// Build entry manually to avoid recursion
-		e := newEntry([]error{ErrMissingSentinel}, []kv{
+		e := newEntry([]error{ErrMissingSentinel}, []Pair{
 			{k: "message", v: "doterr.New requires at least one sentinel error"},
 		})
 		return e
 	}
 
-	// Find where sentinels end (first non-error)
-	sentinelCount := 0
-	i := 0
-	for i < len(parts) {
-		if _, ok := parts[i].(error); ok {
-			sentinelCount++
-			i++
-		} else {
-			break
+	// Find where sentinels end (first non-error)
+	sentinelCount := 0
+	i := 0
+	for i < len(parts) {
+		if _, ok := parts[i].(error); ok {
+			sentinelCount++
+			i++
+		} else {
+			break
+		}
+	}
+
+	// Must have at least one sentinel
+	if sentinelCount == 0 {
+		// Build entry manually to avoid recursion
+		e := newEntry([]error{ErrMissingSentinel}, []Pair{
+			{k: "message", v: "doterr.New requires at least one sentinel error as the first argument"},
+		})
+		return e
+	}
+
+	// Validate remaining args are valid key-value pairs
+	firstKeyIdx := -1
+	for j := i; j < len(parts); j++ {
+		switch v := parts[j].(type) {
+		case KV:
+			// Explicit KV is always valid
+			continue
+		case string:
+			// Mark first key position if not yet found
+			if firstKeyIdx == -1 {
+				firstKeyIdx = j
+			}
+			// After first key, must have even number of remaining args
+			if j+1 >= len(parts) {
+				// Build entry manually to avoid recursion
+				e := newEntry([]error{ErrTrailingKey}, []Pair{
+					{k: "key", v: v},
+					{k: "position", v: j},
+				})
+				return e
+			}
+			j++ // Skip the value
+		case error:
+			// Errors after sentinels are not allowed
+			// Build entry manually to avoid recursion
+			e := newEntry([]error{ErrMisplacedError}, []Pair{
+				{k: "position", v: j},
+				{k: "message", v: "errors must be first"},
+			})
+			return e
+		default:
+			// Non-string, non-error, non-KV values are not allowed
+			// Build entry manually to avoid recursion
+			e := newEntry([]error{ErrInvalidArgumentType}, []Pair{
+				{k: "type", v: fmt.Sprintf("%T", v)},
+				{k: "position", v: j},
+				{k: "message", v: "only error, KV, or string keys allowed"},
+			})
+			return e
+		}
+	}
+
+	// If we found a first key, check that remaining count is even
+	if firstKeyIdx >= 0 {
+		remaining := len(parts) - firstKeyIdx
+		if remaining%2 != 0 {
+			// Build entry manually to avoid recursion
+			e := newEntry([]error{ErrOddKeyValueCount}, []Pair{
+				{k: "position", v: firstKeyIdx},
+				{k: "remaining_count", v: remaining},
+			})
+			return e
+		}
+	}
+
+	return nil
+}
+
+// buildEntry creates an entry from parts without validation.
+// Used internally by WithErr where sentinels are optional.
+// Returns nil if parts are empty or result in an empty entry.
+func buildEntry(parts ...any) error {
+	if len(parts) == 0 {
+		return nil
+	}
+	var e entry
+	e.id = uniqueId
+	appendEntry(&e, parts...)
+	if e.empty() {
+		return nil
+	}
+	return e
+}
+
+// withErr implements WithErr, WithCause and WithBase once base, middle and
+// cause have been told apart. Nil and typed-nil base and cause are absent.
+// o is recorded on a freshly built entry (never merged into an existing one).
+func withErr(base error, middle []any, cause error, o origin) error {
+	if isNilErr(base) {
+		base = nil
+	}
+	if base != nil {
+		if hasSealed(base) {
+			// Sealed errors are final: report the attempt, change nothing.
+			return sealedAttempt(base)
+		}
+		base = checkCrossPackage(base)
+	}
+	if isNilErr(cause) {
+		cause = nil
+	}
+	if cause != nil {
+		cause = summarizeCause(checkCrossPackage(cause))
+	}
+
+	// No base error: build entry from middle, then (if present) join cause LAST.
+	if base == nil {
+		return handleCause(buildEntry(withOrigin(middle, o)...), cause)
+	}
+
+	// Have a base error: try to enrich rightmost entry or join a fresh entry.
+	err := buildErr(base, middle, o)
+
+	// Now handle the cause
+	return handleCause(err, cause)
+}
+
+// hasSealed reports whether err is, or holds outside any entry, a sealed
+// error: the errors WithErr must not enrich.
+func hasSealed(err error) bool {
+	//goland:noinspection GoTypeAssertionOnErrors
+	switch u := err.(type) {
+	case *sealed:
+		return true
+	case ErrNode:
+		return false // enriching an entry never touches the errors it holds
+	case interface{ Unwrap() []error }:
+		for _, kid := range u.Unwrap() {
+			if kid != nil && hasSealed(kid) {
+				return true
+			}
+		}
+	case interface{ Unwrap() error }:
+		return hasSealed(u.Unwrap())
+	}
+	return false
+}
+
+// sealedAttempt returns base, which holds a sealed error, joined behind a
+// read-only ErrSealed entry, or base itself if it already leads with one.
+func sealedAttempt(base error) error {
+	//goland:noinspection GoTypeAssertionOnErrors
+	if u, ok := base.(interface{ Unwrap() []error }); ok {
+		kids := u.Unwrap()
+		//goland:noinspection GoTypeAssertionOnErrors
+		if len(kids) > 0 {
+			if e, ok := kids[0].(entry); ok && e.adopted && containsErr(e.errors, ErrSealed) {
+				return base
+			}
+		}
+	}
+	return errors.Join(entry{id: uniqueId, errors: []error{ErrSealed}, adopted: true}, base)
+}
+
+// ambiguousErrs reports whether parts are two or more errors and nothing else.
+func ambiguousErrs(parts []any) bool {
+	if len(parts) < 2 {
+		return false
+	}
+	for _, part := range parts {
+		if _, ok := part.(error); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// callerAt returns "dir/file.go:line" for the frame skip levels above the
+// function calling callerAt, or "" when caller capture is disabled.
+func callerAt(skip int) string {
+	if !captureCaller.Load() {
+		return ""
+	}
+	_, file, line, ok := runtime.Caller(skip + 1) // +1 for callerAt itself
+	if !ok {
+		return ""
+	}
+	return shortFile(file) + ":" + strconv.Itoa(line)
+}
+
+// withBasePart returns parts led by base, or parts itself when base is nil.
+func withBasePart(base error, parts []any) []any {
+	if base == nil {
+		return parts
+	}
+	return append([]any{base}, parts...)
+}
+
+// renderSome renders shown on one line, followed by the count of the
+// remaining members of an aggregate of total.
+func renderSome(shown []error, total int) string {
+	messages := make([]string, len(shown))
+	for i, err := range shown {
+		messages[i] = strings.ReplaceAll(err.Error(), "\n", "; ")
+	}
+	out := strings.Join(messages, "; ")
+	switch rest := total - len(shown); rest {
+	case 0:
+	case 1:
+		out += " (and 1 more error)"
+	default:
+		out += " (and " + strconv.Itoa(rest) + " more errors)"
+	}
+	return out
+}
+
+// isCodeRune reports whether r may appear in a code parsed by ParseCode.
+func isCodeRune(r rune) bool {
+	return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-' || r == '.'
+}
+
+// shortFile trims a source path to its last directory and file name.
+func shortFile(file string) string {
+	if i := strings.LastIndexByte(file, '/'); i >= 0 {
+		if j := strings.LastIndexByte(file[:i], '/'); j >= 0 {
+			return file[j+1:]
+		}
+	}
+	return file
+}
+
+// origin is what a constructor records on the entries it creates besides
+// its parts: the call site, when caller capture is enabled, and the context
+// given to the Enricher.
+type origin struct {
+	caller string
+	ctx    context.Context
+}
+
+// originAt returns the origin for the frame skip levels above the function
+// calling originAt.
+func originAt(ctx context.Context, skip int) origin {
+	return origin{caller: callerAt(skip + 1), ctx: ctx}
+}
+
+// kvs returns the caller, time and Enricher pairs for a new entry,
+// leaving out Enricher keys for which has reports true.
+func (o origin) kvs(has func(string) bool) []Pair {
+	var out []Pair
+	if o.caller != "" {
+		out = append(out, Pair{k: CallerKey, v: o.caller})
+	}
+	if captureTime.Load() {
+		out = append(out, Pair{k: TimeKey, v: time.Now()})
+	}
+	en := enricher.Load()
+	if en == nil {
+		return out
+	}
+	ctx := o.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	var pairs []any
+	RunHook("Enricher", nil, func() { pairs = (*en).Enrich(ctx) })
+	if len(pairs)%2 != 0 {
+		reportHookErr("Enricher", nil, nil, Pair{k: "reason", v: "odd number of parts"}, Pair{k: "count", v: len(pairs)})
+	}
+	for i := 0; i+1 < len(pairs); i += 2 {
+		k, ok := pairs[i].(string)
+		if !ok || k == "" {
+			reportHookErr("Enricher", nil, nil, Pair{k: "reason", v: "invalid key"}, Pair{k: "index", v: i})
+			continue
 		}
+		if has(k) {
+			continue
+		}
+		out = append(out, Pair{k: k, v: pairs[i+1]})
 	}
+	return out
+}
 
-	// Must have at least one sentinel
-	if sentinelCount == 0 {
-		// Build entry manually to avoid recursion
-		e := newEntry([]error{ErrMissingSentinel}, []kv{
-			{k: "message", v: "doterr.New requires at least one sentinel error as the first argument"},
-		})
-		return e
+// addOrigin records o on e.
+func addOrigin(e *entry, o origin) {
+	e.kvs = append(e.kvs, o.kvs(func(k string) bool {
+		return slices.ContainsFunc(e.kvs, func(p Pair) bool { return p.k == k })
+	})...)
+}
+
+// withOrigin returns middle plus the pairs recording o, if middle would
+// otherwise build a non-empty entry.
+func withOrigin(middle []any, o origin) []any {
+	if len(middle) == 0 {
+		return middle
+	}
+	keys := partKeys(middle)
+	extra := o.kvs(func(k string) bool { return slices.Contains(keys, k) })
+	if len(extra) == 0 {
+		return middle
 	}
+	middle = slices.Clip(middle)
+	for _, p := range extra {
+		middle = append(middle, p)
+	}
+	return middle
+}
 
-	// Validate remaining args are valid key-value pairs
-	firstKeyIdx := -1
-	for j := i; j < len(parts); j++ {
-		switch v := parts[j].(type) {
+// partKeys returns the metadata keys in constructor parts: those of KVs and
+// of "key", value pairs.
+func partKeys(parts []any) []string {
+	var keys []string
+	for i := 0; i < len(parts); i++ {
+		switch p := parts[i].(type) {
 		case KV:
-			// Explicit KV is always valid
-			continue
+			keys = append(keys, p.Key())
 		case string:
-			// Mark first key position if not yet found
-			if firstKeyIdx == -1 {
-				firstKeyIdx = j
-			}
-			// After first key, must have even number of remaining args
-			if j+1 >= len(parts) {
-				// Build entry manually to avoid recursion
-				e := newEntry([]error{ErrTrailingKey}, []kv{
-					{k: "key", v: v},
-					{k: "position", v: j},
-				})
-				return e
-			}
-			j++ // Skip the value
-		case error:
-			// Errors after sentinels are not allowed
-			// Build entry manually to avoid recursion
-			e := newEntry([]error{ErrMisplacedError}, []kv{
-				{k: "position", v: j},
-				{k: "message", v: "errors must be first"},
-			})
-			return e
-		default:
-			// Non-string, non-error, non-KV values are not allowed
-			// Build entry manually to avoid recursion
-			e := newEntry([]error{ErrInvalidArgumentType}, []kv{
-				{k: "type", v: fmt.Sprintf("%T", v)},
-				{k: "position", v: j},
-				{k: "message", v: "only error, KV, or string keys allowed"},
-			})
-			return e
+			keys = append(keys, p)
+			i++
 		}
 	}
+	return keys
+}
 
-	// If we found a first key, check that remaining count is even
-	if firstKeyIdx >= 0 {
-		remaining := len(parts) - firstKeyIdx
-		if remaining%2 != 0 {
-			// Build entry manually to avoid recursion
-			e := newEntry([]error{ErrOddKeyValueCount}, []kv{
-				{k: "position", v: firstKeyIdx},
-				{k: "remaining_count", v: remaining},
-			})
-			return e
+// summarizeCause returns cause, wrapped to render as a summary should its
+// message exceed the SetCauseSizeLimit limit in effect now.
+func summarizeCause(cause error) error {
+	limit := causeSizeLimit.Load()
+	if limit <= 0 || cause == nil {
+		return cause
+	}
+	return &summarized{cause: cause, limit: int(limit)}
+}
+
+// summarized is a cause under SetCauseSizeLimit. Its message is measured on
+// first use rather than at construction; if it is over the limit, the error
+// renders as the summaryEntry and unwraps to it ahead of the cause.
+type summarized struct {
+	cause error
+	limit int
+	once  sync.Once
+	stand *entry // nil if the message is within the limit
+}
+
+func (s *summarized) summary() *entry {
+	s.once.Do(func() {
+		msg := s.cause.Error()
+		if len(msg) > s.limit {
+			stand := summaryEntry(s.cause, msg)
+			s.stand = &stand
 		}
+	})
+	return s.stand
+}
+
+func (s *summarized) Error() string {
+	if stand := s.summary(); stand != nil {
+		return stand.Error()
 	}
+	return s.cause.Error()
+}
 
-	return nil
+func (s *summarized) Unwrap() []error {
+	if stand := s.summary(); stand != nil {
+		return []error{*stand, s.cause}
+	}
+	return []error{s.cause}
 }
 
-// buildEntry creates an entry from parts without validation.
-// Used internally by WithErr where sentinels are optional.
-// Returns nil if parts are empty or result in an empty entry.
-func buildEntry(parts ...any) error {
-	if len(parts) == 0 {
-		return nil
+// summaryEntry is the entry standing in for cause, whose message is msg: its
+// doterr sentinels and the CauseTypeKey, CauseHashKey, CauseCodeKey and
+// CauseSizeKey metadata.
+func summaryEntry(cause error, msg string) entry {
+	var sentinels []error
+	for _, s := range ErrSentinels(cause) {
+		if !isComposite(s) {
+			sentinels = append(sentinels, s)
+		}
 	}
-	var e entry
-	e.id = uniqueId
-	appendEntry(&e, parts...)
-	if e.empty() {
-		return nil
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(msg))
+	kvs := []Pair{
+		{k: CauseTypeKey, v: fmt.Sprintf("%T", cause)},
+		{k: CauseHashKey, v: fmt.Sprintf("%016x", h.Sum64())},
 	}
-	return e
+	if code := ErrCode(cause); code != "" {
+		kvs = append(kvs, Pair{k: CauseCodeKey, v: code})
+	}
+	kvs = append(kvs, Pair{k: CauseSizeKey, v: len(msg)})
+	return entry{id: uniqueId, errors: sentinels, kvs: kvs}
 }
 
 // handleCause inspects err and cause and, if cause is non-nil,
@@ -612,34 +3529,90 @@ func handleCause(err, cause error) error {
 	if err == nil {
 		return cause
 	}
+	//goland:noinspection GoTypeAssertionOnErrors
+	if e, ok := err.(entry); ok {
+		e.cause = cause
+		err = e
+	}
 	// If we have a trailing cause, join it LAST.
 	return errors.Join(err, cause)
 }
 
-// checkCrossPackage wraps an error with ErrCrossPackageError if it's an entry
-// from a different doterr package (different uniqueId).
+// checkCrossPackage handles an entry from a different doterr package
+// (different DoterrID), detected through the ErrNode protocol since each
+// copy's entry is a distinct Go type. Depending on the CrossPackageMode it
+// either wraps it with ErrCrossPackageError or adopts its metadata.
 func checkCrossPackage(err error) error {
+	n, isNode := err.(ErrNode)
+	if !isNode || n.DoterrID() == uniqueId {
+		return err
+	}
+	if CrossPackageMode(crossPackageMode.Load()) == CrossPackageAdopt {
+		return errors.Join(adoptEntry(n), err)
+	}
+	// Cross-package error detected - prepend sentinel
+	crossPkgErr := buildEntry(ErrCrossPackageError, "package_id", n.DoterrID(), "expected_id", uniqueId)
+	return errors.Join(crossPkgErr, err)
+}
+
+// adoptEntry copies a foreign node's sentinels and metadata into a local,
+// read-only entry marked with ForeignKey and the foreign "package_id".
+func adoptEntry(n ErrNode) entry {
+	view, _ := nodeEntry(n)
+	return adoptView(view)
+}
+
+// adoptView is adoptEntry for a foreign entry already read by nodeEntry.
+func adoptView(view entry) entry {
+	adopted := entry{
+		id:      uniqueId,
+		errors:  view.errors,
+		kvs:     view.kvs,
+		adopted: true,
+	}
+	adopted.kvs = append(adopted.kvs,
+		Pair{k: ForeignKey, v: true},
+		Pair{k: "package_id", v: view.id},
+	)
+	return adopted
+}
+
+// nodeEntry returns err as an entry if it is one of this package's entries
+// (by value or pointer) or a foreign copy's entry exposed through ErrNode. A
+// foreign entry is converted into a read-only view keeping its DoterrID.
+func nodeEntry(err error) (entry, bool) {
 	//goland:noinspection GoTypeAssertionOnErrors
-	e, isEntry := err.(entry)
-	if isEntry && e.id != uniqueId {
-		// Cross-package error detected - prepend sentinel
-		crossPkgErr := buildEntry(ErrCrossPackageError, "package_id", e.id, "expected_id", uniqueId)
-		return errors.Join(crossPkgErr, err)
+	switch e := err.(type) {
+	case entry:
+		return e, true
+	case *entry:
+		if e != nil {
+			return *e, true
+		}
+	case ErrNode:
+		keys, values := e.DoterrMeta()
+		view := entry{id: e.DoterrID(), errors: e.DoterrSentinels()}
+		for i, k := range keys {
+			if i < len(values) {
+				view.kvs = append(view.kvs, Pair{k: k, v: values[i]})
+			}
+		}
+		return view, true
 	}
-	return err
+	return entry{}, false
 }
 
 // buildErr tries to enrich the rightmost doterr entry inside baseErr.
 // If none found, it joins a fresh entry (from middle) with baseErr,
 // preserving baseErr's internals (including any existing cause).
-func buildErr(baseErr error, middle []any) error {
+func buildErr(baseErr error, middle []any, o origin) error {
 	enriched, ok := enrichRightmost(baseErr, middle...)
 	if ok {
 		// Successfully merged into an existing doterr entry.
 		return enriched
 	}
 	// No doterr entry found inside base; create a fresh entry and join it with base.
-	e := buildEntry(middle...)
+	e := buildEntry(withOrigin(middle, o)...)
 	if e != nil {
 		// cause remains inside baseErr
 		return errors.Join(e, baseErr)
@@ -657,10 +3630,9 @@ func enrichRightmost(err error, parts ...any) (error, bool) {
 	// Case (a): err is an entry → merge directly.
 	//goland:noinspection GoTypeAssertionOnErrors
 	e, ok := err.(entry)
-	if ok {
-		tmp := e
-		appendEntry(&tmp, parts...)
-		return tmp, true
+	if ok && !e.adopted {
+		merged, conflict := mergeEntry(e, parts)
+		return handleConflict(conflict, merged), true
 	}
 
 	// Case (b): err is a join (multi-unwrap) → scan immediate children right-to-left.
@@ -679,11 +3651,10 @@ func enrichRightmost(err error, parts ...any) (error, bool) {
 	for i := len(newKids) - 1; i >= 0; i-- {
 		//goland:noinspection GoTypeAssertionOnErrors
 		e, ok := newKids[i].(entry)
-		if ok {
-			tmp := e
-			appendEntry(&tmp, parts...)
-			newKids[i] = tmp
-			return errors.Join(newKids...), true
+		if ok && !e.adopted {
+			merged, conflict := mergeEntry(e, parts)
+			newKids[i] = merged
+			return handleConflict(conflict, errors.Join(newKids...)), true
 		}
 		// NOTE: Deliberately NO recursion into nested joins.
 	}
@@ -691,3 +3662,442 @@ func enrichRightmost(err error, parts ...any) (error, bool) {
 	// No doterr entry found at this level.
 	return err, false
 }
+
+// mergeEntry returns a copy of e with parts merged in according to the
+// current MergePolicy. The copy never shares backing arrays with e, so the
+// original entry is left untouched. Under MergeReject, conflict describes the
+// first key that was rejected.
+func mergeEntry(e entry, parts []any) (merged entry, conflict *entry) {
+	var add entry
+	appendEntry(&add, parts...)
+
+	merged = entry{
+		id:     e.id,
+		errors: append(slices.Clip(e.errors), add.errors...),
+		kvs:    slices.Clone(e.kvs),
+	}
+	policy := MergePolicy(mergePolicy.Load())
+	for _, pair := range add.kvs {
+		idx := slices.IndexFunc(merged.kvs, func(p Pair) bool { return p.k == pair.k })
+		switch {
+		case idx < 0 || policy == MergeLayer:
+			merged.kvs = append(merged.kvs, pair)
+		case policy == MergeOverride:
+			merged.kvs[idx] = pair
+		case conflict == nil:
+			conflict = newEntry([]error{ErrMetaConflict}, []Pair{{k: "key", v: pair.k}})
+		}
+	}
+	return merged, conflict
+}
+
+// handleConflict joins a merge conflict (if any) in front of err.
+func handleConflict(conflict *entry, err error) error {
+	if conflict == nil {
+		return err
+	}
+	return errors.Join(*conflict, err)
+}
+
+// fingerprintErr writes the shape of err into h. Entries contribute their
+// sentinel messages (walking composite ones in turn) and metadata keys;
+// multi-unwrap trees contribute their children in order; any other error contributes its concrete type and is
+// unwrapped further if it wraps a single error.
+func fingerprintErr(h hash.Hash, err error) {
+	e, ok := nodeEntry(err)
+	if ok {
+		for _, s := range e.errors {
+			if isComposite(s) {
+				// A cause stored as a sentinel; its text holds metadata values.
+				fingerprintErr(h, s)
+				continue
+			}
+			_, _ = fmt.Fprintf(h, "s:%s\n", s.Error())
+		}
+		for _, pair := range e.kvs {
+			_, _ = fmt.Fprintf(h, "k:%s\n", pair.k)
+		}
+		return
+	}
+	switch u := err.(type) {
+	case interface{ Unwrap() []error }:
+		_, _ = fmt.Fprint(h, "(\n")
+		for _, child := range u.Unwrap() {
+			if child != nil {
+				fingerprintErr(h, child)
+			}
+		}
+		_, _ = fmt.Fprint(h, ")\n")
+	case interface{ Unwrap() error }:
+		_, _ = fmt.Fprintf(h, "t:%T\n", err)
+		inner := u.Unwrap()
+		if inner != nil {
+			fingerprintErr(h, inner)
+		}
+	default:
+		_, _ = fmt.Fprintf(h, "t:%T\n", err)
+	}
+}
+
+// nextCause returns the primary cause of err as defined by Causes().
+func nextCause(err error) error {
+	//goland:noinspection GoTypeAssertionOnErrors
+	switch u := err.(type) {
+	case ErrNode, combined:
+		return nil
+	case interface{ Unwrap() error }:
+		return u.Unwrap()
+	case interface{ Unwrap() []error }:
+		kids := u.Unwrap()
+		if len(kids) == 0 {
+			return nil
+		}
+		return kids[len(kids)-1]
+	}
+	return nil
+}
+
+// walkEntries calls fn for every doterr entry in the tree of err, depth-first
+// and left-to-right, descending through multi-unwrap and single-unwrap errors.
+// The sentinels held inside an entry are not descended into.
+func walkEntries(err error, fn func(entry)) {
+	walkEntriesUntil(err, func(e entry) bool {
+		fn(e)
+		return true
+	})
+}
+
+// walkEntriesUntil is walkEntries stopping as soon as fn returns false, in
+// which case it returns false. Aggregate members are visited without copying.
+func walkEntriesUntil(err error, fn func(entry) bool) bool {
+	if err == nil {
+		return true
+	}
+	e, ok := nodeEntry(err)
+	if ok {
+		return fn(e)
+	}
+	switch u := err.(type) {
+	case interface{ Unwrap() []error }:
+		for child := range ChildrenSeq(err) {
+			if !walkEntriesUntil(child, fn) {
+				return false
+			}
+		}
+	case interface{ Unwrap() error }:
+		return walkEntriesUntil(u.Unwrap(), fn)
+	}
+	return true
+}
+
+// walkEntriesDeep is walkEntriesUntil also descending into the composite
+// errors an entry holds, after fn is called for it, since NewErr(ErrX, cause)
+// stores a cause given without metadata among the sentinels. Lookups of one
+// value down the chain use it; collectors such as ErrSentinels do not, so an
+// inner entry's sentinels are never reported as the outer entry's.
+func walkEntriesDeep(err error, fn func(entry) bool) bool {
+	return walkEntriesUntil(err, func(e entry) bool {
+		if !fn(e) {
+			return false
+		}
+		for _, s := range e.errors {
+			if isComposite(s) && !walkEntriesDeep(s, fn) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// flatMeta returns the metadata of every doterr entry in the tree of err,
+// keeping the first (outermost) value of each key, with the keys in the order
+// they were first seen.
+func flatMeta(err error) (keys []string, values map[string]any) {
+	values = make(map[string]any)
+	walkEntries(err, func(e entry) {
+		for _, pair := range e.kvs {
+			if _, seen := values[pair.k]; seen {
+				continue
+			}
+			keys = append(keys, pair.k)
+			values[pair.k] = pair.v
+		}
+	})
+	return keys, values
+}
+
+// findValue returns the value for key from the first doterr entry in the
+// tree of err (depth-first, left-to-right) that has that key.
+func findValue(err error, key string) (v any, found bool) {
+	walkEntriesDeep(err, func(e entry) bool {
+		for _, pair := range e.kvs {
+			if pair.k == key {
+				v, found = pair.v, true
+				return false
+			}
+		}
+		return true
+	})
+	return v, found
+}
+
+// isNilErr reports whether err is nil or a typed nil: a nil pointer, map,
+// slice, func or chan stored in a non-nil error interface.
+func isNilErr(err error) bool {
+	if err == nil {
+		return true
+	}
+	v := reflect.ValueOf(err)
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Map, reflect.Slice, reflect.Func, reflect.Chan:
+		return v.IsNil()
+	}
+	return false
+}
+
+// equalConfig holds the options of one ErrEqual comparison.
+type equalConfig struct {
+	ignore []string
+}
+
+func (c *equalConfig) equal(a, b error) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	ea, okA := nodeEntry(a)
+	eb, okB := nodeEntry(b)
+	if okA || okB {
+		return okA && okB && c.equalEntries(ea, eb)
+	}
+	if reflect.TypeOf(a) != reflect.TypeOf(b) {
+		return false
+	}
+	switch ua := a.(type) {
+	case interface{ Unwrap() []error }:
+		ka, kb := ua.Unwrap(), b.(interface{ Unwrap() []error }).Unwrap()
+		return c.equalLists(ka, kb)
+	case interface{ Unwrap() error }:
+		ia, ib := ua.Unwrap(), b.(interface{ Unwrap() error }).Unwrap()
+		if ia == nil || ib == nil {
+			return ia == nil && ib == nil && a.Error() == b.Error()
+		}
+		// Compare only this layer's own text, e.g. the "lookup: " of a
+		// fmt.Errorf wrap, so ignored metadata deeper down cannot leak in.
+		ownA, _ := strings.CutSuffix(a.Error(), ia.Error())
+		ownB, _ := strings.CutSuffix(b.Error(), ib.Error())
+		return ownA == ownB && c.equal(ia, ib)
+	}
+	//goland:noinspection GoDirectComparisonOfErrors
+	if hashableErr(a) && a == b {
+		return true
+	}
+	return a.Error() == b.Error()
+}
+
+func (c *equalConfig) equalLists(a, b []error) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !c.equal(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *equalConfig) equalEntries(a, b entry) bool {
+	if len(a.errors) != len(b.errors) {
+		return false
+	}
+	for i := range a.errors {
+		sa, sb := a.errors[i], b.errors[i]
+		//goland:noinspection GoDirectComparisonOfErrors
+		if hashableErr(sa) && hashableErr(sb) && sa == sb {
+			continue
+		}
+		if !isComposite(sa) || !c.equal(sa, sb) {
+			return false
+		}
+	}
+	ka, kb := c.keptKVs(a.kvs), c.keptKVs(b.kvs)
+	if len(ka) != len(kb) {
+		return false
+	}
+	for i := range ka {
+		if ka[i].k != kb[i].k || !reflect.DeepEqual(ka[i].v, kb[i].v) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *equalConfig) keptKVs(kvs []Pair) []Pair {
+	if len(c.ignore) == 0 {
+		return kvs
+	}
+	out := make([]Pair, 0, len(kvs))
+	for _, pair := range kvs {
+		if !slices.Contains(c.ignore, pair.k) {
+			out = append(out, pair)
+		}
+	}
+	return out
+}
+
+// isComposite reports whether err wraps other errors, i.e. is a cause stored
+// among an entry's sentinels rather than a sentinel itself.
+func isComposite(err error) bool {
+	if _, ok := nodeEntry(err); ok {
+		return true
+	}
+	switch err.(type) {
+	case interface{ Unwrap() []error }, interface{ Unwrap() error }:
+		return true
+	}
+	return false
+}
+
+// pruneErr implements Prune, reporting whether anything changed so that
+// untouched subtrees are returned as they were.
+func pruneErr(err error, drop func(ErrNode) bool) (error, bool) {
+	return rewriteErr(err, func(e entry) (error, bool) {
+		if !drop(e) {
+			return e, false
+		}
+		switch len(e.errors) {
+		case 0:
+			return nil, true
+		case 1:
+			return e.errors[0], true
+		}
+		return errors.Join(e.errors...), true
+	})
+}
+
+// The wrapper types of the standard library that rewriteErr rebuilds. They
+// have no Is or As methods of their own, so a rebuilt copy matches exactly
+// what the original did.
+var (
+	joinErrType = reflect.TypeOf(errors.Join(errors.New("")))
+	wrapErrType = reflect.TypeOf(fmt.Errorf("%w", errors.New("")))
+)
+
+// rewriteErr returns err with fn applied to every doterr entry in its tree,
+// and whether anything changed. fn is given the entry, its composite
+// sentinels already rewritten, and returns the error to put in its place
+// (nil removes it) and whether that differs from the entry; it must not
+// modify the given entry's slices. Entries left empty disappear.
+//
+// Only doterr's own structure, errors.Join results and fmt.Errorf wraps
+// with a single %w are rebuilt around a change; any other wrapping error is
+// kept intact, with the tree beneath it, since a copy of it would break its
+// errors.As matches and its custom Is and As methods.
+func rewriteErr(err error, fn func(entry) (error, bool)) (error, bool) {
+	//goland:noinspection GoTypeAssertionOnErrors
+	if s, ok := err.(*sealed); ok {
+		inner, changed := rewriteErr(s.err, fn)
+		if !changed || inner == nil {
+			return inner, changed
+		}
+		return &sealed{err: inner}, true
+	}
+	if e, ok := nodeEntry(err); ok {
+		changed := false
+		errs := make([]error, 0, len(e.errors))
+		for _, s := range e.errors {
+			if isComposite(s) {
+				ps, c := rewriteErr(s, fn)
+				changed = changed || c
+				if ps != nil {
+					errs = append(errs, ps)
+				}
+				continue
+			}
+			errs = append(errs, s)
+		}
+		if changed {
+			e.errors = errs
+		}
+		out, c := fn(e)
+		if !changed && !c {
+			return err, false
+		}
+		if oe, ok := nodeEntry(out); ok {
+			if len(oe.errors) == 0 && len(oe.kvs) == 0 {
+				return nil, true
+			}
+			if oe.id != uniqueId {
+				oe = adoptView(oe) // a rewritten foreign entry stays read-only
+			}
+			out = oe // by value, keeping adopted
+		}
+		return out, true
+	}
+	switch u := err.(type) {
+	case interface{ Unwrap() []error }:
+		//goland:noinspection GoTypeAssertionOnErrors
+		agg, isCombined := err.(combined)
+		if !isCombined && reflect.TypeOf(err) != joinErrType {
+			return err, false
+		}
+		kids := u.Unwrap()
+		changed := false
+		out := make([]error, 0, len(kids))
+		for _, kid := range kids {
+			if kid == nil {
+				continue
+			}
+			pk, c := rewriteErr(kid, fn)
+			changed = changed || c
+			if pk != nil {
+				out = append(out, pk)
+			}
+		}
+		if !changed {
+			return err, false
+		}
+		if isCombined {
+			return CombineErrs(out, WithRenderer(agg.render)), true
+		}
+		return errors.Join(out...), true
+	case interface{ Unwrap() error }:
+		inner := u.Unwrap()
+		if inner == nil || reflect.TypeOf(err) != wrapErrType {
+			return err, false
+		}
+		pi, changed := rewriteErr(inner, fn)
+		if !changed {
+			return err, false
+		}
+		own, _ := strings.CutSuffix(err.Error(), inner.Error())
+		if pi == nil {
+			return errors.New(strings.TrimSuffix(own, ": ")), true
+		}
+		return fmt.Errorf("%s%w", own, pi), true
+	}
+	return err, false
+}
+
+// hashableErr reports whether err is non-nil and usable as a map key. The
+// value, not just its type, is checked: a struct with an interface field has
+// a comparable type, yet hashing it panics when the field holds an entry.
+func hashableErr(err error) bool {
+	return err != nil && reflect.ValueOf(err).Comparable()
+}
+
+// containsErr reports whether errs holds target by identity.
+// Non-comparable errors (such as entries) are never considered held, since
+// comparing them would panic.
+func containsErr(errs []error, target error) bool {
+	if !hashableErr(target) {
+		return false
+	}
+	for _, err := range errs {
+		//goland:noinspection GoDirectComparisonOfErrors
+		if err == target {
+			return true
+		}
+	}
+	return false
+}
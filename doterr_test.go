@@ -1,9 +1,21 @@
 package doterr_test
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"io"
+	"io/fs"
 	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"testing"
+	"time"
 
 	. "github.com/mikeschinkel/go-doterr"
 )
@@ -400,3 +412,1769 @@ func TestWithErr_DetectsCrossPackageErrors_CauseError(t *testing.T) {
 		t.Error("local error should not trigger cross-package detection")
 	}
 }
+
+func TestErrFingerprint_IgnoresValues(t *testing.T) {
+	a := NewErr(ErrTest, "id", 1, errors.New("cause a"))
+	b := NewErr(ErrTest, "id", 2, errors.New("cause b"))
+	if ErrFingerprint(a) != ErrFingerprint(b) {
+		t.Error("expected errors differing only by values to share a fingerprint")
+	}
+	// A cause stored among the sentinels renders its values in its text.
+	held := func(id int) error { return NewErr(ErrTest, fmt.Errorf("held: %w", NewErr(ErrOther, "id", id))) }
+	if ErrFingerprint(held(1)) != ErrFingerprint(held(2)) {
+		t.Error("expected nested entries differing only by values to share a fingerprint")
+	}
+	c := NewErr(ErrOther, "id", 1)
+	if ErrFingerprint(a) == ErrFingerprint(c) {
+		t.Error("expected different sentinels to produce different fingerprints")
+	}
+	if ErrFingerprint(nil) != "" {
+		t.Error("expected empty fingerprint for nil")
+	}
+}
+
+func TestErrSentinels_WalksWholeTree(t *testing.T) {
+	inner := NewErr(ErrOther, "k", 1)
+	outer := NewErr(ErrTest, "k", 2, fmt.Errorf("wrapped: %w", inner))
+	got := ErrSentinels(outer)
+	if len(got) != 2 || got[0] != ErrTest || got[1] != ErrOther {
+		t.Fatalf("expected [ErrTest ErrOther], got %v", got)
+	}
+	held := fmt.Errorf("held: %w", inner)
+	if got := ErrSentinels(NewErr(ErrTest, held)); len(got) != 2 || got[0] != ErrTest || got[1] != held {
+		t.Errorf("expected a held error itself, not its sentinels, got %v", got)
+	}
+	if ErrSentinels(errors.New("plain")) != nil {
+		t.Error("expected nil for a non-doterr error")
+	}
+}
+
+// QueryErr is a user wrapper with value receivers; a struct with an
+// interface field is comparable by type but not when the field holds an
+// entry.
+type QueryErr struct{ Err error }
+
+func (q QueryErr) Error() string { return "query: " + q.Err.Error() }
+func (q QueryErr) Unwrap() error { return q.Err }
+
+func TestUncomparableWrappers_DoNotPanic(t *testing.T) {
+	recovered := func() (r any) {
+		defer func() { r = recover() }()
+		PanicErr(NewErr(ErrOther, "k", 1))
+		return nil
+	}
+	for name, held := range map[string]error{
+		"Seal":     Seal(NewErr(ErrOther, "k", 1)),
+		"panicked": recovered().(error),
+		"QueryErr": QueryErr{Err: NewErr(ErrOther, "k", 1)},
+	} {
+		err := NewErr(ErrTest, held)
+		_ = IsRetryable(err)
+		_ = ErrSeverity(err)
+		_ = ErrCode(err)
+		_ = Summarize(err)
+		if got := ErrSentinels(CombineErrs([]error{err, NewErr(ErrTest, held)})); len(got) < 2 {
+			t.Errorf("%s: unexpected sentinels %v", name, got)
+		}
+		if _, ok := LookupSentinel(held); ok {
+			t.Errorf("%s: did not expect a registration", name)
+		}
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	cause := NewErr(ErrOther, ErrRetryable)
+	if !IsRetryable(NewErr(ErrTest, "k", 1, cause)) {
+		t.Error("expected nested ErrRetryable to be found")
+	}
+	if IsRetryable(NewErr(ErrTest)) {
+		t.Error("did not expect plain error to be retryable")
+	}
+}
+
+func TestErrSeverity_OutermostWins(t *testing.T) {
+	cause := NewErr(ErrOther, SeverityCritical)
+	err := NewErr(ErrTest, SeverityWarn, "k", 1, cause)
+	if got := ErrSeverity(err); got != SeverityWarn {
+		t.Errorf("expected warn, got %v", got)
+	}
+	if got := ErrSeverity(NewErr(ErrTest, "k", 1, cause)); got != SeverityCritical {
+		t.Errorf("expected nested critical, got %v", got)
+	}
+	if got := ErrSeverity(NewErr(ErrTest)); got != SeverityUnset {
+		t.Errorf("expected unset, got %v", got)
+	}
+}
+
+func TestRetryAfter_RoundTrip(t *testing.T) {
+	err := WithRetryAfter(NewErr(ErrRateLimited, "client", "abc"), 3*time.Second)
+	d, ok := RetryAfter(err)
+	if !ok || d != 3*time.Second {
+		t.Errorf("expected 3s, got %v (ok=%v)", d, ok)
+	}
+	wrapped := NewErr(ErrTest, fmt.Errorf("upstream: %w", err))
+	if d, ok := RetryAfter(wrapped); !ok || d != 3*time.Second {
+		t.Errorf("expected nested retry_after to be found, got %v (ok=%v)", d, ok)
+	}
+	if _, ok := RetryAfter(NewErr(ErrTest, RetryAfterKey, "soon")); ok {
+		t.Error("expected non-duration retry_after to be ignored")
+	}
+	if WithRetryAfter(nil, time.Second) != nil || WithRetryAfter(loadTypedNil(), time.Second) != nil {
+		t.Error("expected nil for nil and typed-nil errors")
+	}
+}
+
+func TestWithJob_ErrJob(t *testing.T) {
+	err := WithJob(NewErr(ErrTest, "invoice", "inv-9"), "billing.invoices", "job-42", 3)
+	job, ok := ErrJob(fmt.Errorf("worker: %w", err))
+	if !ok || job != (Job{Queue: "billing.invoices", ID: "job-42", Attempt: 3}) {
+		t.Errorf("unexpected job %+v (ok=%v)", job, ok)
+	}
+	if v, _ := ErrValue[string](err, "invoice"); v != "inv-9" {
+		t.Errorf("expected existing metadata kept, got %q", v)
+	}
+	// Fields come from one entry, never mixed across jobs.
+	outer := NewErr(ErrTest, JobIDKey, "outer", WithJob(NewErr(ErrTest), "q", "inner", 1))
+	if job, _ := ErrJob(outer); job != (Job{ID: "outer"}) {
+		t.Errorf("expected outer job only, got %+v", job)
+	}
+	if _, ok := ErrJob(NewErr(ErrTest)); ok {
+		t.Error("expected no job")
+	}
+	if WithJob(nil, "q", "id", 1) != nil || WithJob(loadTypedNil(), "q", "id", 1) != nil {
+		t.Error("expected nil for nil and typed-nil errors")
+	}
+}
+
+func TestPartialResult_SummaryDetailsAndErr(t *testing.T) {
+	var pr PartialResult
+	pr.Succeed("a")
+	pr.Fail("b", nil)
+	pr.Fail("c", NewErr(ErrTest, "reason", "dup"))
+
+	if got := pr.Summary(); got != "2 succeeded, 1 failed" {
+		t.Errorf("unexpected summary %q", got)
+	}
+	details := pr.Details()
+	if len(details) != 1 || details[0].ID != "c" || details[0].Meta["reason"] != "dup" {
+		t.Fatalf("unexpected details %+v", details)
+	}
+	if len(details[0].Sentinels) != 1 || details[0].Sentinels[0] != "test" {
+		t.Errorf("unexpected sentinels %v", details[0].Sentinels)
+	}
+
+	err := pr.Err()
+	if !errors.Is(err, ErrPartialFailure) || !errors.Is(err, ErrTest) {
+		t.Fatalf("expected partial failure aggregate, got %v", err)
+	}
+	if n, _ := ErrValue[int](err, "failed"); n != 1 {
+		t.Errorf("expected failed=1 on summary entry, got %d", n)
+	}
+	var empty PartialResult
+	if empty.Err() != nil {
+		t.Error("expected nil error with no failures")
+	}
+}
+
+func TestCollectChan_JoinsInArrivalOrder(t *testing.T) {
+	ch := make(chan error, 3)
+	ch <- NewErr(ErrTest, "source", "a")
+	ch <- nil
+	ch <- NewErr(ErrOther, "source", "b")
+	close(ch)
+
+	err := CollectChan(context.Background(), ch)
+	u, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		t.Fatalf("expected an aggregate, got %T", err)
+	}
+	kids := u.Unwrap()
+	if len(kids) != 2 {
+		t.Fatalf("expected 2 errors, got %d", len(kids))
+	}
+	for i, kid := range kids {
+		if n, _ := ErrValue[int](kid, ArrivalKey); n != i+1 {
+			t.Errorf("child %d: expected arrival %d, got %d", i, i+1, n)
+		}
+	}
+	if !errors.Is(err, ErrTest) || !errors.Is(err, ErrOther) {
+		t.Error("expected both sentinels in aggregate")
+	}
+}
+
+func TestCollectChan_StopsOnContextDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := CollectChan(ctx, make(chan error))
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestCombineErrs_TraversesLikeErrorsJoin(t *testing.T) {
+	target := &customError{"deep"}
+	members := []error{
+		NewErr(ErrTest, "k", 1),
+		NewErr(ErrOther, fmt.Errorf("wrapped: %w", target)),
+	}
+	combined := CombineErrs(members)
+	joined := errors.Join(members...)
+
+	for name, err := range map[string]error{"combined": combined, "joined": joined} {
+		if !errors.Is(err, ErrTest) || !errors.Is(err, ErrOther) {
+			t.Errorf("%s: expected errors.Is to find both sentinels", name)
+		}
+		got, ok := FindErr[*customError](err)
+		if //goland:noinspection GoDirectComparisonOfErrors
+		!ok || got != target {
+			t.Errorf("%s: expected errors.As to reach nested typed error", name)
+		}
+	}
+
+	u := combined.(interface{ Unwrap() []error })
+	kids := u.Unwrap()
+	kids[0] = nil
+	if u.Unwrap()[0] == nil {
+		t.Error("expected Unwrap to return a copy")
+	}
+}
+
+func TestCauses_FollowsPrimaryChain(t *testing.T) {
+	root := errors.New("root")
+	driver := NewErr(ErrOther, "sql", "SELECT 1", root)
+	repo := fmt.Errorf("repo: %w", driver)
+	svc := NewErr(ErrTest, "op", "Get", repo)
+
+	var chain []error
+	for e := range Causes(svc) {
+		chain = append(chain, e)
+	}
+	if len(chain) != 4 {
+		t.Fatalf("expected 4 nodes, got %d: %v", len(chain), chain)
+	}
+	if op, _ := ErrValue[string](chain[0], "op"); op != "Get" {
+		t.Errorf("expected first node to be the service layer, got %v", chain[0])
+	}
+	if sql, _ := ErrValue[string](chain[2], "sql"); sql != "SELECT 1" {
+		t.Errorf("expected third node to be the driver layer, got %v", chain[2])
+	}
+	//goland:noinspection GoDirectComparisonOfErrors
+	if chain[3] != root {
+		t.Errorf("expected chain to end at root, got %v", chain[3])
+	}
+
+	n := 0
+	for range Causes(svc) {
+		n++
+		break
+	}
+	if n != 1 {
+		t.Error("expected iteration to stop on break")
+	}
+}
+
+func TestNewErr_KVBeforeTrailingCause(t *testing.T) {
+	cause := errors.New("cause")
+	err := NewErr(ErrTest, SeverityWarn, cause)
+	if errors.Is(err, ErrMisplacedError) {
+		t.Fatalf("expected explicit KV to count as a pair, got %v", err)
+	}
+	if !errors.Is(err, cause) || ErrSeverity(err) != SeverityWarn {
+		t.Errorf("expected cause and severity, got %v", err)
+	}
+}
+
+func TestNewOpErr_TraceOutermostFirst(t *testing.T) {
+	root := errors.New("connection reset")
+	s3 := NewOpErr(Op("s3.Put"), ErrOther, root)
+	store := NewOpErr(Op("store.Save"), ErrOther, "key", "k1", s3)
+	api := NewOpErr(Op("api.Upload"), ErrTest, fmt.Errorf("saving: %w", store))
+
+	if !errors.Is(api, root) {
+		t.Fatal("lost root cause")
+	}
+	ops := ErrOps(api)
+	if len(ops) != 3 || ops[0] != "api.Upload" || ops[2] != "s3.Put" {
+		t.Fatalf("unexpected ops %v", ops)
+	}
+	if got := ErrOpTrace(api); got != "api.Upload → store.Save → s3.Put" {
+		t.Errorf("unexpected trace %q", got)
+	}
+	if ErrOpTrace(NewErr(ErrTest)) != "" {
+		t.Error("expected empty trace without ops")
+	}
+}
+
+func TestWithErr_MergePolicies(t *testing.T) {
+	t.Cleanup(func() { SetMergePolicy(MergeLayer) })
+	base := NewErr(ErrTest, "id", 1, "name", "a")
+
+	layered := WithErr(base, "id", 2)
+	if id, _ := ErrValue[int](layered, "id"); id != 1 || len(ErrMeta(layered)) != 3 {
+		t.Errorf("layer: expected original value first and both kept, got %v", ErrMeta(layered))
+	}
+
+	SetMergePolicy(MergeOverride)
+	overridden := WithErr(base, "id", 2)
+	kvs := ErrMeta(overridden)
+	if len(kvs) != 2 || kvs[0].Key() != "id" || kvs[0].Value() != 2 {
+		t.Errorf("override: expected id replaced in place, got %v", kvs)
+	}
+
+	SetMergePolicy(MergeReject)
+	rejected := WithErr(base, "id", 2, "extra", true)
+	if !errors.Is(rejected, ErrMetaConflict) {
+		t.Fatalf("reject: expected ErrMetaConflict, got %v", rejected)
+	}
+	if key, _ := ErrValue[string](rejected, "key"); key != "id" {
+		t.Errorf("reject: expected conflicting key reported, got %q", key)
+	}
+	if ErrSeverity(rejected) != SeverityUnset || !errors.Is(rejected, ErrTest) {
+		t.Errorf("reject: expected base preserved, got %v", rejected)
+	}
+
+	if id, _ := ErrValue[int](base, "id"); id != 1 || len(ErrMeta(base)) != 2 {
+		t.Error("expected base entry to be left untouched")
+	}
+}
+
+func TestSeal_BlocksEnrichment(t *testing.T) {
+	final := Seal(NewErr(ErrTest, "user_message", "try again later"))
+	if !IsSealed(final) || IsSealed(NewErr(ErrTest)) {
+		t.Fatal("expected IsSealed to detect sealed errors only")
+	}
+	if !errors.Is(final, ErrTest) {
+		t.Error("expected sealed error to keep its sentinels")
+	}
+	if msg, _ := ErrValue[string](final, "user_message"); msg != "try again later" {
+		t.Errorf("expected metadata readable through seal, got %q", msg)
+	}
+
+	attempted := WithErr(final, "internal", "leak")
+	if !errors.Is(attempted, ErrSealed) {
+		t.Fatalf("expected ErrSealed, got %v", attempted)
+	}
+	if _, found := ErrValue[string](attempted, "internal"); found {
+		t.Error("expected new metadata to be discarded")
+	}
+	if !errors.Is(attempted, ErrTest) {
+		t.Error("expected original sealed error to be preserved")
+	}
+
+	wrapped := NewErr(ErrOther, "layer", "outer", final)
+	if errors.Is(wrapped, ErrSealed) || !errors.Is(wrapped, ErrTest) || !errors.Is(wrapped, final) {
+		t.Error("expected sealed error to be usable as a cause")
+	}
+	if again := WithErr(attempted, "internal", "leak"); again != attempted {
+		t.Errorf("expected a second attempt to leave the error unchanged, got %v", again)
+	}
+	for _, holder := range []error{wrapped, errors.Join(errors.New("other"), final), fmt.Errorf("ctx: %w", final)} {
+		got := WithErr(holder, "internal", "leak")
+		if !errors.Is(got, ErrSealed) {
+			t.Errorf("expected a tree holding a sealed error to be protected, got %v", got)
+		}
+		if _, found := ErrValue[string](got, "internal"); found {
+			t.Errorf("expected new metadata discarded for %v", holder)
+		}
+	}
+	if Seal(nil) != nil {
+		t.Error("expected nil for nil")
+	}
+}
+
+// Sentinels registered by tests live in package vars: the registry is
+// global, and re-registering the same sentinel is a no-op, so the tests pass
+// under -count=N.
+var (
+	errRegistryA = errors.New("registry a")
+	errRegistryB = errors.New("registry b")
+	errRegistryC = errors.New("registry c")
+	errRegistryD = errors.New("registry d")
+)
+
+func TestRegisterSentinel_DetectsDuplicates(t *testing.T) {
+	errA, errB, errC := errRegistryA, errRegistryB, errRegistryC
+
+	if err := RegisterSentinel(errA, "T1001", "registry_a"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := RegisterSentinel(errA, "T1001", "registry_a"); err != nil {
+		t.Errorf("expected identical re-registration to be a no-op, got %v", err)
+	}
+
+	err := RegisterSentinel(errB, "T1001", "registry_b")
+	if !errors.Is(err, ErrDuplicateCode) {
+		t.Errorf("expected ErrDuplicateCode, got %v", err)
+	}
+	if existing, _ := ErrValue[string](err, "existing"); existing != "registry_a" {
+		t.Errorf("expected existing name in metadata, got %q", existing)
+	}
+	if err := RegisterSentinel(errC, "T1003", "registry_a"); !errors.Is(err, ErrDuplicateName) {
+		t.Errorf("expected ErrDuplicateName, got %v", err)
+	}
+	if err := RegisterSentinel(errA, "T1004", "registry_a"); !errors.Is(err, ErrDuplicateSentinel) {
+		t.Errorf("expected ErrDuplicateSentinel, got %v", err)
+	}
+	if err := RegisterSentinel(nil, "T1005", "x"); !errors.Is(err, ErrInvalidSentinel) {
+		t.Errorf("expected ErrInvalidSentinel, got %v", err)
+	}
+
+	info, ok := LookupCode("T1001")
+	//goland:noinspection GoDirectComparisonOfErrors
+	if !ok || info.Sentinel != errA {
+		t.Errorf("expected lookup by code, got %+v", info)
+	}
+	if got := ErrCode(NewErr(ErrTest, "k", 1, NewErr(errA))); got != "T1001" {
+		t.Errorf("expected ErrCode T1001, got %q", got)
+	}
+}
+
+func TestMustRegisterSentinel_Panics(t *testing.T) {
+	errD := MustRegisterSentinel(errRegistryD, "T2001", "")
+	if info, ok := LookupSentinel(errD); !ok || info.Name != "registry d" {
+		t.Errorf("expected name to default to message, got %+v", info)
+	}
+	defer func() {
+		r := recover()
+		err, ok := r.(error)
+		if !ok || !errors.Is(err, ErrDuplicateCode) {
+			t.Errorf("expected panic with ErrDuplicateCode, got %v", r)
+		}
+	}()
+	MustRegisterSentinel(errors.New("registry e"), "T2001", "registry_e")
+}
+
+func TestNamespace_PrefixesStringKeys(t *testing.T) {
+	ns := Namespace("mylib")
+	cause := errors.New("cause")
+	err := ns.NewErr(ErrTest, SeverityWarn, "id", "name", "line", 3, cause)
+
+	if v, _ := ErrValue[string](err, "mylib.id"); v != "name" {
+		t.Errorf("expected mylib.id=name (value left alone), got %q", v)
+	}
+	if v, _ := ErrValue[int](err, "mylib.line"); v != 3 {
+		t.Errorf("expected mylib.line=3, got %d", v)
+	}
+	if ErrSeverity(err) != SeverityWarn {
+		t.Error("expected explicit KV key to stay canonical")
+	}
+	if !errors.Is(err, cause) {
+		t.Error("lost cause")
+	}
+
+	enriched := ns.WithErr(err, "attempt", 2)
+	if v, _ := ErrValue[int](enriched, "mylib.attempt"); v != 2 {
+		t.Errorf("expected mylib.attempt=2, got %d", v)
+	}
+	if ops := ErrOps(ns.NewOpErr(Op("mylib.Parse"), ErrTest, "k", 1)); len(ops) != 1 {
+		t.Errorf("expected op to be recorded, got %v", ops)
+	}
+}
+
+// foreignNode simulates an entry created by another embedded copy of doterr.go.
+type foreignNode struct {
+	sentinels []error
+	keys      []string
+	values    []any
+}
+
+func (f foreignNode) Error() string                 { return "foreign" }
+func (f foreignNode) Unwrap() []error               { return f.sentinels }
+func (f foreignNode) DoterrID() int                 { return -1 }
+func (f foreignNode) DoterrSentinels() []error      { return f.sentinels }
+func (f foreignNode) DoterrMeta() ([]string, []any) { return f.keys, f.values }
+
+func TestErrNode_ReadsForeignEntries(t *testing.T) {
+	foreign := foreignNode{
+		sentinels: []error{ErrOther},
+		keys:      []string{"table"},
+		values:    []any{"users"},
+	}
+	if table, _ := ErrValue[string](foreign, "table"); table != "users" {
+		t.Errorf("expected foreign metadata to be readable, got %q", table)
+	}
+	if got := Errors(foreign); len(got) != 1 || got[0] != ErrOther {
+		t.Errorf("expected foreign sentinels, got %v", got)
+	}
+
+	local := NewErr(ErrTest, "op", "Get", foreign)
+	if got := ErrSentinels(local); len(got) != 2 || got[1] != ErrOther {
+		t.Errorf("expected sentinels from both copies, got %v", got)
+	}
+	if ErrFingerprint(local) == ErrFingerprint(NewErr(ErrTest, "op", "Get", errors.New("x"))) {
+		t.Error("expected foreign entry shape to contribute to fingerprint")
+	}
+}
+
+func TestWithErr_FlagsForeignEntriesViaProtocol(t *testing.T) {
+	foreign := foreignNode{sentinels: []error{ErrOther}}
+	enriched := WithErr(foreign, "extra", "metadata")
+	if !errors.Is(enriched, ErrCrossPackageError) {
+		t.Fatalf("expected ErrCrossPackageError, got %v", enriched)
+	}
+	if id, _ := ErrValue[int](enriched, "package_id"); id != -1 {
+		t.Errorf("expected foreign package_id, got %d", id)
+	}
+}
+
+func TestWithErr_AdoptsForeignEntries(t *testing.T) {
+	t.Cleanup(func() { SetCrossPackageMode(CrossPackageFlag) })
+	SetCrossPackageMode(CrossPackageAdopt)
+
+	foreign := foreignNode{
+		sentinels: []error{ErrOther},
+		keys:      []string{"table"},
+		values:    []any{"users"},
+	}
+	adopted := WithErr("op", "Get", foreign)
+	if errors.Is(adopted, ErrCrossPackageError) {
+		t.Fatalf("did not expect ErrCrossPackageError in adopt mode: %v", adopted)
+	}
+	var sawForeign bool
+	for _, kid := range adopted.(interface{ Unwrap() []error }).Unwrap() {
+		if f, _ := ErrValue[bool](kid, ForeignKey); f {
+			sawForeign = true
+			if table, _ := ErrValue[string](kid, "table"); table != "users" {
+				t.Errorf("expected adopted metadata, got %q", table)
+			}
+		}
+	}
+	if !sawForeign {
+		t.Error("expected an adopted entry marked foreign=true")
+	}
+
+	enriched := WithErr(foreign, "attempt", 2)
+	if n, _ := ErrValue[int](enriched, "attempt"); n != 2 {
+		t.Errorf("expected new metadata in a fresh local entry, got %v", ErrMeta(enriched))
+	}
+	if f, _ := ErrValue[bool](enriched, ForeignKey); f {
+		t.Error("expected fresh entry not to be marked foreign")
+	}
+	if !errors.Is(enriched, ErrOther) {
+		t.Error("expected foreign sentinel preserved")
+	}
+}
+
+func TestAttach_Attachments(t *testing.T) {
+	err := Attach(NewErr(ErrTest, "user", "alice"), "request_body", BlobRef("mem:abc"))
+	outer := NewErr(ErrOther, "attachment.dump", "file:def", err)
+
+	atts := Attachments(outer)
+	if len(atts) != 2 {
+		t.Fatalf("expected 2 attachments, got %v", atts)
+	}
+	if atts[0] != (Attachment{Name: "dump", Ref: "file:def"}) {
+		t.Errorf("expected string ref to be recognized, got %v", atts[0])
+	}
+	if atts[1] != (Attachment{Name: "request_body", Ref: "mem:abc"}) {
+		t.Errorf("unexpected nested attachment %v", atts[1])
+	}
+	if Attach(nil, "x", "mem:abc") != nil || Attach(loadTypedNil(), "x", "mem:abc") != nil {
+		t.Error("expected nil for nil and typed-nil errors")
+	}
+}
+
+type nilableErr struct{}
+
+func (*nilableErr) Error() string { panic("called on typed nil") }
+
+func loadTypedNil() error {
+	var p *nilableErr
+	return p
+}
+
+func TestTypedNilCauses_AreAbsent(t *testing.T) {
+	typedNil := loadTypedNil()
+
+	err := NewErr(ErrTest, "key", "k", typedNil)
+	if kids := err.(interface{ Unwrap() []error }).Unwrap(); len(kids) != 1 {
+		t.Errorf("expected no cause node, got %d children", len(kids))
+	}
+	if got := err.Error(); got != "test; meta: key=k" {
+		t.Errorf("unexpected message %q", got)
+	}
+	var untyped error
+	if got := NewErr(ErrTest, untyped); got.Error() != "test" {
+		t.Errorf("expected nil trailing cause to be dropped, got %q", got)
+	}
+	if meta := ErrMeta(NewErr(ErrTest, "key", nil)); len(meta) != 1 || meta[0].Value() != nil {
+		t.Errorf("expected nil to remain a valid value, got %v", meta)
+	}
+
+	enriched := WithErr(NewErr(ErrTest), "attempt", 1, typedNil)
+	if got := enriched.Error(); got != "test; meta: attempt=1" {
+		t.Errorf("unexpected WithErr message %q", got)
+	}
+	if got := WithErr(typedNil, "attempt", 1); got.Error() != "meta: attempt=1" {
+		t.Errorf("expected typed-nil base to be ignored, got %q", got)
+	}
+	if CombineErrs([]error{typedNil, nil}) != nil {
+		t.Error("expected CombineErrs to drop typed nils")
+	}
+	if len(AppendErr(nil, typedNil)) != 0 {
+		t.Error("expected AppendErr to drop typed nils")
+	}
+}
+
+func TestWrapErr(t *testing.T) {
+	if err := WrapErr(loadTypedNil(), ErrTest, "key", "k"); err != nil {
+		t.Errorf("expected nil for typed-nil cause, got %v", err)
+	}
+	if err := WrapErr(nil, ErrTest); err != nil {
+		t.Errorf("expected nil for nil cause, got %v", err)
+	}
+	cause := errors.New("disk full")
+	err := WrapErr(cause, ErrTest, "key", "k")
+	if !errors.Is(err, cause) || !errors.Is(err, ErrTest) {
+		t.Errorf("expected sentinel and cause, got %v", err)
+	}
+	if k, _ := ErrValue[string](err, "key"); k != "k" {
+		t.Errorf("expected key=k, got %q", k)
+	}
+}
+
+func TestNewValidationErr(t *testing.T) {
+	err := NewValidationErr(ErrTest, map[string]string{"name": "required", "age": "must be positive"})
+	if !errors.Is(err, ErrTest) || !errors.Is(err, ErrInvalidField) {
+		t.Fatalf("expected sentinel and ErrInvalidField, got %v", err)
+	}
+	if n, _ := ErrValue[int](err, InvalidCountKey); n != 2 {
+		t.Errorf("expected invalid_count=2, got %d", n)
+	}
+	fields := ValidationFields(err)
+	want := []FieldErr{{"age", "must be positive"}, {"name", "required"}}
+	if len(fields) != 2 || fields[0] != want[0] || fields[1] != want[1] {
+		t.Errorf("expected sorted fields %v, got %v", want, fields)
+	}
+	if NewValidationErr(ErrTest, nil) != nil {
+		t.Error("expected nil for no fields")
+	}
+}
+
+func recoverFrom(fn func()) (err error) {
+	defer func() {
+		if perr := RecoverErr(recover()); perr != nil {
+			err = perr
+		}
+	}()
+	fn()
+	return nil
+}
+
+func TestRecoverErr_PreservesErrorChain(t *testing.T) {
+	inner := NewErr(ErrTest, "user", "alice")
+	err := recoverFrom(func() { panic(fmt.Errorf("handler: %w", inner)) })
+
+	if !errors.Is(err, ErrPanic) || !errors.Is(err, ErrTest) {
+		t.Fatalf("expected ErrPanic and the original sentinel, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "handler: test; meta: user=alice") {
+		t.Errorf("expected the cause to be preserved rather than restated, got %q", err)
+	}
+	if typ, _ := ErrValue[string](err, PanicTypeKey); typ != "*fmt.wrapError" {
+		t.Errorf("unexpected panic_type %q", typ)
+	}
+	if _, ok := ErrValue[any](err, PanicValueKey); ok {
+		t.Error("did not expect panic_value for an error panic")
+	}
+
+	var re interface{ RuntimeError() }
+	err = recoverFrom(func() { _ = []int{}[len(os.Args)] })
+	if !errors.As(err, &re) {
+		t.Errorf("expected runtime.Error to be preserved, got %v", err)
+	}
+}
+
+func TestRecoverErr_NonErrorValues(t *testing.T) {
+	type code struct{ N int }
+	err := recoverFrom(func() { panic(code{N: 7}) })
+	if v, _ := ErrValue[code](err, PanicValueKey); v.N != 7 {
+		t.Errorf("expected typed panic_value, got %v", ErrMeta(err))
+	}
+	if stack, _ := ErrValue[string](err, PanicStackKey); stack == "" {
+		t.Error("expected panic_stack")
+	}
+	if recoverFrom(func() {}) != nil || RecoverErr(nil) != nil {
+		t.Error("expected nil without a panic")
+	}
+}
+
+func TestPanicErr_RecoverErrRestoresIntact(t *testing.T) {
+	inner := WithErr(NewErr(ErrTest, "user", "alice"), "line", 12)
+	err := recoverFrom(func() { PanicErr(inner) })
+	if !ErrEqual(err, inner) || errors.Is(err, ErrPanic) {
+		t.Errorf("expected the original error back, got %v", err)
+	}
+
+	var recovered any
+	func() {
+		defer func() { recovered = recover() }()
+		PanicErr(inner)
+	}()
+	perr, ok := recovered.(error)
+	if !ok || !errors.Is(perr, ErrTest) || perr.Error() != inner.Error() {
+		t.Errorf("expected an error panic value wrapping the chain, got %#v", recovered)
+	}
+	if !errors.Is(NewErr(ErrOther, "k", 1, perr), perr) {
+		t.Error("expected the panic value to match itself by identity")
+	}
+
+	if recoverFrom(func() { PanicErr(nil) }) != nil {
+		t.Error("expected PanicErr(nil) not to panic")
+	}
+}
+
+var (
+	errDown    = MustRegisterSentinel(errors.New("db down"), "T_DB_DOWN", "")
+	errCorrupt = MustRegisterSentinel(errors.New("data corrupt"), "T_CORRUPT", "")
+)
+
+func TestSentinelDefaults_Propagate(t *testing.T) {
+	if err := SetSentinelDefaults(errDown, SeverityError, RetryAllowed); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetSentinelDefaults(errCorrupt, SeverityCritical, RetryForbidden); err != nil {
+		t.Fatal(err)
+	}
+
+	down := NewErr(ErrTest, "k", 1, NewErr(errDown))
+	if !IsRetryable(down) {
+		t.Error("expected nested RetryAllowed default to make the error retryable")
+	}
+	if got := ErrSeverity(down); got != SeverityError {
+		t.Errorf("expected default severity error, got %v", got)
+	}
+
+	both := errors.Join(down, fmt.Errorf("verify: %w", NewErr(errCorrupt, ErrRetryable)))
+	if IsRetryable(both) {
+		t.Error("expected RetryForbidden anywhere in the chain to win")
+	}
+	if got := ErrSeverity(both); got != SeverityCritical {
+		t.Errorf("expected most severe default, got %v", got)
+	}
+	if got := ErrSeverity(NewErr(ErrTest, SeverityInfo, NewErr(errCorrupt))); got != SeverityCritical {
+		t.Errorf("expected explicit severity not to lower defaults, got %v", got)
+	}
+	if got := ErrSeverity(NewErr(ErrTest, SeverityCritical, NewErr(errDown))); got != SeverityCritical {
+		t.Errorf("expected explicit severity to escalate defaults, got %v", got)
+	}
+
+	if err := SetSentinelDefaults(errors.New("unregistered"), SeverityWarn, RetryUnset); !errors.Is(err, ErrInvalidSentinel) {
+		t.Errorf("expected ErrInvalidSentinel, got %v", err)
+	}
+}
+
+func TestLookupSentinel_NonComparable(t *testing.T) {
+	err := NewErr(ErrTest, NewErr(ErrOther, "k", 1), ErrOther)
+	if code := ErrCode(err); code != "" {
+		t.Errorf("expected no code, got %q", code)
+	}
+	if IsRetryable(err) || ErrSeverity(err) != SeverityUnset {
+		t.Error("expected no classification")
+	}
+	if _, ok := LookupSentinel(NewErr(ErrOther, "k", 1)); ok {
+		t.Error("expected an entry never to be found in the registry")
+	}
+}
+
+func TestWithCause_WithBase(t *testing.T) {
+	base := NewErr(ErrTest, "k", 1)
+	cause := errors.New("disk full")
+
+	withCause := WithCause(base, cause, "attempt", 2)
+	if !errors.Is(withCause, cause) {
+		t.Fatalf("expected cause to be joined, got %v", withCause)
+	}
+	if sentinels := Errors(withCause); len(sentinels) != 1 {
+		t.Errorf("expected cause not to be merged as a sentinel, got %v", sentinels)
+	}
+	if n, _ := ErrValue[int](withCause, "attempt"); n != 2 {
+		t.Errorf("expected attempt=2, got %d", n)
+	}
+
+	withBase := WithBase(base, ErrOther)
+	sentinels := Errors(withBase)
+	if len(sentinels) != 2 || sentinels[1] != ErrOther {
+		t.Errorf("expected ErrOther merged as a sentinel, got %v", sentinels)
+	}
+	if WithCause(nil, nil) != nil || WithBase(nil) != nil {
+		t.Error("expected nil for nil inputs")
+	}
+}
+
+func TestSetStrictWithErr_RejectsAmbiguousForm(t *testing.T) {
+	base := NewErr(ErrTest)
+	if SetStrictWithErr(true) {
+		t.Fatal("expected strict mode to be off by default")
+	}
+	t.Cleanup(func() { SetStrictWithErr(false) })
+
+	err := WithErr(base, ErrOther)
+	if !errors.Is(err, ErrAmbiguousErrors) {
+		t.Fatalf("expected ErrAmbiguousErrors, got %v", err)
+	}
+	if !errors.Is(err, ErrTest) || !errors.Is(err, ErrOther) {
+		t.Error("expected the arguments to be preserved")
+	}
+	if err := WithErr(base, "k", 1, ErrOther); errors.Is(err, ErrAmbiguousErrors) {
+		t.Errorf("expected metadata to disambiguate, got %v", err)
+	}
+	if err := WithCause(base, ErrOther); errors.Is(err, ErrAmbiguousErrors) {
+		t.Errorf("expected WithCause to be accepted, got %v", err)
+	}
+}
+
+func TestErrBudget_TracksRequestErrors(t *testing.T) {
+	ctx, budget := WithErrBudget(context.Background(), 2)
+	if ErrBudgetFrom(ctx) != budget {
+		t.Fatal("expected budget from context")
+	}
+
+	first := TrackErr(ctx, NewErr(ErrTest))
+	if _, ok := ErrValue[int](first, ErrsSoFarKey); ok {
+		t.Error("did not expect errors_so_far on the first error")
+	}
+	second := TrackErr(ctx, NewErr(ErrTest))
+	if n, _ := ErrValue[int](second, ErrsSoFarKey); n != 1 {
+		t.Errorf("expected errors_so_far=1, got %d", n)
+	}
+	if budget.Exceeded() || budget.Err() != nil {
+		t.Error("expected budget to hold at the limit")
+	}
+	_ = TrackErr(ctx, errors.New("third"))
+	if budget.Count() != 3 || !errors.Is(budget.Err(), ErrBudgetExceeded) {
+		t.Errorf("expected exceeded budget, got count=%d err=%v", budget.Count(), budget.Err())
+	}
+	if TrackErr(ctx, nil) != nil || budget.Count() != 3 {
+		t.Error("expected nil errors not to be counted")
+	}
+
+	plain := NewErr(ErrTest)
+	if got := TrackErr(context.Background(), plain); got.Error() != plain.Error() {
+		t.Errorf("expected err unchanged without a budget, got %v", got)
+	}
+	var none *ErrBudget
+	if none.Count() != 0 || none.Exceeded() || none.Err() != nil {
+		t.Error("expected nil budget to be inert")
+	}
+}
+
+func TestAliasSentinel_MatchesOldName(t *testing.T) {
+	errOld := errors.New("user missing")
+	errMid := errors.New("account missing")
+	errNew := errors.New("principal not found")
+	if err := AliasSentinel(errOld, errMid); err != nil {
+		t.Fatal(err)
+	}
+	if err := AliasSentinel(errMid, errNew); err != nil {
+		t.Fatal(err)
+	}
+	if err := AliasSentinel(errNew, errOld); err != nil { // cycles are tolerated
+		t.Fatal(err)
+	}
+
+	err := fmt.Errorf("lookup: %w", NewErr(errNew, "id", 7))
+	for _, target := range []error{errNew, errMid, errOld} {
+		if !errors.Is(err, target) {
+			t.Errorf("expected errors.Is to match %q", target)
+		}
+	}
+	if errors.Is(err, ErrOther) {
+		t.Error("did not expect an unrelated sentinel to match")
+	}
+	if err := AliasSentinel(errOld, errOld); !errors.Is(err, ErrInvalidSentinel) {
+		t.Errorf("expected ErrInvalidSentinel for a self-alias, got %v", err)
+	}
+}
+
+func callerLine(t *testing.T, err error) string {
+	t.Helper()
+	c, ok := ErrValue[string](err, CallerKey)
+	if !ok {
+		t.Fatalf("expected caller on %v", err)
+	}
+	return c
+}
+
+func here(t *testing.T) string {
+	t.Helper()
+	_, file, line, _ := runtime.Caller(1)
+	return fmt.Sprintf("%s/%s:%d", filepath.Base(filepath.Dir(file)), filepath.Base(file), line)
+}
+
+// wrapLoadErr is the kind of thin helper teams write around doterr.
+func wrapLoadErr(cause error) error {
+	return NewErrSkip(1, ErrTest, "op", "load", cause)
+}
+
+func enrichHelper(err error) error {
+	return WithErrSkip(1, err, "helper", true)
+}
+
+func TestCaptureCaller_ReportsUserCallSite(t *testing.T) {
+	if SetCaptureCaller(true) {
+		t.Fatal("expected caller capture to be off by default")
+	}
+	t.Cleanup(func() { SetCaptureCaller(false) })
+
+	err, want := NewErr(ErrTest), here(t)
+	if got := callerLine(t, err); got != want {
+		t.Errorf("NewErr caller = %q, want %q", got, want)
+	}
+	err, want = wrapLoadErr(errors.New("eof")), here(t)
+	if got := callerLine(t, err); got != want {
+		t.Errorf("NewErrSkip caller = %q, want %q", got, want)
+	}
+	err, want = enrichHelper(errors.New("plain")), here(t)
+	if got := callerLine(t, err); got != want {
+		t.Errorf("WithErrSkip caller = %q, want %q", got, want)
+	}
+	err, want = NewOpErr("svc.Run", ErrTest), here(t)
+	if got := callerLine(t, err); got != want {
+		t.Errorf("NewOpErr caller = %q, want %q", got, want)
+	}
+
+	SetCaptureCaller(false)
+	if _, ok := ErrValue[string](NewErr(ErrTest), CallerKey); ok {
+		t.Error("did not expect caller when capture is disabled")
+	}
+}
+
+func TestCaptureTime(t *testing.T) {
+	if SetCaptureTime(true) {
+		t.Fatal("expected time capture to be off by default")
+	}
+	t.Cleanup(func() { SetCaptureTime(false) })
+
+	before := time.Now()
+	inner := NewErr(ErrTest)
+	outer := WithErr(inner, "layer", "outer")
+	wrapped := WithErr(errors.New("plain"), "layer", "wrap")
+	if at, ok := ErrValue[time.Time](inner, TimeKey); !ok || at.Before(before) {
+		t.Errorf("expected NewErr timestamp, got %v, %v", at, ok)
+	}
+	if _, ok := ErrValue[time.Time](wrapped, TimeKey); !ok {
+		t.Error("expected WithErr timestamp on the new entry")
+	}
+	if n := strings.Count(fmt.Sprint(ErrMeta(outer)), TimeKey); n != 1 {
+		t.Errorf("expected enriching an entry to keep its timestamp, found %d", n)
+	}
+
+	SetCaptureTime(false)
+	if _, ok := ErrValue[time.Time](NewErr(ErrTest), TimeKey); ok {
+		t.Error("did not expect timestamp when capture is disabled")
+	}
+}
+
+func TestErrEqual_Structural(t *testing.T) {
+	build := func(ts int, cause string) error {
+		return NewErr(ErrTest, "user", "alice", "timestamp", ts,
+			fmt.Errorf("lookup: %w", NewErr(ErrOther, "instance_id", ts, errors.New(cause))))
+	}
+	a, b := build(1, "eof"), build(2, "eof")
+
+	if ErrEqual(a, b) {
+		t.Error("expected differing metadata to be unequal")
+	}
+	if !ErrEqual(a, b, IgnoreKeys("timestamp", "instance_id")) {
+		t.Errorf("expected equal with volatile keys ignored:\n%v\n%v", a, b)
+	}
+	if ErrEqual(a, build(1, "closed"), IgnoreKeys("timestamp", "instance_id")) {
+		t.Error("expected differing causes to be unequal")
+	}
+	if ErrEqual(NewErr(ErrTest), NewErr(ErrOther)) {
+		t.Error("expected differing sentinels to be unequal")
+	}
+	if !ErrEqual(nil, nil) || ErrEqual(a, nil) {
+		t.Error("unexpected nil handling")
+	}
+
+	SetCaptureCaller(true)
+	c1 := NewErr(ErrTest, "k", 1)
+	c2 := NewErr(ErrTest, "k", 1)
+	SetCaptureCaller(false)
+	if ErrEqual(c1, c2) {
+		t.Error("expected differing call sites to be unequal")
+	}
+	if !ErrEqual(c1, c2, IgnoreStacks()) {
+		t.Error("expected equal with stacks ignored")
+	}
+}
+
+func TestMetaDiff(t *testing.T) {
+	before := NewErr(ErrTest, "user", "alice", "attempt", 1, "region", "us",
+		NewErr(ErrOther, "host", "db1"))
+	after := NewErr(ErrTest, "user", "alice", "attempt", 2,
+		NewErr(ErrOther, "host", "db1", "shard", 7))
+
+	d := MetaDiff(before, after)
+	if len(d.Removed) != 1 || d.Removed[0].Key() != "region" {
+		t.Errorf("unexpected removed %v", d.Removed)
+	}
+	if len(d.Added) != 1 || d.Added[0].Key() != "shard" || d.Added[0].Value() != 7 {
+		t.Errorf("unexpected added %v", d.Added)
+	}
+	if len(d.Changed) != 1 || d.Changed[0] != (MetaChange{Key: "attempt", Old: 1, New: 2}) {
+		t.Errorf("unexpected changed %v", d.Changed)
+	}
+	want := "+shard=7\n-region=us\n~attempt: 1 -> 2\n"
+	if got := d.String(); got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+	if !MetaDiff(before, before).Empty() {
+		t.Error("expected no difference between an error and itself")
+	}
+}
+
+type tenantKey struct{}
+
+func TestSetEnricher(t *testing.T) {
+	prev := SetEnricher(EnricherFunc(func(ctx context.Context) []any {
+		pairs := []any{"region", "eu-west-1"}
+		if tenant, ok := ctx.Value(tenantKey{}).(string); ok {
+			pairs = append(pairs, "tenant", tenant)
+		}
+		return pairs
+	}))
+	t.Cleanup(func() { SetEnricher(prev) })
+
+	err := NewErr(ErrTest)
+	if got, _ := ErrValue[string](err, "region"); got != "eu-west-1" {
+		t.Errorf("expected region from enricher, got %q", got)
+	}
+	ctx := context.WithValue(context.Background(), tenantKey{}, "acme")
+	err = NewErrCtx(ctx, ErrTest, "region", "local")
+	if got, _ := ErrValue[string](err, "tenant"); got != "acme" {
+		t.Errorf("expected tenant from ctx, got %q", got)
+	}
+	if got, _ := ErrValue[string](err, "region"); got != "local" {
+		t.Errorf("expected explicit region to win, got %q", got)
+	}
+	err = WithErrCtx(ctx, errors.New("plain"), "k", 1)
+	if got, _ := ErrValue[string](err, "tenant"); got != "acme" {
+		t.Errorf("expected tenant on WithErrCtx entry, got %q", got)
+	}
+	if n := len(ErrMeta(WithErr(NewErr(ErrTest), "k", 1))); n != 2 {
+		t.Errorf("expected enriching an entry not to re-run the enricher, got %d pairs", n)
+	}
+
+	SetEnricher(nil)
+	if _, ok := ErrValue[string](NewErr(ErrTest), "region"); ok {
+		t.Error("did not expect enrichment after removing the enricher")
+	}
+}
+
+var ErrLookup = NewSentinel("lookup failed")
+
+func TestSentinel_ScopedConstructors(t *testing.T) {
+	err := ErrLookup.New("key", "k1")
+	if !errors.Is(err, ErrLookup) {
+		t.Errorf("expected errors.Is to match the sentinel: %v", err)
+	}
+	if got, _ := ErrValue[string](err, "key"); got != "k1" {
+		t.Errorf("expected key metadata, got %q", got)
+	}
+	if ErrLookup.Wrap(nil, "key", "k1") != nil {
+		t.Error("expected Wrap of a nil cause to be nil")
+	}
+	cause := errors.New("eof")
+	err = ErrLookup.Wrap(cause, "key", "k2")
+	if !errors.Is(err, ErrLookup) || !errors.Is(err, cause) {
+		t.Errorf("expected sentinel and cause: %v", err)
+	}
+	if NewSentinel("lookup failed") == ErrLookup {
+		t.Error("expected distinct sentinels for equal messages")
+	}
+
+	SetCaptureCaller(true)
+	err, want := ErrLookup.New(), here(t)
+	SetCaptureCaller(false)
+	if got := callerLine(t, err); got != want {
+		t.Errorf("Sentinel.New caller = %q, want %q", got, want)
+	}
+}
+
+func TestNewSentinelFunc_MatchesClass(t *testing.T) {
+	errServerSide := NewSentinelFunc("server-side failure", func(err error) bool {
+		status, _ := ErrValue[int](err, "status")
+		return status >= 500
+	})
+	errUnlucky := errors.New("unlucky")
+	errTagged := NewSentinelFunc("tagged", func(err error) bool { return err == errUnlucky })
+
+	if !errors.Is(WithErr(NewErr(ErrTest, "status", 503), "retry", true), errServerSide) {
+		t.Error("expected a 5xx entry to match")
+	}
+	if errors.Is(NewErr(ErrTest, "status", 404), errServerSide) {
+		t.Error("did not expect a 4xx entry to match")
+	}
+	if !errors.Is(NewErr(ErrTest, errUnlucky, "k", "v", errors.New("cause")), errTagged) {
+		t.Error("expected match on one of the entry's sentinels")
+	}
+	if errors.Is(errUnlucky, errTagged) {
+		t.Error("did not expect a bare error outside any entry to match")
+	}
+	if !errors.Is(NewErr(errServerSide), errServerSide) {
+		t.Error("expected identity match to still work")
+	}
+
+	missing := []syscall.Errno{syscall.ENOENT, syscall.ENOTDIR}
+	errMissing := NewSentinelFunc("missing", func(err error) bool {
+		var errno syscall.Errno
+		return errors.As(err, &errno) && slices.Contains(missing, errno)
+	})
+	errRead := errors.New("read failed")
+	if !errors.Is(NewErr(errRead, &os.PathError{Op: "open", Path: "/x", Err: syscall.ENOENT}), errMissing) {
+		t.Error("expected match on an errno wrapped by a held error")
+	}
+	if !errors.Is(NewErr(errRead, "path", "/x", &os.PathError{Op: "open", Path: "/x", Err: syscall.ENOTDIR}), errMissing) {
+		t.Error("expected match on an errno wrapped by the cause")
+	}
+	if !errors.Is(WithErr("path", "/x", fmt.Errorf("open: %w", syscall.ENOENT)), errMissing) {
+		t.Error("expected match on the cause of WithErr without a base")
+	}
+	if errors.Is(NewErr(errRead, &os.PathError{Op: "open", Path: "/x", Err: syscall.EACCES}), errMissing) {
+		t.Error("did not expect an errno outside the set to match")
+	}
+}
+
+func TestPrune_DropsNodesKeepsSentinels(t *testing.T) {
+	debug := NewErr(ErrOther, SeverityDebug, "dump", "huge payload")
+	err := NewErr(ErrTest, "user", "alice", fmt.Errorf("lookup: %w", debug))
+	isDebug := func(n ErrNode) bool { return ErrSeverity(n) == SeverityDebug }
+
+	pruned := Prune(err, isDebug)
+	if !errors.Is(pruned, ErrTest) || !errors.Is(pruned, ErrOther) {
+		t.Errorf("expected sentinels to survive pruning: %v", pruned)
+	}
+	if strings.Contains(pruned.Error(), "huge payload") {
+		t.Errorf("expected debug metadata to be pruned: %v", pruned)
+	}
+	if !strings.Contains(pruned.Error(), "lookup: ") {
+		t.Errorf("expected the wrap layer's text to be kept: %v", pruned)
+	}
+	if got, _ := ErrValue[string](pruned, "user"); got != "alice" {
+		t.Errorf("expected unmatched metadata to be kept, got %q", got)
+	}
+	var nodes int
+	Prune(pruned, func(ErrNode) bool { nodes++; return false })
+	if nodes != 1 {
+		t.Errorf("expected the matched node itself to be dropped, got %d entries", nodes)
+	}
+
+	if kept := Prune(err, func(ErrNode) bool { return false }); kept != err {
+		t.Error("expected err itself when nothing matched")
+	}
+	if Prune(WithErr("k", 1), func(ErrNode) bool { return true }) != nil {
+		t.Error("expected an entry left empty to disappear")
+	}
+	if !IsSealed(Prune(Seal(err), isDebug)) {
+		t.Error("expected a sealed error to stay sealed")
+	}
+}
+
+// customWrap is a foreign wrapper with an Is method of its own.
+type customWrap struct{ err error }
+
+func (w *customWrap) Error() string        { return "custom: " + w.err.Error() }
+func (w *customWrap) Unwrap() error        { return w.err }
+func (w *customWrap) Is(target error) bool { return target == ErrRetryable }
+
+func TestPrune_KeepsForeignWrappers(t *testing.T) {
+	debug := NewErr(ErrOther, SeverityDebug, "dump", "huge payload")
+	wrap := &customWrap{err: debug}
+	err := NewErr(ErrTest, "user", "alice", wrap)
+	pruned := Prune(err, func(n ErrNode) bool { return ErrSeverity(n) == SeverityDebug })
+
+	var got *customWrap
+	if !errors.As(pruned, &got) || got != wrap {
+		t.Errorf("expected the foreign wrapper itself to be kept: %v", pruned)
+	}
+	if !errors.Is(pruned, ErrRetryable) {
+		t.Error("expected the wrapper's Is method to keep working")
+	}
+}
+
+func TestRewriteEntries(t *testing.T) {
+	inner := NewErr(ErrOther, "token", "s3cr3t", "table", "users")
+	err := fmt.Errorf("lookup: %w", NewErr(ErrTest, "user", "alice", inner))
+	got := RewriteEntries(err, func(sentinels []error, meta []KV) ([]error, []KV, bool) {
+		n := len(meta)
+		meta = slices.DeleteFunc(meta, func(m KV) bool { return m.Key() == "token" })
+		return sentinels, meta, len(meta) != n
+	})
+	if strings.Contains(got.Error(), "s3cr3t") || !strings.HasPrefix(got.Error(), "lookup: ") {
+		t.Errorf("expected the token dropped under the wrapper's text, got %q", got)
+	}
+	if !errors.Is(got, ErrTest) || !errors.Is(got, ErrOther) {
+		t.Errorf("expected the sentinels kept, got %v", got)
+	}
+	if !strings.Contains(inner.Error(), "s3cr3t") {
+		t.Error("expected the original error to be left untouched")
+	}
+	if RewriteEntries(err, func(s []error, m []KV) ([]error, []KV, bool) { return s, m, false }) != err {
+		t.Error("expected err itself when nothing changed")
+	}
+	if RewriteEntries(NewErr(ErrTest), func([]error, []KV) ([]error, []KV, bool) { return nil, nil, true }) != nil {
+		t.Error("expected nil when nothing is left")
+	}
+
+	foreign := foreignNode{sentinels: []error{ErrOther}, keys: []string{"token"}, values: []any{"s3cr3t"}}
+	rewritten := RewriteEntries(foreign, func(s []error, m []KV) ([]error, []KV, bool) {
+		return append(s, ErrTest), m, true
+	})
+	enriched := WithErr(rewritten, "attempt", 2)
+	if meta := ErrMeta(enriched); slices.ContainsFunc(meta, func(m KV) bool { return m.Key() == "token" }) {
+		t.Errorf("expected the rewritten foreign entry to stay read-only, got %v", meta)
+	}
+	if !errors.Is(enriched, ErrTest) {
+		t.Errorf("expected the foreign entry rewritten, got %v", enriched)
+	}
+}
+
+func TestCondense(t *testing.T) {
+	err := NewErr(ErrTest, "detail", strings.Repeat("x", 100), fmt.Errorf("read: %w", io.EOF))
+	got := Condense(err, 20)
+	if strings.Contains(got.Error(), "xxxx") || !errors.Is(got, ErrTest) || !errors.Is(got, io.EOF) {
+		t.Errorf("expected a stand-in still matching the error, got %v", got)
+	}
+	if n, _ := ErrValue[int](got, CauseSizeKey); n != len(err.Error()) {
+		t.Errorf("expected %s %d, got %d", CauseSizeKey, len(err.Error()), n)
+	}
+	if Condense(err, 0) != err || Condense(err, len(err.Error())) != err || Condense(nil, 1) != nil {
+		t.Error("expected short errors, nil and a zero limit to pass through")
+	}
+}
+
+func TestSetCauseSizeLimit_SummarizesLargeCauses(t *testing.T) {
+	prev := SetCauseSizeLimit(64)
+	t.Cleanup(func() { SetCauseSizeLimit(prev) })
+
+	big := NewErr(ErrOther, "body", strings.Repeat("x", 1000))
+	err := NewErr(ErrTest, "op", "upload", big)
+	if strings.Contains(err.Error(), "xxxx") {
+		t.Errorf("expected the large cause to be dropped: %.80s", err.Error())
+	}
+	if !errors.Is(err, ErrTest) || !errors.Is(err, ErrOther) {
+		t.Errorf("expected sentinels to survive summarizing: %v", err)
+	}
+	reachable := false
+	for k, v := range MetaStream(err) {
+		reachable = reachable || k == "body" && v == strings.Repeat("x", 1000)
+	}
+	if !reachable {
+		t.Error("expected the cause itself to stay reachable")
+	}
+	kids := err.(interface{ Unwrap() []error }).Unwrap()
+	summary := kids[len(kids)-1]
+	if got, _ := ErrValue[int](summary, CauseSizeKey); got != len(big.Error()) {
+		t.Errorf("expected cause size %d, got %d", len(big.Error()), got)
+	}
+	if got, _ := ErrValue[string](summary, CauseTypeKey); got != "doterr.entry" {
+		t.Errorf("unexpected cause type %q", got)
+	}
+	if got, _ := ErrValue[string](summary, CauseHashKey); len(got) != 16 {
+		t.Errorf("expected a 64-bit hex cause hash, got %q", got)
+	}
+
+	small := errors.New("eof")
+	if err := WithErr(errors.New("base"), "k", 1, small); !errors.Is(err, small) || !strings.HasSuffix(err.Error(), "eof") {
+		t.Errorf("expected a small cause to be retained: %v", err)
+	}
+	for _, target := range []error{context.Canceled, io.EOF, fs.ErrNotExist} {
+		long := fmt.Errorf("%s: %w", strings.Repeat("y", 100), target)
+		if err := NewErr(ErrTest, "k", 1, long); !errors.Is(err, target) || strings.Contains(err.Error(), "yyyy") {
+			t.Errorf("expected %v matched through a summarized cause: %v", target, err)
+		}
+	}
+	SetCauseSizeLimit(0)
+	if err := NewErr(ErrTest, "op", "upload", big); !strings.Contains(err.Error(), "xxxx") {
+		t.Error("expected the cause to be retained with the limit disabled")
+	}
+}
+
+func TestSharedErr_ConcurrentEnrichment(t *testing.T) {
+	shared := NewSharedErr(NewErr(ErrTest, "service", "billing"))
+
+	var wg sync.WaitGroup
+	errs := make([]error, 50)
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = shared.With("worker", i)
+			if i%10 == 0 {
+				shared.Update("generation", i)
+			}
+		}()
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if got, _ := ErrValue[int](err, "worker"); got != i {
+			t.Errorf("error %d carries worker %d", i, got)
+		}
+	}
+	if n := len(ErrMeta(shared.Err())); n != 1+5 {
+		t.Errorf("expected service plus five generation updates, got %v", ErrMeta(shared.Err()))
+	}
+	if _, ok := ErrValue[int](shared.Err(), "worker"); ok {
+		t.Error("expected With to leave the template unchanged")
+	}
+	if ErrMeta(NewSharedErr(nil).With("k", 1))[0].Key() != "k" {
+		t.Error("expected With on an empty template to build an entry")
+	}
+}
+
+func TestAggregateRenderers(t *testing.T) {
+	agg := CombineErrs([]error{
+		NewErr(ErrTest, SeverityWarn, "n", 1),
+		NewErr(ErrOther, SeverityCritical, "n", 2),
+		errors.New("plain"),
+	})
+	full := agg.Error()
+	if strings.Count(full, "\n") != 2 {
+		t.Errorf("expected the full list by default, got %q", full)
+	}
+	if got := RenderAggregate(agg, AggregateFirst()); !strings.HasPrefix(got, "test") || !strings.HasSuffix(got, " (and 2 more errors)") {
+		t.Errorf("unexpected first-failure rendering %q", got)
+	}
+	if got := RenderAggregate(agg, AggregateTopN(2)); !strings.HasPrefix(got, "other") || !strings.HasSuffix(got, " (and 1 more error)") || strings.Contains(got, "\n") {
+		t.Errorf("unexpected top-N rendering %q", got)
+	}
+	if got := RenderAggregate(ErrTest, AggregateFirst()); got != "test" {
+		t.Errorf("expected non-aggregates to render as Error(), got %q", got)
+	}
+
+	members := agg.(interface{ Unwrap() []error }).Unwrap()
+	first := CombineErrs(members, WithRenderer(AggregateFirst()))
+	if got := first.Error(); strings.Contains(got, "\n") || !strings.Contains(got, "2 more") {
+		t.Errorf("expected Error() to follow WithRenderer, got %q", got)
+	}
+	if agg.Error() != full {
+		t.Error("expected other aggregates to keep the full rendering")
+	}
+	if got := RenderAggregate(first, AggregateFull()); got != full {
+		t.Errorf("expected RenderAggregate to override the renderer, got %q", got)
+	}
+}
+
+func TestRedactionModes(t *testing.T) {
+	t.Cleanup(MarkSensitive("email"))
+	err := NewErr(ErrTest, "email", "a@example.com", "user", "alice")
+	if !IsSensitive("email") || IsSensitive("user") {
+		t.Fatal("unexpected sensitivity classification")
+	}
+	if !strings.Contains(err.Error(), "a@example.com") {
+		t.Errorf("expected values as-is with redaction off: %v", err)
+	}
+
+	prev := SetRedactionMode(RedactOn)
+	t.Cleanup(func() { SetRedactionMode(prev) })
+	if got := err.Error(); strings.Contains(got, "a@example.com") || !strings.Contains(got, "email="+RedactedValue) || !strings.Contains(got, "user=alice") {
+		t.Errorf("expected only the sensitive value redacted: %v", got)
+	}
+	if got, _ := ErrValue[string](err, "email"); got != "a@example.com" {
+		t.Errorf("expected programmatic access to be unaffected, got %q", got)
+	}
+
+	var report RedactionReport
+	prevHook := SetRedactionAuditHook(report.Record)
+	t.Cleanup(func() { SetRedactionAuditHook(prevHook) })
+	SetRedactionMode(RedactAudit)
+	_ = err.Error()
+	_ = err.Error()
+	if v := EmitValue("custom", "email", "x"); v != "x" {
+		t.Errorf("expected audit mode not to redact, got %v", v)
+	}
+	sites := report.Sites()
+	want := []RedactionSite{{Emitter: "custom", Key: "email", Count: 1}}
+	if !slices.Equal(sites, want) {
+		t.Errorf("expected only the encoder's emission audited, got %+v", sites)
+	}
+}
+
+func BenchmarkNewErr(b *testing.B) {
+	b.ReportAllocs()
+	for i := range b.N {
+		_ = NewErr(ErrTest, "id", "item", "n", i&0xff)
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	t.Cleanup(MarkSensitive("card"))
+	err := NewErr(ErrTest, ErrRetryable,
+		"order", 42,
+		"card", "4111",
+		UserMessageKey, "We could not charge your card.",
+		HintKey, "Check the card number.",
+		NewErr(ErrOther, "order", 7, HintKey, "Try another card.", HintKey, "Check the card number."),
+	)
+	got := Summarize(err)
+	if got.Message != "test; retryable; other" {
+		t.Errorf("unexpected message %q", got.Message)
+	}
+	if got.UserMessage != "We could not charge your card." {
+		t.Errorf("unexpected user message %q", got.UserMessage)
+	}
+	if !slices.Equal(got.Hints, []string{"Check the card number.", "Try another card."}) {
+		t.Errorf("unexpected hints %q", got.Hints)
+	}
+	if !got.Retryable {
+		t.Error("expected retryable")
+	}
+	if len(got.Meta) != 1 || got.Meta["order"] != 42 {
+		t.Errorf("expected only safe, outermost metadata, got %v", got.Meta)
+	}
+	if got := Summarize(errors.New("dial 10.0.0.7: refused")); got.Message != InternalErrMessage || got.Meta != nil {
+		t.Errorf("unexpected summary of a plain error %+v", got)
+	}
+	held := NewErr(ErrTest, fmt.Errorf("query %q: %w", "secret", ErrOther), TimeKey, time.Now(), CallerKey, "x.go:1", PanicStackKey, "goroutine 1")
+	if got := Summarize(held); got.Message != "test" || got.Meta != nil {
+		t.Errorf("expected foreign text and diagnostics left out, got %+v", got)
+	}
+	if got := Summarize(nil); got.Message != "" || got.Meta != nil || got.Hints != nil {
+		t.Error("expected the zero summary for nil")
+	}
+}
+
+type authKind int
+
+const (
+	authBadPassword authKind = iota
+	authLocked
+	authExpired
+)
+
+type AuthErr = Of[authKind]
+
+var (
+	ErrBadPassword = NewOf(authBadPassword, "bad password")
+	ErrLocked      = NewOf(authLocked, "account locked")
+	ErrExpired     = NewOf(authExpired, "credentials expired")
+)
+
+func TestOf_TypedEnum(t *testing.T) {
+	var _ *AuthErr = ErrLocked
+	err := WithErr(NewErr(ErrLocked, "user", "alice"), "attempts", 5)
+	if !errors.Is(err, ErrLocked) || errors.Is(err, ErrBadPassword) {
+		t.Errorf("expected identity matching: %v", err)
+	}
+	if kind, ok := KindOf[authKind](err); !ok || kind != authLocked {
+		t.Errorf("KindOf = %v, %v", kind, ok)
+	}
+	if got := Members[authKind](); len(got) != 3 || got[0] != ErrBadPassword || got[1] != ErrLocked || got[2] != ErrExpired {
+		t.Errorf("unexpected members %v", got)
+	}
+
+	handlers := map[authKind]func(error) error{
+		authBadPassword: func(error) error { return nil },
+		authLocked:      func(error) error { return ErrOther },
+	}
+	if got := HandleAll(err, handlers); got != ErrOther {
+		t.Errorf("expected the locked handler's result, got %v", got)
+	}
+	if got := HandleAll(ErrTest, handlers); got != ErrTest {
+		t.Errorf("expected errors without a kind unchanged, got %v", got)
+	}
+	if got := HandleAll(error(ErrExpired), handlers); got != ErrExpired {
+		t.Errorf("expected errors without a handler unchanged, got %v", got)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic for a duplicate kind")
+		}
+	}()
+	NewOf(authLocked, "again")
+}
+
+func TestTimed(t *testing.T) {
+	if Timed("test.Ok", func() error { return nil }) != nil {
+		t.Error("expected nil on success")
+	}
+	before := time.Now()
+	err := Timed("test.Slow", func() error {
+		time.Sleep(5 * time.Millisecond)
+		return NewErr(ErrTest, "id", 1)
+	})
+	if !errors.Is(err, ErrTest) {
+		t.Fatalf("expected the cause to be kept: %v", err)
+	}
+	if ms, _ := ErrValue[int64](err, DurationKey); ms < 5 {
+		t.Errorf("duration_ms = %d", ms)
+	}
+	if start, _ := ErrValue[time.Time](err, StartKey); start.Before(before) {
+		t.Errorf("start = %v, before %v", start, before)
+	}
+	if got := ErrOpTrace(err); got != "test.Slow" {
+		t.Errorf("op trace = %q", got)
+	}
+}
+
+func TestHookFailures_AreReported(t *testing.T) {
+	var mu sync.Mutex
+	var got []error
+	prev := SetHookErrHandler(func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		got = append(got, err)
+	})
+	t.Cleanup(func() { SetHookErrHandler(prev) })
+
+	prevEnricher := SetEnricher(EnricherFunc(func(context.Context) []any { panic("enricher broke") }))
+	err := NewErr(ErrTest, "k", "v")
+	SetEnricher(EnricherFunc(func(context.Context) []any { return []any{"region"} }))
+	_ = NewErr(ErrTest)
+	SetEnricher(prevEnricher)
+	if !errors.Is(err, ErrTest) {
+		t.Errorf("expected the error to be built despite the panicking enricher: %v", err)
+	}
+
+	boom := NewErr(ErrOther, "why", "bad")
+	plain := errors.New("escaped")
+	if RunHook("test.subject", plain, func() { panic(boom) }) {
+		t.Error("expected RunHook to report the panic")
+	}
+
+	if len(got) != 3 {
+		t.Fatalf("expected 3 hook failures, got %d: %v", len(got), got)
+	}
+	for _, e := range got {
+		if !errors.Is(e, ErrHookFailed) {
+			t.Errorf("expected ErrHookFailed on %v", e)
+		}
+	}
+	if hook, _ := ErrValue[string](got[0], HookKey); hook != "Enricher" {
+		t.Errorf("unexpected hook %q", hook)
+	}
+	if v, _ := ErrValue[string](got[0], PanicValueKey); v != "enricher broke" {
+		t.Errorf("unexpected panic value %q", v)
+	}
+	if reason, _ := ErrValue[string](got[1], "reason"); reason != "odd number of parts" {
+		t.Errorf("expected the malformed enrichment reported, got %v", got[1])
+	}
+	if hook, _ := ErrValue[string](got[2], HookKey); hook != "test.subject" || !errors.Is(got[2], ErrOther) {
+		t.Errorf("expected the panicking error kept as cause, got %v", got[2])
+	}
+	if fp, _ := ErrValue[string](got[2], HookFingerprintKey); fp != ErrFingerprint(plain) {
+		t.Errorf("expected the subject's fingerprint, got %q", fp)
+	}
+
+	if RunHook("custom", nil, func() {}) != true || RunHook("custom", nil, func() { panic(1) }) != false {
+		t.Error("unexpected RunHook results")
+	}
+}
+
+var hookBurstRun atomic.Int32
+
+func TestHookFailures_RateLimitedAndBounded(t *testing.T) {
+	var got []error
+	var handle func(error)
+	prev := SetHookErrHandler(func(err error) {
+		got = append(got, err)
+		if handle != nil {
+			handle(err)
+		}
+	})
+	t.Cleanup(func() { SetHookErrHandler(prev) })
+
+	// A unique name per run, as the limit outlives the test.
+	name := fmt.Sprintf("test.burst%d", hookBurstRun.Add(1))
+	for range 15 {
+		RunHook(name, nil, func() { panic("broken") })
+	}
+	if len(got) != 10 {
+		t.Errorf("expected a burst of 10 reports, got %d", len(got))
+	}
+
+	// A hook that fails on every hook failure it handles stops at the depth bound.
+	got = nil
+	handle = func(err error) {
+		depth, _ := ErrValue[int](err, HookDepthKey)
+		RunHook(fmt.Sprintf("test.depth%d", depth), err, func() { panic(err) })
+	}
+	RunHook("test.depth", nil, func() { panic("first") })
+	handle = nil
+	if len(got) != 17 {
+		t.Fatalf("expected 17 chained reports, got %d", len(got))
+	}
+	if depth, _ := ErrValue[int](got[16], HookDepthKey); depth != 16 {
+		t.Errorf("unexpected final depth %d", depth)
+	}
+}
+
+var errGroupCoded = MustRegisterSentinel(errors.New("group coded"), "T1014", "")
+
+func TestGroupBy(t *testing.T) {
+	errCoded := errGroupCoded
+	errs := []error{
+		NewErr(ErrTest, "shard", 1),
+		NewErr(errCoded, "shard", 2),
+		nil,
+		NewErr(ErrTest, "shard", 2),
+		errors.New("plain"),
+	}
+
+	bySentinel := GroupBy(errs, BySentinel)
+	if len(bySentinel) != 3 || len(bySentinel["test"]) != 2 || len(bySentinel["plain"]) != 1 {
+		t.Errorf("unexpected sentinel groups %v", bySentinel)
+	}
+	if byCode := GroupBy(errs, ByCode); len(byCode["T1014"]) != 1 || len(byCode[""]) != 3 {
+		t.Errorf("unexpected code groups %v", byCode)
+	}
+	byShard := GroupBy(errs, ByMetaKey("shard"))
+	if len(byShard["2"]) != 2 || !errors.Is(byShard["2"][0], errCoded) || len(byShard[""]) != 1 {
+		t.Errorf("unexpected shard groups %v", byShard)
+	}
+	if byFP := GroupBy(errs, ByFingerprint); len(byFP) != 3 {
+		t.Errorf("expected 3 fingerprint groups, got %d", len(byFP))
+	}
+}
+
+var errQuotaCoded = MustRegisterSentinel(errors.New("quota exceeded"), "E1042", "")
+
+func TestCodePrefix_ParseCode(t *testing.T) {
+	errCoded := errQuotaCoded
+	err := NewErr(errCoded, "tenant", "acme", NewErr(ErrTest))
+
+	if got := err.Error(); strings.Contains(got, "[E1042]") {
+		t.Errorf("did not expect a prefix by default: %q", got)
+	}
+	prev := SetCodePrefix(true)
+	t.Cleanup(func() { SetCodePrefix(prev) })
+	got := err.Error()
+	if !strings.HasPrefix(got, "[E1042] quota exceeded; meta: tenant=acme") || !strings.Contains(got, "\ntest") {
+		t.Errorf("expected only the coded entry prefixed, got %q", got)
+	}
+
+	line := "2026-10-15T12:00:01Z [ERROR] [req 7] " + got
+	if code, ok := ParseCode(line); !ok || code != "E1042" {
+		t.Errorf("ParseCode = %q, %v", code, ok)
+	}
+	for _, bad := range []string{"no code", "[]", "[E1042", "[two words]", "[INFO] started", "[E9999] unregistered"} {
+		if code, ok := ParseCode(bad); ok {
+			t.Errorf("ParseCode(%q) = %q, want none", bad, code)
+		}
+	}
+}
+
+func TestDiag_RetainedOnlyAtLevel(t *testing.T) {
+	build := func() error {
+		return NewErr(ErrTest, "id", 1, Debug("query_plan", "seq scan"), Trace("rows", 42))
+	}
+	if _, ok := ErrValue[string](build(), "query_plan"); ok || DiagEnabled(DiagDebug) {
+		t.Error("expected diagnostic metadata dormant by default")
+	}
+
+	prev := SetDiagLevel(DiagDebug)
+	t.Cleanup(func() { SetDiagLevel(prev) })
+	err := build()
+	if plan, _ := ErrValue[string](err, "query_plan"); plan != "seq scan" {
+		t.Errorf("expected Debug metadata at DiagDebug, got %q", plan)
+	}
+	if _, ok := ErrValue[int](err, "rows"); ok {
+		t.Error("did not expect Trace metadata at DiagDebug")
+	}
+
+	SetDiagLevel(DiagTrace)
+	if rows, _ := ErrValue[int](WithErr(NewErr(ErrTest), Trace("rows", 7)), "rows"); rows != 7 {
+		t.Errorf("expected Trace metadata via WithErr at DiagTrace, got %d", rows)
+	}
+}
+
+func TestChildrenSeq_MetaStream(t *testing.T) {
+	var rows []error
+	for i := range 1000 {
+		rows = append(rows, NewErr(ErrTest, "row", i))
+	}
+	agg := CombineErrs(rows)
+
+	n := 0
+	for child := range ChildrenSeq(agg) {
+		if row, _ := ErrValue[int](child, "row"); row != n {
+			t.Fatalf("child %d has row %d", n, row)
+		}
+		n++
+	}
+	if n != 1000 {
+		t.Errorf("expected 1000 children, got %d", n)
+	}
+	for range ChildrenSeq(NewErr(ErrTest)) {
+		t.Error("expected no children for a non-aggregate")
+	}
+
+	var seen []any
+	for k, v := range MetaStream(agg) {
+		if k != "row" {
+			t.Errorf("unexpected key %q", k)
+		}
+		seen = append(seen, v)
+		if len(seen) == 3 {
+			break
+		}
+	}
+	if !slices.Equal(seen, []any{0, 1, 2}) {
+		t.Errorf("expected the first three rows, got %v", seen)
+	}
+}
+
+func TestAggregateMaxBytes(t *testing.T) {
+	agg := CombineErrs([]error{errors.New("aaaa"), errors.New("bbbb"), errors.New("cccc"), errors.New("dddd")})
+	if got := RenderAggregate(agg, AggregateMaxBytes(10)); got != "aaaa\nbbbb\n(and 2 more errors)" {
+		t.Errorf("unexpected capped rendering %q", got)
+	}
+	if got := RenderAggregate(agg, AggregateMaxBytes(1)); got != "aaaa\n(and 3 more errors)" {
+		t.Errorf("expected at least one member, got %q", got)
+	}
+	if got := RenderAggregate(agg, AggregateMaxBytes(1000)); got != agg.Error() {
+		t.Errorf("expected full rendering under the cap, got %q", got)
+	}
+}
+
+func TestPrimaryCause(t *testing.T) {
+	blip := NewErr(ErrRetryable, SeverityError, "id", 1)
+	warn := NewErr(ErrTest, SeverityWarn, "id", 2)
+	fatal := NewErr(ErrTest, SeverityError, "id", 3)
+	agg := CombineErrs([]error{warn, blip, fatal})
+
+	if got := PrimaryCause(agg); got.Error() != fatal.Error() {
+		t.Errorf("expected the non-retryable error-level member, got %v", got)
+	}
+	ranked := RankCauses(agg, 0)
+	if len(ranked) != 3 || ranked[1].Error() != blip.Error() || ranked[2].Error() != warn.Error() {
+		t.Errorf("unexpected ranking %v", ranked)
+	}
+	if got := RankCauses(agg, 2); len(got) != 2 {
+		t.Errorf("expected the two best, got %v", got)
+	}
+
+	// Nested aggregates are ranked in turn; plain joins count too.
+	nested := errors.Join(errors.New("noise"), CombineErrs([]error{warn, fatal}))
+	if got := PrimaryCause(nested); got.Error() != fatal.Error() {
+		t.Errorf("expected the nested fatal member, got %v", got)
+	}
+
+	// A doterr entry joined with its cause is a chain, not an aggregate.
+	chain := NewErr(ErrTest, "k", "v", errors.New("cause"))
+	if PrimaryCause(chain) != chain || RankCauses(chain, 0) != nil {
+		t.Errorf("expected a chain to be its own primary cause")
+	}
+	if PrimaryCause(nil) != nil {
+		t.Error("expected nil for nil error")
+	}
+
+	prev := SetCauseRanking(func(a, b error) int {
+		ia, _ := ErrValue[int](a, "id")
+		ib, _ := ErrValue[int](b, "id")
+		return ia - ib
+	})
+	t.Cleanup(func() { SetCauseRanking(prev...) })
+	if got := PrimaryCause(agg); got.Error() != blip.Error() {
+		t.Errorf("expected the lowest id under a custom ranking, got %v", got)
+	}
+	SetCauseRanking()
+	if got := PrimaryCause(CombineErrs([]error{warn, warn})); got.Error() != warn.Error() {
+		t.Errorf("expected ties to keep the first member, got %v", got)
+	}
+}
@@ -0,0 +1,95 @@
+// Package doterrarena is a bump allocator for the storage of doterr errors,
+// for batch processors that create thousands of short-lived errors per
+// batch and want to release them together:
+//
+//	arena := doterrarena.New(0)
+//	for _, batch := range batches {
+//	    for _, item := range batch {
+//	        results = append(results, doterr.NewErrWith(arena, ErrInvalid, "id", item.ID))
+//	    }
+//	    flush(results)
+//	    results = results[:0]
+//	    arena.Reset()
+//	}
+//
+// Errors built with an Arena behave exactly like those from doterr.NewErr,
+// and enriching them with doterr.WithErr copies rather than writes into the
+// arena. They must not be used after Reset, which reuses their storage, so
+// they must not escape the batch into anything that keeps errors longer: a
+// doterr.SharedErr, a cache, or doterrstats.Report, whose recent-errors ring
+// and subscribers hold on to what they are given. Report such errors once
+// the batch is done with them, or build them with doterr.NewErr instead.
+package doterrarena
+
+import (
+	"sync"
+
+	"github.com/mikeschinkel/go-doterr"
+)
+
+// defaultSlab is the number of sentinels, and of metadata pairs, a slab
+// holds when New is given no size.
+const defaultSlab = 1024
+
+// Arena is a doterr.Allocator handing out the sentinel and metadata storage
+// of entries from slabs. Only that storage comes from the arena; boxing
+// metadata values that do not fit in an interface word, the error value
+// itself, and the errors.Join that attaches a trailing cause still allocate.
+// An Arena is safe for concurrent use.
+type Arena struct {
+	mu    sync.Mutex
+	size  int
+	errs  []error // current slab; len is the bump offset
+	pairs []doterr.Pair
+}
+
+var _ doterr.Allocator = (*Arena)(nil)
+
+// New returns an Arena whose slabs hold size sentinels and size metadata
+// pairs. A size of zero or less uses a default of 1024.
+func New(size int) *Arena {
+	if size <= 0 {
+		size = defaultSlab
+	}
+	return &Arena{size: size}
+}
+
+// Sentinels implements doterr.Allocator.
+func (a *Arena) Sentinels(n int) []error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return bump(&a.errs, n, a.size)
+}
+
+// Pairs implements doterr.Allocator.
+func (a *Arena) Pairs(n int) []doterr.Pair {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return bump(&a.pairs, n, a.size)
+}
+
+// Reset releases every error built since the previous Reset, keeping the
+// current slab for reuse. Errors built before Reset must no longer be used.
+func (a *Arena) Reset() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	clear(a.errs)
+	clear(a.pairs)
+	a.errs = a.errs[:0]
+	a.pairs = a.pairs[:0]
+}
+
+// bump returns an empty slice with capacity n taken from the end of *slab,
+// starting a new slab of at least size elements when *slab is full. The
+// slice is capped, so appending past n copies to the heap.
+func bump[T any](slab *[]T, n, size int) []T {
+	if n == 0 {
+		return nil
+	}
+	if cap(*slab)-len(*slab) < n {
+		*slab = make([]T, 0, max(size, n))
+	}
+	start := len(*slab)
+	*slab = (*slab)[:start+n]
+	return (*slab)[start : start : start+n]
+}
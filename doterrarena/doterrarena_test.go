@@ -0,0 +1,59 @@
+package doterrarena
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mikeschinkel/go-doterr"
+)
+
+var (
+	errTest  = errors.New("test")
+	errOther = errors.New("other")
+)
+
+func TestArena(t *testing.T) {
+	arena := New(4)
+	var errs []error
+	for i := range 10 {
+		errs = append(errs, doterr.NewErrWith(arena, errTest, "i", i, "name", "x"))
+	}
+	for i, err := range errs {
+		if !errors.Is(err, errTest) {
+			t.Errorf("expected errTest on %v", err)
+		}
+		if got, _ := doterr.ErrValue[int](err, "i"); got != i {
+			t.Errorf("expected i=%d, got %d", i, got)
+		}
+	}
+
+	enriched := doterr.WithErr(errs[0], "extra", true)
+	if got, _ := doterr.ErrValue[int](errs[1], "i"); got != 1 {
+		t.Errorf("expected enrichment not to write into the arena, got i=%d", got)
+	}
+	if _, ok := doterr.ErrValue[bool](errs[0], "extra"); ok {
+		t.Error("expected the arena error itself to be unchanged")
+	}
+	if v, _ := doterr.ErrValue[bool](enriched, "extra"); !v {
+		t.Errorf("expected extra on %v", enriched)
+	}
+
+	if got, want := doterr.NewErrWith(arena, errTest, "k", "v", errors.New("cause")).Error(), doterr.NewErr(errTest, "k", "v", errors.New("cause")).Error(); got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+	arena.Reset()
+	if got, _ := doterr.ErrValue[string](doterr.NewErrWith(arena, errOther, "k", "after"), "k"); got != "after" {
+		t.Errorf("expected a usable arena after Reset, got %q", got)
+	}
+}
+
+func BenchmarkArena(b *testing.B) {
+	arena := New(0)
+	b.ReportAllocs()
+	for i := range b.N {
+		_ = doterr.NewErrWith(arena, errTest, "id", "item", "n", i&0xff)
+		if i%1000 == 999 {
+			arena.Reset()
+		}
+	}
+}
@@ -0,0 +1,162 @@
+// Package doterrblob provides doterr.BlobStore implementations for keeping
+// large error evidence out of band: an in-memory store for tests and
+// short-lived processes, and a directory store that survives restarts.
+// Both are content-addressed, so attaching the same payload twice stores it
+// once.
+package doterrblob
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/mikeschinkel/go-doterr"
+	"github.com/mikeschinkel/go-doterr/doterrwire"
+)
+
+// ErrBlobNotFound is returned by Get for a reference the store does not hold.
+var ErrBlobNotFound = errors.New("blob not found")
+
+// ErrInvalidRef is returned by Get for a reference from another kind of store.
+var ErrInvalidRef = errors.New("invalid blob reference")
+
+// Reference schemes, prefixed to the payload's SHA-256 hex digest.
+const (
+	MemScheme = "mem:"
+	DirScheme = "file:"
+)
+
+// MemStore is an in-memory doterr.BlobStore. The zero value is ready to use.
+type MemStore struct {
+	mu    sync.RWMutex
+	blobs map[string][]byte
+}
+
+var _ doterr.BlobStore = (*MemStore)(nil)
+
+// Put stores a copy of data and returns its reference. name is not used.
+func (s *MemStore) Put(_ string, data []byte) (doterr.BlobRef, error) {
+	digest := digestOf(data)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.blobs == nil {
+		s.blobs = make(map[string][]byte)
+	}
+	if _, ok := s.blobs[digest]; !ok {
+		s.blobs[digest] = append([]byte(nil), data...)
+	}
+	return doterr.BlobRef(MemScheme + digest), nil
+}
+
+// Get returns the payload for ref.
+func (s *MemStore) Get(ref doterr.BlobRef) ([]byte, error) {
+	digest, err := parseRef(ref, MemScheme)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	data, ok := s.blobs[digest]
+	if !ok {
+		return nil, doterr.NewErr(ErrBlobNotFound, "ref", string(ref))
+	}
+	return append([]byte(nil), data...), nil
+}
+
+// DirStore is a doterr.BlobStore that keeps each payload in a file named by
+// its digest under Dir, which is created on first Put.
+type DirStore struct {
+	Dir string
+}
+
+var _ doterr.BlobStore = DirStore{}
+
+// Put writes data under s.Dir and returns its reference. name is not used.
+// The file is written to a temporary name and renamed, so concurrent Puts of
+// the same payload are safe.
+func (s DirStore) Put(_ string, data []byte) (doterr.BlobRef, error) {
+	digest := digestOf(data)
+	ref := doterr.BlobRef(DirScheme + digest)
+	path := filepath.Join(s.Dir, digest)
+	_, err := os.Stat(path)
+	if err == nil {
+		return ref, nil
+	}
+	err = os.MkdirAll(s.Dir, 0o755)
+	if err != nil {
+		return "", err
+	}
+	f, err := os.CreateTemp(s.Dir, digest+".*.tmp")
+	if err != nil {
+		return "", err
+	}
+	_, err = f.Write(data)
+	closeErr := f.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+		return "", err
+	}
+	return ref, nil
+}
+
+// Get reads the payload for ref from s.Dir.
+func (s DirStore) Get(ref doterr.BlobRef) ([]byte, error) {
+	digest, err := parseRef(ref, DirScheme)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(filepath.Join(s.Dir, digest))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, doterr.NewErr(ErrBlobNotFound, "ref", string(ref), err)
+	}
+	return data, err
+}
+
+// PutErr encodes evidence with the named doterrwire codec and stores it, so
+// a whole error (say, the upstream failure behind a retry) can be attached
+// with doterr.Attach. The codec name is passed to store as the blob name.
+func PutErr(store doterr.BlobStore, codec string, evidence error) (doterr.BlobRef, error) {
+	data, err := doterrwire.EncodeWith(codec, evidence)
+	if err != nil {
+		return "", err
+	}
+	return store.Put(codec, data)
+}
+
+// GetErr reverses PutErr; codec must be the one the error was stored with.
+func GetErr(store doterr.BlobStore, codec string, ref doterr.BlobRef) (evidence error, err error) {
+	data, err := store.Get(ref)
+	if err != nil {
+		return nil, err
+	}
+	return doterrwire.DecodeWith(codec, data)
+}
+
+func digestOf(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// parseRef returns the digest of ref, which must use scheme and hold a
+// well-formed hex digest (so it is always safe to use as a file name).
+func parseRef(ref doterr.BlobRef, scheme string) (string, error) {
+	digest, ok := strings.CutPrefix(string(ref), scheme)
+	if ok && len(digest) == 2*sha256.Size {
+		_, err := hex.DecodeString(digest)
+		ok = err == nil
+	}
+	if !ok {
+		return "", doterr.NewErr(ErrInvalidRef, "ref", string(ref), "scheme", scheme)
+	}
+	return digest, nil
+}
@@ -0,0 +1,75 @@
+package doterrblob
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"testing"
+
+	"github.com/mikeschinkel/go-doterr"
+)
+
+var ErrTest = errors.New("upload failed")
+
+func testStore(t *testing.T, store doterr.BlobStore) {
+	t.Helper()
+	body := bytes.Repeat([]byte("evidence "), 1000)
+	ref, err := store.Put("request_body", body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := store.Put("request_body", body)
+	if err != nil || again != ref {
+		t.Errorf("expected identical ref for identical payload, got %q, %v", again, err)
+	}
+
+	attached := doterr.Attach(doterr.NewErr(ErrTest), "request_body", ref)
+	atts := doterr.Attachments(attached)
+	if len(atts) != 1 || atts[0].Name != "request_body" || atts[0].Ref != ref {
+		t.Fatalf("unexpected attachments %v", atts)
+	}
+	got, err := store.Get(atts[0].Ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, body) {
+		t.Errorf("payload mismatch")
+	}
+
+	scheme := string(ref[:len(ref)-2*sha256.Size])
+	_, err = store.Get(doterr.BlobRef(scheme + digestOf([]byte("missing"))))
+	if !errors.Is(err, ErrBlobNotFound) {
+		t.Errorf("expected ErrBlobNotFound, got %v", err)
+	}
+	_, err = store.Get("bogus:../../etc/passwd")
+	if !errors.Is(err, ErrInvalidRef) {
+		t.Errorf("expected ErrInvalidRef, got %v", err)
+	}
+}
+
+func TestMemStore(t *testing.T) {
+	testStore(t, &MemStore{})
+}
+
+func TestDirStore(t *testing.T) {
+	testStore(t, DirStore{Dir: t.TempDir() + "/blobs"})
+}
+
+func TestPutErr_GetErr(t *testing.T) {
+	store := &MemStore{}
+	evidence := doterr.NewErr(ErrTest, "status", 502)
+	ref, err := PutErr(store, "proto", evidence)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := GetErr(store, "proto", ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Error() != evidence.Error() {
+		t.Errorf("unexpected evidence %v", got)
+	}
+	if _, err := PutErr(store, "msgpack", evidence); err == nil {
+		t.Error("expected an error for an unregistered codec")
+	}
+}
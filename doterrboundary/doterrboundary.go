@@ -0,0 +1,300 @@
+// Package doterrboundary governs doterr errors where they leave a layer:
+// Audit reports errors escaping without doterr context, and a Boundary
+// declares what errors look like once they are out:
+//
+//	var apiBoundary = doterrboundary.Boundary{
+//	  Name:        "example.com/app/api",
+//	  StripStacks: true,
+//	  Redact:      true,
+//	  Translate:   doterrboundary.TranslateMap{store.ErrNoRows: ErrNotFound},
+//	  MaxBytes:    4096,
+//	}
+//
+//	return apiBoundary.Apply(err)
+package doterrboundary
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/mikeschinkel/go-doterr"
+)
+
+// Escape is a non-doterr error seen by Audit: an error leaving a layer
+// without doterr context, i.e. a gap in error handling.
+type Escape struct {
+	Boundary string // name given to Audit
+	Caller   string // "dir/file.go:line" of the Audit call
+	Type     string // Go type of the error
+	Err      error
+}
+
+var auditHook atomic.Pointer[func(Escape)]
+
+// SetAuditHook enables audit mode: from then on Audit calls hook for every
+// escaping error that holds no doterr entry anywhere in its tree. Passing
+// nil disables audit mode. Returns the previous hook. Use a Report's Record
+// method as the hook to aggregate escapes into a report.
+func SetAuditHook(hook func(Escape)) func(Escape) {
+	var next *func(Escape)
+	if hook != nil {
+		next = &hook
+	}
+	prev := auditHook.Swap(next)
+	if prev == nil {
+		return nil
+	}
+	return *prev
+}
+
+// Audit marks an exit point of the layer named boundary (typically a package
+// path) and returns err unchanged:
+//
+//	return doterrboundary.Audit("example.com/app/store", err)
+//
+// It costs one atomic load unless audit mode is enabled with SetAuditHook.
+func Audit(boundary string, err error) error {
+	audit(boundary, err, 1)
+	return err
+}
+
+// audit implements Audit; skip counts the frames above its caller to report
+// as the exit point.
+func audit(boundary string, err error, skip int) {
+	hook := auditHook.Load()
+	var node doterr.ErrNode
+	if hook == nil || isNil(err) || errors.As(err, &node) {
+		return
+	}
+	caller := "unknown"
+	_, file, line, ok := runtime.Caller(skip + 1)
+	if ok {
+		caller = shortFile(file) + ":" + strconv.Itoa(line)
+	}
+	escape := Escape{
+		Boundary: boundary,
+		Caller:   caller,
+		Type:     fmt.Sprintf("%T", err),
+		Err:      err,
+	}
+	doterr.RunHook("doterrboundary.AuditHook", err, func() { (*hook)(escape) })
+}
+
+// shortFile trims a source path to its last directory and file name.
+func shortFile(file string) string {
+	if i := strings.LastIndexByte(file, '/'); i >= 0 {
+		if j := strings.LastIndexByte(file[:i], '/'); j >= 0 {
+			return file[j+1:]
+		}
+	}
+	return file
+}
+
+// Site is one escape point in a Report.
+type Site struct {
+	Boundary string
+	Caller   string
+	Type     string
+	Count    int
+}
+
+// Report aggregates Escapes by boundary, call site and error type. The zero
+// value is ready to use and safe for concurrent use:
+//
+//	var report doterrboundary.Report
+//	doterrboundary.SetAuditHook(report.Record)
+type Report struct {
+	mu    sync.Mutex
+	sites map[Site]int
+}
+
+// Record adds e to the report.
+func (r *Report) Record(e Escape) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.sites == nil {
+		r.sites = make(map[Site]int)
+	}
+	r.sites[Site{Boundary: e.Boundary, Caller: e.Caller, Type: e.Type}]++
+}
+
+// Sites returns the escape points recorded so far, most frequent first, then
+// by boundary, caller and type.
+func (r *Report) Sites() []Site {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	sites := make([]Site, 0, len(r.sites))
+	for site, n := range r.sites {
+		site.Count = n
+		sites = append(sites, site)
+	}
+	slices.SortFunc(sites, func(a, b Site) int {
+		if a.Count != b.Count {
+			return b.Count - a.Count
+		}
+		if c := strings.Compare(a.Boundary, b.Boundary); c != 0 {
+			return c
+		}
+		if c := strings.Compare(a.Caller, b.Caller); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Type, b.Type)
+	})
+	return sites
+}
+
+// String renders the report one escape point per line as
+// "count boundary caller type".
+func (r *Report) String() string {
+	var sb strings.Builder
+	for _, site := range r.Sites() {
+		fmt.Fprintf(&sb, "%d %s %s %s\n", site.Count, site.Boundary, site.Caller, site.Type)
+	}
+	return sb.String()
+}
+
+// Translator maps the sentinels of a layer to the ones it exposes, for
+// Boundary.Translate. It returns false to keep a sentinel as it is.
+type Translator interface {
+	Translate(sentinel error) (replacement error, ok bool)
+}
+
+// TranslateMap is a Translator backed by a map from internal to exposed
+// sentinels:
+//
+//	doterrboundary.TranslateMap{store.ErrNoRows: ErrNotFound, store.ErrLocked: ErrUnavailable}
+type TranslateMap map[error]error
+
+func (m TranslateMap) Translate(sentinel error) (error, bool) {
+	if sentinel == nil || !reflect.ValueOf(sentinel).Comparable() {
+		return nil, false
+	}
+	replacement, ok := m[sentinel]
+	return replacement, ok
+}
+
+// Boundary declares what errors look like when they leave a layer, so the
+// policy is written once and every exit point applies it the same way. The
+// zero Boundary changes nothing.
+type Boundary struct {
+	// Name, if set, makes Apply an Audit exit point of that name.
+	Name string
+
+	// StripStacks removes doterr.CallerKey and doterr.PanicStackKey metadata.
+	StripStacks bool
+
+	// Redact replaces sensitive metadata values (see doterr.MarkSensitive)
+	// with doterr.RedactedValue, whatever the doterr.RedactionMode.
+	Redact bool
+
+	// Translate, if set, replaces the sentinels of every entry it maps. A
+	// panic in it is reported with doterr.RunHook and the sentinel kept.
+	Translate Translator
+
+	// MaxBytes, if positive, makes an error whose Error() text would still
+	// be longer render as the stand-in doterr.Condense gives it. The error
+	// stays reachable through Unwrap, so errors.Is and errors.As keep
+	// matching it.
+	MaxBytes int
+}
+
+// ErrWithheld is what Boundary.Apply returns for an error that had nothing
+// left to expose once its policies applied, such as one whose only metadata
+// was a stripped stack.
+var ErrWithheld = errors.New("error details withheld")
+
+// Apply returns err as it should leave the layer: stacks stripped, values
+// redacted, sentinels translated and size capped, in that order, as b
+// configures. Structure is kept as for doterr.RewriteEntries, and err is
+// returned as it is when nothing applies; an error left with nothing to
+// expose becomes ErrWithheld. Returns nil for nil.
+func (b Boundary) Apply(err error) error {
+	if isNil(err) {
+		return nil
+	}
+	if b.Name != "" {
+		audit(b.Name, err, 1)
+	}
+	if b.StripStacks || b.Redact || b.Translate != nil {
+		err = doterr.RewriteEntries(err, b.rewrite)
+		if err == nil {
+			err = ErrWithheld
+		}
+	}
+	if b.MaxBytes > 0 {
+		err = doterr.Condense(err, b.MaxBytes)
+	}
+	return err
+}
+
+// rewrite applies b's metadata and sentinel policies to one entry.
+func (b Boundary) rewrite(sentinels []error, meta []doterr.KV) ([]error, []doterr.KV, bool) {
+	changed := false
+	if b.StripStacks || b.Redact {
+		kept := meta[:0]
+		for _, pair := range meta {
+			switch k := pair.Key(); {
+			case b.StripStacks && (k == doterr.CallerKey || k == doterr.PanicStackKey):
+				changed = true
+				continue
+			case b.Redact && doterr.IsSensitive(k) && pair.Value() != doterr.RedactedValue:
+				pair = redacted(k)
+				changed = true
+			}
+			kept = append(kept, pair)
+		}
+		meta = kept
+	}
+	if b.Translate != nil {
+		for i, sentinel := range sentinels {
+			if composite(sentinel) {
+				continue
+			}
+			var replacement error
+			var ok bool
+			doterr.RunHook("doterrboundary.Translator", sentinel, func() { replacement, ok = b.Translate.Translate(sentinel) })
+			if ok && replacement != nil {
+				sentinels[i] = replacement
+				changed = true
+			}
+		}
+	}
+	return sentinels, meta, changed
+}
+
+// redacted is a metadata pair holding doterr.RedactedValue.
+type redacted string
+
+func (r redacted) Key() string { return string(r) }
+func (r redacted) Value() any  { return doterr.RedactedValue }
+
+// composite reports whether err wraps other errors, i.e. is a cause stored
+// among an entry's sentinels rather than a sentinel itself.
+func composite(err error) bool {
+	switch err.(type) {
+	case doterr.ErrNode, interface{ Unwrap() []error }, interface{ Unwrap() error }:
+		return true
+	}
+	return false
+}
+
+// isNil reports whether err is nil, including a typed nil: a nil pointer
+// stored in an error interface.
+func isNil(err error) bool {
+	if err == nil {
+		return true
+	}
+	v := reflect.ValueOf(err)
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Map, reflect.Slice, reflect.Func, reflect.Chan:
+		return v.IsNil()
+	}
+	return false
+}
@@ -0,0 +1,156 @@
+package doterrboundary
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mikeschinkel/go-doterr"
+)
+
+var (
+	errTest  = errors.New("test")
+	errOther = errors.New("other")
+)
+
+func storeLoad(fail error) error {
+	return Audit("app/store", fail)
+}
+
+func TestAudit_ReportsUnwrappedEscapes(t *testing.T) {
+	plain := errors.New("disk full")
+	if storeLoad(plain) != plain {
+		t.Fatal("expected Audit to return err unchanged")
+	}
+
+	var report Report
+	prev := SetAuditHook(report.Record)
+	t.Cleanup(func() { SetAuditHook(prev) })
+
+	_ = storeLoad(plain)
+	_ = storeLoad(plain)
+	_ = storeLoad(nil)
+	_ = storeLoad(doterr.NewErr(errTest, plain))
+	_ = storeLoad(fmt.Errorf("load: %w", doterr.NewErr(errTest)))
+
+	sites := report.Sites()
+	if len(sites) != 1 {
+		t.Fatalf("expected one escape point, got %+v", sites)
+	}
+	site := sites[0]
+	if site.Boundary != "app/store" || site.Type != "*errors.errorString" || site.Count != 2 {
+		t.Errorf("unexpected site %+v", site)
+	}
+	if !strings.Contains(site.Caller, "doterrboundary_test.go:") {
+		t.Errorf("expected the call site in doterrboundary_test.go, got %q", site.Caller)
+	}
+	if !strings.HasPrefix(report.String(), "2 app/store ") {
+		t.Errorf("unexpected report %q", report.String())
+	}
+
+	var tied Report
+	for _, typ := range []string{"*b", "*a", "*c"} {
+		tied.Record(Escape{Boundary: "app/store", Caller: "x.go:1", Type: typ})
+	}
+	for range 5 {
+		if got := tied.String(); got != "1 app/store x.go:1 *a\n1 app/store x.go:1 *b\n1 app/store x.go:1 *c\n" {
+			t.Fatalf("expected ties ordered by type, got %q", got)
+		}
+	}
+}
+
+func TestBoundary_Apply(t *testing.T) {
+	ErrNoRows := errors.New("no rows")
+	ErrNotFound := errors.New("not found")
+	t.Cleanup(doterr.MarkSensitive("bnd_token"))
+	doterr.SetCaptureCaller(true)
+	t.Cleanup(func() { doterr.SetCaptureCaller(false) })
+
+	inner := doterr.NewErr(ErrNoRows, "table", "users", "bnd_token", "s3cr3t")
+	err := fmt.Errorf("lookup: %w", doterr.WithErr(inner, "bnd_token", "again"))
+	b := Boundary{
+		StripStacks: true,
+		Redact:      true,
+		Translate:   TranslateMap{ErrNoRows: ErrNotFound, errors.New("unused"): errTest},
+	}
+	got := b.Apply(err)
+	value := func(err error, key string) (any, bool) {
+		for k, v := range doterr.MetaStream(err) {
+			if k == key {
+				return v, true
+			}
+		}
+		return nil, false
+	}
+	if !errors.Is(got, ErrNotFound) || errors.Is(got, ErrNoRows) {
+		t.Errorf("expected the sentinel translated, got %v", got)
+	}
+	if msg := got.Error(); strings.Contains(msg, "s3cr3t") || strings.Contains(msg, "again") || !strings.HasPrefix(msg, "lookup: ") {
+		t.Errorf("expected redacted values under the wrapper's text, got %q", msg)
+	}
+	if _, ok := value(got, doterr.CallerKey); ok {
+		t.Errorf("expected %s to be stripped from %v", doterr.CallerKey, got)
+	}
+	if v, _ := value(got, "table"); v != "users" {
+		t.Errorf("expected other metadata kept, got %v", v)
+	}
+	if _, ok := value(inner, doterr.CallerKey); !ok || !strings.Contains(inner.Error(), "s3cr3t") {
+		t.Error("expected the original error to be left untouched")
+	}
+
+	if (Boundary{}).Apply(err) != err || b.Apply(nil) != nil {
+		t.Error("expected the zero Boundary and a nil error to pass through")
+	}
+	plain := errors.New("plain")
+	if b.Apply(plain) != plain {
+		t.Error("expected an error without entries to pass through")
+	}
+
+	capped := Boundary{MaxBytes: 20}.Apply(doterr.NewErr(ErrNotFound, "detail", strings.Repeat("x", 100)))
+	if len(capped.Error()) > 100 || !errors.Is(capped, ErrNotFound) {
+		t.Errorf("expected a capped stand-in matching the sentinel, got %v", capped)
+	}
+	if n, _ := doterr.ErrValue[int](capped, doterr.CauseSizeKey); n <= 20 {
+		t.Errorf("expected %s on the stand-in, got %d", doterr.CauseSizeKey, n)
+	}
+	eof := Boundary{MaxBytes: 20}.Apply(doterr.NewErr(ErrNotFound, "k", 1, fmt.Errorf("read %s: %w", strings.Repeat("x", 100), io.EOF)))
+	if len(eof.Error()) > 100 || !errors.Is(eof, io.EOF) {
+		t.Errorf("expected a capped error still matching its cause, got %v", eof)
+	}
+
+	if got := b.Apply(doterr.WithErr(doterr.CallerKey, "main.go:1")); got != ErrWithheld {
+		t.Errorf("expected ErrWithheld for an error stripped bare, got %v", got)
+	}
+
+	var report Report
+	prev := SetAuditHook(report.Record)
+	t.Cleanup(func() { SetAuditHook(prev) })
+	_ = Boundary{Name: "app/api"}.Apply(plain)
+	sites := report.Sites()
+	if len(sites) != 1 || sites[0].Boundary != "app/api" || !strings.HasPrefix(filepath.Base(sites[0].Caller), "doterrboundary_test.go:") {
+		t.Errorf("expected the Apply call site audited, got %+v", sites)
+	}
+}
+
+func TestAudit_ReportsHookPanics(t *testing.T) {
+	var got []error
+	prevHandler := doterr.SetHookErrHandler(func(err error) { got = append(got, err) })
+	t.Cleanup(func() { doterr.SetHookErrHandler(prevHandler) })
+
+	boom := doterr.NewErr(errOther, "why", "bad")
+	prev := SetAuditHook(func(Escape) { panic(boom) })
+	t.Cleanup(func() { SetAuditHook(prev) })
+	plain := errors.New("escaped")
+	if Audit("app/x", plain) != plain {
+		t.Error("expected Audit to return err unchanged")
+	}
+	if len(got) != 1 {
+		t.Fatalf("expected 1 hook failure, got %v", got)
+	}
+	if hook, _ := doterr.ErrValue[string](got[0], doterr.HookKey); hook != "doterrboundary.AuditHook" || !errors.Is(got[0], errOther) {
+		t.Errorf("expected the panicking error kept as cause, got %v", got[0])
+	}
+}
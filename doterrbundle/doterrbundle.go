@@ -0,0 +1,293 @@
+// Package doterrbundle curates a batch of errors into a single zip archive
+// suitable for attaching to a support ticket:
+//
+//	data, err := doterrbundle.BuildSupportBundle(failures, doterrbundle.Options{Note: ticketID})
+//
+// The archive is assembled from doterr's own structures instead of ad-hoc
+// scripts: each distinct error (by doterr.ErrFingerprint) appears once, as
+// its doterrwire JSON tree and as a rendered tree, alongside an excerpt of
+// the sentinel catalog for the codes involved. Sensitive metadata (see
+// doterr.MarkSensitive) is always redacted, whatever the process's
+// RedactionMode, and the archive's content is bounded by Options.MaxBytes.
+// Sensitive values of six bytes or more are also removed where they appear
+// verbatim in wrapped messages; text that formats them differently is not
+// caught.
+//
+// Layout:
+//
+//	manifest.json        Manifest
+//	catalog.json         []CatalogEntry for every code in the included errors
+//	errors/001.json      doterrwire.Node, redacted
+//	errors/001.txt       the same tree rendered by Node.String
+package doterrbundle
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mikeschinkel/go-doterr"
+	"github.com/mikeschinkel/go-doterr/doterrwire"
+)
+
+// DefaultMaxBytes is the content bound used when Options.MaxBytes is zero.
+const DefaultMaxBytes = 1 << 20
+
+// ErrBundleTooSmall is returned when Options.MaxBytes cannot hold even the
+// manifest and the first error; "max_bytes" and "needed" are attached.
+var ErrBundleTooSmall = errors.New("support bundle size limit too small")
+
+// Options configure BuildSupportBundle.
+type Options struct {
+	// MaxBytes bounds the total uncompressed size of the archive's files.
+	// Errors that would exceed it are left out and counted in
+	// Manifest.Omitted. Zero means DefaultMaxBytes.
+	MaxBytes int
+
+	// Note is copied into the manifest, e.g. a ticket ID or a description.
+	Note string
+}
+
+// Manifest describes a bundle; it is stored as manifest.json.
+type Manifest struct {
+	Created  time.Time `json:"created"`
+	Note     string    `json:"note,omitempty"`
+	Errors   []Summary `json:"errors"`
+	Omitted  int       `json:"omitted,omitempty"` // distinct errors left out by MaxBytes
+	Redacted []string  `json:"redacted,omitempty"`
+}
+
+// Summary is one distinct error in a Manifest.
+type Summary struct {
+	File        string   `json:"file"` // without extension
+	Fingerprint string   `json:"fingerprint"`
+	Count       int      `json:"count"` // occurrences in the input
+	Codes       []string `json:"codes,omitempty"`
+}
+
+// CatalogEntry is a registered sentinel referenced by the bundle.
+type CatalogEntry struct {
+	Code     string `json:"code"`
+	Name     string `json:"name"`
+	Message  string `json:"message"`
+	Severity string `json:"severity,omitempty"`
+	Retry    string `json:"retry,omitempty"`
+}
+
+// now is the manifest clock, replaced in tests.
+var now = time.Now
+
+// distinct is one fingerprint's exemplar and occurrence count.
+type distinct struct {
+	err         error
+	fingerprint string
+	count       int
+}
+
+// BuildSupportBundle returns a zip archive of errs as described in the
+// package documentation. Nil errors are skipped. Errors are included in
+// order of first appearance until Options.MaxBytes is reached.
+func BuildSupportBundle(errs []error, opts Options) ([]byte, error) {
+	maxBytes := opts.MaxBytes
+	if maxBytes <= 0 {
+		maxBytes = DefaultMaxBytes
+	}
+	var groups []*distinct
+	byFingerprint := make(map[string]*distinct)
+	for _, err := range errs {
+		if err == nil {
+			continue
+		}
+		fp := doterr.ErrFingerprint(err)
+		d, ok := byFingerprint[fp]
+		if !ok {
+			d = &distinct{err: err, fingerprint: fp}
+			byFingerprint[fp] = d
+			groups = append(groups, d)
+		}
+		d.count++
+	}
+
+	manifest := Manifest{Created: now().UTC(), Note: opts.Note, Errors: []Summary{}}
+	var codes []string
+	files := map[string][]byte{}
+	var order []string
+	size := 0 // of the error files so far
+	for i, d := range groups {
+		node, keys := redact(d.err)
+		tree, err := json.MarshalIndent(node, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		text := []byte(node.String())
+		summary := Summary{
+			File:        fmt.Sprintf("errors/%03d", len(manifest.Errors)+1),
+			Fingerprint: d.fingerprint,
+			Count:       d.count,
+			Codes:       codesOf(node),
+		}
+		next := manifest
+		next.Errors = append(slices.Clip(manifest.Errors), summary)
+		next.Redacted = merge(manifest.Redacted, keys)
+		next.Omitted = len(groups) // widest value it can take
+		nextCodes := merge(codes, summary.Codes)
+		needed := size + len(tree) + len(text) + jsonSize(next) + jsonSize(catalogOf(nextCodes))
+		if needed > maxBytes {
+			if i == 0 {
+				return nil, doterr.NewErr(ErrBundleTooSmall, "max_bytes", maxBytes, "needed", needed)
+			}
+			manifest.Omitted = len(groups) - i
+			break
+		}
+		size += len(tree) + len(text)
+		manifest.Errors, manifest.Redacted, codes = next.Errors, next.Redacted, nextCodes
+		files[summary.File+".json"] = tree
+		files[summary.File+".txt"] = text
+		order = append(order, summary.File+".json", summary.File+".txt")
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	err := writeJSON(zw, "manifest.json", manifest)
+	if err == nil {
+		err = writeJSON(zw, "catalog.json", catalogOf(codes))
+	}
+	for _, name := range order {
+		if err != nil {
+			break
+		}
+		err = writeFile(zw, name, files[name])
+	}
+	if err == nil {
+		err = zw.Close()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// minScrubLen is the shortest sensitive value redact removes from messages;
+// shorter ones, such as "1", would mangle unrelated text.
+const minScrubLen = 6
+
+// redact encodes err and replaces every sensitive metadata value with
+// doterr.RedactedValue, as well as verbatim occurrences of values of at least
+// minScrubLen bytes inside wrapped messages. It returns the tree and the
+// sensitive keys it found.
+func redact(err error) (*doterrwire.Node, []string) {
+	var keys, values []string
+	for k, v := range doterr.MetaStream(err) {
+		if !doterr.IsSensitive(k) {
+			continue
+		}
+		keys = append(keys, k)
+		if s := fmt.Sprint(v); len(s) >= minScrubLen && s != doterr.RedactedValue {
+			values = append(values, s)
+		}
+	}
+	node := doterrwire.Encode(err)
+	var scrub func(n *doterrwire.Node)
+	scrub = func(n *doterrwire.Node) {
+		for _, v := range values {
+			n.Message = strings.ReplaceAll(n.Message, v, doterr.RedactedValue)
+		}
+		for i, f := range n.Meta {
+			if doterr.IsSensitive(f.Key) {
+				n.Meta[i] = doterrwire.Field{Key: f.Key, Value: doterr.RedactedValue}
+			}
+		}
+		for _, child := range n.Children {
+			scrub(child)
+		}
+	}
+	scrub(node)
+	return node, keys
+}
+
+// codesOf returns the registered codes of every sentinel in the tree, in
+// order of first appearance.
+func codesOf(n *doterrwire.Node) []string {
+	var codes []string
+	var walk func(n *doterrwire.Node)
+	walk = func(n *doterrwire.Node) {
+		for _, s := range n.Sentinels {
+			if s.Code != "" && !slices.Contains(codes, s.Code) {
+				codes = append(codes, s.Code)
+			}
+		}
+		for _, child := range n.Children {
+			walk(child)
+		}
+	}
+	walk(n)
+	return codes
+}
+
+// catalogOf returns the catalog excerpt for codes; never nil, so it encodes
+// as [].
+func catalogOf(codes []string) []CatalogEntry {
+	catalog := []CatalogEntry{}
+	for _, code := range codes {
+		catalog = append(catalog, catalogEntry(code))
+	}
+	return catalog
+}
+
+func catalogEntry(code string) CatalogEntry {
+	info, _ := doterr.LookupCode(code)
+	entry := CatalogEntry{Code: info.Code, Name: info.Name}
+	if info.Sentinel != nil {
+		entry.Message = info.Sentinel.Error()
+	}
+	if info.Severity != doterr.SeverityUnset {
+		entry.Severity = info.Severity.String()
+	}
+	switch info.Retry {
+	case doterr.RetryAllowed:
+		entry.Retry = "allowed"
+	case doterr.RetryForbidden:
+		entry.Retry = "forbidden"
+	}
+	return entry
+}
+
+// merge returns the sorted union of a and b without modifying a.
+func merge(a, b []string) []string {
+	out := slices.Clone(a)
+	for _, s := range b {
+		if !slices.Contains(out, s) {
+			out = append(out, s)
+		}
+	}
+	slices.Sort(out)
+	return out
+}
+
+// jsonSize is the indented size of v as written to the archive.
+func jsonSize(v any) int {
+	b, _ := json.MarshalIndent(v, "", "  ")
+	return len(b)
+}
+
+func writeJSON(zw *zip.Writer, name string, v any) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFile(zw, name, b)
+}
+
+func writeFile(zw *zip.Writer, name string, data []byte) error {
+	w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: now().UTC()})
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
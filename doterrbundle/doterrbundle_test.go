@@ -0,0 +1,131 @@
+package doterrbundle
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/mikeschinkel/go-doterr"
+)
+
+var ErrCharge = doterr.MustRegisterSentinel(errors.New("charge failed"), "BUNDLE_CHARGE", "charge_failed")
+
+func init() {
+	doterr.MarkSensitive("bundle_email")
+}
+
+func readBundle(t *testing.T, data []byte) map[string][]byte {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string][]byte{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[f.Name], err = io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return files
+}
+
+func TestBuildSupportBundle(t *testing.T) {
+	charge := func(email string) error {
+		return fmt.Errorf("checkout: %w", doterr.NewErr(ErrCharge, "bundle_email", email, "amount", 42))
+	}
+	errs := []error{charge("a@example.com"), nil, charge("b@example.com"), errors.New("disk full")}
+
+	data, err := BuildSupportBundle(errs, Options{Note: "TICKET-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := readBundle(t, data)
+	for name, content := range files {
+		if bytes.Contains(content, []byte("@example.com")) {
+			t.Errorf("%s leaks a sensitive value:\n%s", name, content)
+		}
+	}
+
+	var m Manifest
+	if err := json.Unmarshal(files["manifest.json"], &m); err != nil {
+		t.Fatal(err)
+	}
+	if m.Note != "TICKET-1" || len(m.Errors) != 2 || m.Omitted != 0 {
+		t.Fatalf("unexpected manifest %+v", m)
+	}
+	if m.Errors[0].Count != 2 || m.Errors[1].Count != 1 || m.Errors[0].Codes[0] != "BUNDLE_CHARGE" {
+		t.Errorf("unexpected summaries %+v", m.Errors)
+	}
+	if len(m.Redacted) != 1 || m.Redacted[0] != "bundle_email" {
+		t.Errorf("unexpected redacted keys %v", m.Redacted)
+	}
+	var catalog []CatalogEntry
+	if err := json.Unmarshal(files["catalog.json"], &catalog); err != nil {
+		t.Fatal(err)
+	}
+	if len(catalog) != 1 || catalog[0] != (CatalogEntry{Code: "BUNDLE_CHARGE", Name: "charge_failed", Message: "charge failed"}) {
+		t.Errorf("unexpected catalog %+v", catalog)
+	}
+	if !strings.Contains(string(files["errors/001.txt"]), "amount=42") || files["errors/002.json"] == nil {
+		t.Errorf("unexpected error files %v", files)
+	}
+}
+
+func TestBuildSupportBundle_MaxBytes(t *testing.T) {
+	var errs []error
+	for i := range 20 {
+		// Distinct keys give distinct fingerprints.
+		errs = append(errs, doterr.NewErr(ErrCharge, fmt.Sprintf("order_%d", i), strings.Repeat("x", 200)))
+	}
+	const limit = 4096
+	data, err := BuildSupportBundle(errs, Options{MaxBytes: limit})
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := readBundle(t, data)
+	total := 0
+	for _, content := range files {
+		total += len(content)
+	}
+	if total > limit {
+		t.Errorf("content is %d bytes, over the %d limit", total, limit)
+	}
+	var m Manifest
+	if err := json.Unmarshal(files["manifest.json"], &m); err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Errors) == 0 || len(m.Errors)+m.Omitted != len(errs) {
+		t.Errorf("expected %d errors split between included and omitted, got %d and %d", len(errs), len(m.Errors), m.Omitted)
+	}
+
+	_, err = BuildSupportBundle(errs, Options{MaxBytes: 100})
+	if !errors.Is(err, ErrBundleTooSmall) {
+		t.Errorf("expected ErrBundleTooSmall, got %v", err)
+	}
+}
+
+func TestBuildSupportBundle_ShortSensitiveValue(t *testing.T) {
+	err := fmt.Errorf("retry 1 of 3: %w", doterr.NewErr(ErrCharge, "bundle_email", "1"))
+	data, berr := BuildSupportBundle([]error{err}, Options{})
+	if berr != nil {
+		t.Fatal(berr)
+	}
+	text := string(readBundle(t, data)["errors/001.txt"])
+	if !strings.Contains(text, "retry 1 of 3") {
+		t.Errorf("expected messages left intact by a short value:\n%s", text)
+	}
+	if !strings.Contains(text, "bundle_email="+doterr.RedactedValue) {
+		t.Errorf("expected the metadata value redacted:\n%s", text)
+	}
+}
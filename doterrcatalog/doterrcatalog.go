@@ -0,0 +1,73 @@
+// Package doterrcatalog lints the doterr sentinel registry for catalog
+// hygiene, meant to run in a test so problems surface as the vocabulary
+// grows:
+//
+//	func TestCatalog(t *testing.T) {
+//	    if err := doterrcatalog.Validate(doterrhttp.CheckStatus); err != nil {
+//	        t.Fatal(err)
+//	    }
+//	}
+package doterrcatalog
+
+import (
+	"errors"
+
+	"github.com/mikeschinkel/go-doterr"
+)
+
+// Sentinels for catalog problems reported by Validate. Each problem also
+// carries ErrProblem.
+var (
+	ErrProblem         = errors.New("catalog problem")
+	ErrOrphanedCode    = errors.New("code aliased to an unregistered sentinel")
+	ErrMissingMessage  = errors.New("sentinel has no message")
+	ErrMissingSeverity = errors.New("sentinel has no default severity")
+)
+
+// Check inspects one registration for Validate and returns the problem
+// found, or nil.
+type Check func(doterr.SentinelInfo) error
+
+// Validate checks every registered sentinel (see doterr.RegisterSentinel).
+// Every registration is checked for an empty message (ErrMissingMessage), a
+// doterr.SeverityUnset default (ErrMissingSeverity, see
+// doterr.SetSentinelDefaults) and an alias to an unregistered replacement,
+// which leaves its code on no new error (ErrOrphanedCode, see
+// doterr.AliasSentinel), and then with each of checks. Problems are returned
+// as a doterr.CombineErrs aggregate of entries carrying ErrProblem, "code"
+// and "name", in registration order; a problem from checks is the entry's
+// trailing cause. Returns nil if there are none.
+func Validate(checks ...Check) error {
+	var problems []error
+	for _, info := range doterr.RegisteredSentinels() {
+		if info.Sentinel.Error() == "" {
+			problems = append(problems, problem(info, ErrMissingMessage))
+		}
+		if info.Severity == doterr.SeverityUnset {
+			problems = append(problems, problem(info, ErrMissingSeverity))
+		}
+		for _, replacement := range doterr.SentinelAliases(info.Sentinel) {
+			if _, ok := doterr.LookupSentinel(replacement); !ok {
+				problems = append(problems, problem(info, ErrOrphanedCode, "replacement", replacement.Error()))
+			}
+		}
+		for _, check := range checks {
+			err := check(info)
+			if err != nil {
+				problems = append(problems, problem(info, nil, err))
+			}
+		}
+	}
+	return doterr.CombineErrs(problems)
+}
+
+// problem builds a Validate problem entry for info, adding sentinel (if
+// non-nil) and then rest, which may end with a cause.
+func problem(info doterr.SentinelInfo, sentinel error, rest ...any) error {
+	parts := []any{ErrProblem}
+	if sentinel != nil {
+		parts = append(parts, sentinel)
+	}
+	parts = append(parts, "code", info.Code, "name", info.Name)
+	return doterr.NewErr(append(parts, rest...)...)
+}
@@ -0,0 +1,53 @@
+package doterrcatalog
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mikeschinkel/go-doterr"
+)
+
+var (
+	errCatalogSilent       = doterr.MustRegisterSentinel(errors.New(""), "T1007A", "catalog_silent")
+	errCatalogGood         = doterr.MustRegisterSentinel(errors.New("catalog good"), "T1007B", "")
+	errCatalogOld          = doterr.MustRegisterSentinel(errors.New("catalog old"), "T1007C", "")
+	_                      = doterr.MustRegisterSentinel(errors.New("catalog no severity"), "T1007D", "")
+	errCatalogUnregistered = errors.New("catalog unregistered")
+)
+
+func TestValidate(t *testing.T) {
+	for _, s := range []error{errCatalogSilent, errCatalogGood, errCatalogOld} {
+		_ = doterr.SetSentinelDefaults(s, doterr.SeverityError, doterr.RetryUnset)
+	}
+	if err := doterr.AliasSentinel(errCatalogOld, errCatalogUnregistered); err != nil {
+		t.Fatal(err)
+	}
+	errFlagged := errors.New("flagged by check")
+	check := func(info doterr.SentinelInfo) error {
+		if info.Code == "T1007B" {
+			return errFlagged
+		}
+		return nil
+	}
+
+	found := map[string][]error{}
+	for _, p := range Validate(check).(interface{ Unwrap() []error }).Unwrap() {
+		if !errors.Is(p, ErrProblem) {
+			t.Errorf("expected ErrProblem on %v", p)
+		}
+		code, _ := doterr.ErrValue[string](p, "code")
+		found[code] = append(found[code], p)
+	}
+	expect := map[string]error{"T1007A": ErrMissingMessage, "T1007B": errFlagged, "T1007C": ErrOrphanedCode}
+	for code, want := range expect {
+		if len(found[code]) != 1 || !errors.Is(found[code][0], want) {
+			t.Errorf("%s: expected one %q problem, got %v", code, want, found[code])
+		}
+	}
+	if got, _ := doterr.ErrValue[string](found["T1007C"][0], "replacement"); got != "catalog unregistered" {
+		t.Errorf("unexpected replacement %q", got)
+	}
+	if len(found["T1007D"]) != 1 || !errors.Is(found["T1007D"][0], ErrMissingSeverity) {
+		t.Errorf("expected a severity gap for sentinels without defaults, got %v", found["T1007D"])
+	}
+}
@@ -0,0 +1,81 @@
+// Package doterrchi plugs doterrhttp into go-chi/chi routers. chi handlers and
+// middleware are plain net/http types, so the package has no chi dependency:
+//
+//	r := chi.NewRouter()
+//	r.Use(middleware.RequestID, doterrchi.Recoverer)
+//	r.NotFound(doterrchi.NotFound)
+//	r.MethodNotAllowed(doterrchi.MethodNotAllowed)
+//	r.Method(http.MethodGet, "/users/{id}", doterrchi.Handler(getUser))
+//
+// Failures are rendered as problem details with doterrhttp.WriteProblem. Call
+// UseChiRequestID with chi's middleware.GetReqID so the ID chi assigned is the
+// one echoed back.
+package doterrchi
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/mikeschinkel/go-doterr"
+	"github.com/mikeschinkel/go-doterr/doterrhttp"
+)
+
+// HandlerFunc is a chi handler that may fail; see Handler.
+type HandlerFunc func(http.ResponseWriter, *http.Request) error
+
+// Handler adapts fn to an http.Handler. A non-nil returned error is counted
+// against the request's doterr.ErrBudget, if any, and rendered with
+// doterrhttp.WriteProblem, so it must be returned before anything is written.
+func Handler(fn HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := fn(w, r)
+		if err != nil {
+			doterrhttp.WriteProblem(w, r, doterr.TrackErr(r.Context(), err))
+		}
+	})
+}
+
+// Recoverer is middleware that turns a panic in next into a doterr.ErrPanic
+// error (see doterr.RecoverErr) rendered as a 500 problem. It replaces chi's
+// middleware.Recoverer. http.ErrAbortHandler is re-panicked, as net/http
+// expects.
+func Recoverer(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			rec := recover()
+			if rec == nil {
+				return
+			}
+			//goland:noinspection GoDirectComparisonOfErrors
+			if rec == http.ErrAbortHandler {
+				panic(rec)
+			}
+			doterrhttp.WriteProblem(w, r, doterr.RecoverErr(rec))
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// NotFound renders a 404 problem; pass it to chi's Router.NotFound.
+func NotFound(w http.ResponseWriter, r *http.Request) {
+	doterrhttp.WriteProblem(w, r, doterr.NewErr(doterrhttp.ErrRouteNotFound, "path", r.URL.Path))
+}
+
+// MethodNotAllowed renders a 405 problem; pass it to chi's
+// Router.MethodNotAllowed.
+func MethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	doterrhttp.WriteProblem(w, r, doterr.NewErr(doterrhttp.ErrMethodNotAllowed, "method", r.Method))
+}
+
+// UseChiRequestID makes doterrhttp echo the request ID chi's
+// middleware.RequestID stored in the context. Pass middleware.GetReqID; the
+// RequestIDHeader header is still used when the context has no ID. It returns
+// the previous doterrhttp request-ID function.
+func UseChiRequestID(getReqID func(context.Context) string) func(*http.Request) string {
+	return doterrhttp.SetRequestIDFunc(func(r *http.Request) string {
+		if id := getReqID(r.Context()); id != "" {
+			return id
+		}
+		return r.Header.Get(doterrhttp.RequestIDHeader)
+	})
+}
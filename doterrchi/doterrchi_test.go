@@ -0,0 +1,87 @@
+package doterrchi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mikeschinkel/go-doterr"
+	"github.com/mikeschinkel/go-doterr/doterrhttp"
+)
+
+var ErrChiTest = errors.New("chi test")
+
+func decode(t *testing.T, rec *httptest.ResponseRecorder) doterrhttp.Problem {
+	t.Helper()
+	if ct := rec.Header().Get("Content-Type"); ct != doterrhttp.ProblemContentType {
+		t.Fatalf("unexpected content type %q", ct)
+	}
+	var p doterrhttp.Problem
+	if err := json.Unmarshal(rec.Body.Bytes(), &p); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestHandler_RendersProblem(t *testing.T) {
+	h := Handler(func(w http.ResponseWriter, r *http.Request) error {
+		return doterr.NewErr(doterr.ErrRateLimited, doterr.RetryAfterKey, 0)
+	})
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(doterrhttp.RequestIDHeader, "abc")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	p := decode(t, rec)
+	if rec.Code != http.StatusTooManyRequests || p.Status != http.StatusTooManyRequests {
+		t.Errorf("expected 429, got %d / %d", rec.Code, p.Status)
+	}
+	if p.RequestID != "abc" || rec.Header().Get(doterrhttp.RequestIDHeader) != "abc" {
+		t.Errorf("expected request ID echo, got %q", p.RequestID)
+	}
+}
+
+func TestRecoverer(t *testing.T) {
+	h := Recoverer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		panic("boom")
+	}))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if p := decode(t, rec); p.Status != http.StatusInternalServerError {
+		t.Errorf("expected 500, got %d", p.Status)
+	}
+}
+
+func TestNotFoundAndMethodNotAllowed(t *testing.T) {
+	rec := httptest.NewRecorder()
+	NotFound(rec, httptest.NewRequest(http.MethodGet, "/nope", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404, got %d", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	MethodNotAllowed(rec, httptest.NewRequest(http.MethodDelete, "/", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected 405, got %d", rec.Code)
+	}
+}
+
+type reqIDKey struct{}
+
+func TestUseChiRequestID(t *testing.T) {
+	prev := UseChiRequestID(func(ctx context.Context) string {
+		id, _ := ctx.Value(reqIDKey{}).(string)
+		return id
+	})
+	defer doterrhttp.SetRequestIDFunc(prev)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req = req.WithContext(context.WithValue(req.Context(), reqIDKey{}, "host/abc-000001"))
+	rec := httptest.NewRecorder()
+	NotFound(rec, req)
+	if p := decode(t, rec); p.RequestID != "host/abc-000001" {
+		t.Errorf("expected chi request ID, got %q", p.RequestID)
+	}
+}
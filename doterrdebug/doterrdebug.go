@@ -0,0 +1,175 @@
+// Package doterrdebug serves a running process's error state, in the spirit
+// of net/http/pprof: the doterrstats recent-errors ring buffer and counters,
+// browsable as HTML or JSON and filterable by sentinel, code and fingerprint,
+// so operators can see what is failing without log access.
+//
+// The handler is opt-in: importing this package registers nothing. Mount it
+// with Register, or route Handler() at a path of your choosing, ideally on an
+// internal-only listener since error metadata may be sensitive.
+package doterrdebug
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mikeschinkel/go-doterr"
+	"github.com/mikeschinkel/go-doterr/doterrfields"
+	"github.com/mikeschinkel/go-doterr/doterrstats"
+)
+
+// Path is where Register mounts the inspector.
+const Path = "/debug/errors"
+
+// Register mounts Handler at Path on mux.
+func Register(mux *http.ServeMux) {
+	mux.Handle(Path, Handler())
+}
+
+// Handler returns the inspector. It responds with JSON when the request has
+// format=json or prefers application/json, and HTML otherwise. The query
+// parameters sentinel (message), code (registered sentinel code) and
+// fingerprint (prefix) narrow the listed errors; the counters are unfiltered.
+func Handler() http.Handler {
+	return http.HandlerFunc(serve)
+}
+
+// Report is the inspector's view of the process's error state.
+type Report struct {
+	Filter Filter            `json:"filter"`
+	Stats  doterrstats.Stats `json:"stats"`
+	Recent []Recent          `json:"recent"` // newest first
+}
+
+// Filter holds the query parameters applied to Report.Recent.
+type Filter struct {
+	Sentinel    string `json:"sentinel,omitempty"`
+	Code        string `json:"code,omitempty"`
+	Fingerprint string `json:"fingerprint,omitempty"`
+}
+
+// Recent is one recent error as rendered by the inspector.
+type Recent struct {
+	Time        time.Time   `json:"time"`
+	Fingerprint string      `json:"fingerprint"`
+	Message     string      `json:"message"`
+	Sentinels   []string    `json:"sentinels,omitempty"`
+	Codes       []string    `json:"codes,omitempty"`
+	Meta        [][2]string `json:"meta,omitempty"`
+}
+
+// Snapshot builds the Report the handler would serve for f.
+func Snapshot(f Filter) Report {
+	report := Report{Filter: f, Stats: doterrstats.ErrStats(), Recent: []Recent{}}
+	errs := doterrstats.RecentErrs()
+	for i := len(errs) - 1; i >= 0; i-- {
+		r := newRecent(errs[i])
+		if f.matches(r) {
+			report.Recent = append(report.Recent, r)
+		}
+	}
+	return report
+}
+
+func newRecent(re doterrstats.RecentErr) Recent {
+	r := Recent{Time: re.Time, Fingerprint: re.Fingerprint, Message: re.Err.Error()}
+	for _, s := range doterr.ErrSentinels(re.Err) {
+		r.Sentinels = append(r.Sentinels, s.Error())
+		if info, ok := doterr.LookupSentinel(s); ok {
+			r.Codes = append(r.Codes, info.Code)
+		}
+	}
+	for _, pair := range doterr.ErrMeta(re.Err) {
+		if !doterrfields.Emits("doterrdebug", pair.Key()) {
+			continue
+		}
+		r.Meta = append(r.Meta, [2]string{pair.Key(), fmt.Sprintf("%v", doterr.EmitValue("doterrdebug", pair.Key(), pair.Value()))})
+	}
+	return r
+}
+
+func (f Filter) matches(r Recent) bool {
+	switch {
+	case f.Sentinel != "" && !slices.Contains(r.Sentinels, f.Sentinel):
+		return false
+	case f.Code != "" && !slices.Contains(r.Codes, f.Code):
+		return false
+	case f.Fingerprint != "" && !strings.HasPrefix(r.Fingerprint, f.Fingerprint):
+		return false
+	}
+	return true
+}
+
+func serve(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	report := Snapshot(Filter{
+		Sentinel:    q.Get("sentinel"),
+		Code:        q.Get("code"),
+		Fingerprint: q.Get("fingerprint"),
+	})
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if q.Get("format") == "json" || prefersJSON(r) {
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(report)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_ = page.Execute(w, report)
+}
+
+func prefersJSON(r *http.Request) bool {
+	accept := r.Header.Get("Accept")
+	return strings.Contains(accept, "application/json") && !strings.Contains(accept, "text/html")
+}
+
+var page = template.Must(template.New("page").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<title>{{.Stats.Total}} errors - /debug/errors</title>
+<style>
+body { font-family: sans-serif; font-size: 14px; }
+table { border-collapse: collapse; }
+td, th { border: 1px solid #ccc; padding: 2px 6px; text-align: left; vertical-align: top; }
+code { font-size: 12px; }
+</style>
+</head>
+<body>
+<h1>/debug/errors</h1>
+<p>{{.Stats.Total}} errors reported. <a href="?format=json">JSON</a>{{if or .Filter.Sentinel .Filter.Code .Filter.Fingerprint}} · <a href="?">clear filter</a>{{end}}</p>
+<form>
+<label>sentinel <input name="sentinel" value="{{.Filter.Sentinel}}"></label>
+<label>code <input name="code" value="{{.Filter.Code}}"></label>
+<label>fingerprint <input name="fingerprint" value="{{.Filter.Fingerprint}}"></label>
+<input type="submit" value="filter">
+</form>
+<h2>By sentinel</h2>
+<table>
+<tr><th>count</th><th>sentinel</th></tr>
+{{range $s, $n := .Stats.BySentinel}}<tr><td>{{$n}}</td><td><a href="?sentinel={{$s}}">{{$s}}</a></td></tr>
+{{end}}</table>
+<h2>By fingerprint</h2>
+<table>
+<tr><th>count</th><th>fingerprint</th></tr>
+{{range $fp, $n := .Stats.ByFingerprint}}<tr><td>{{$n}}</td><td><a href="?fingerprint={{$fp}}"><code>{{$fp}}</code></a></td></tr>
+{{end}}</table>
+<h2>Recent ({{len .Recent}})</h2>
+<table>
+<tr><th>time</th><th>fingerprint</th><th>sentinels</th><th>error</th><th>metadata</th></tr>
+{{range .Recent}}<tr>
+<td>{{.Time.Format "2006-01-02 15:04:05.000"}}</td>
+<td><a href="?fingerprint={{.Fingerprint}}"><code>{{.Fingerprint}}</code></a></td>
+<td>{{range $i, $s := .Sentinels}}{{if $i}}<br>{{end}}<a href="?sentinel={{$s}}">{{$s}}</a>{{end}}{{range .Codes}}<br><a href="?code={{.}}"><code>{{.}}</code></a>{{end}}</td>
+<td>{{.Message}}</td>
+<td>{{range .Meta}}<code>{{index . 0}}={{index . 1}}</code><br>{{end}}</td>
+</tr>
+{{end}}</table>
+</body>
+</html>
+`))
@@ -0,0 +1,85 @@
+package doterrdebug
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mikeschinkel/go-doterr"
+	"github.com/mikeschinkel/go-doterr/doterrstats"
+)
+
+var (
+	ErrDebugCoded = doterr.MustRegisterSentinel(errors.New("debug coded"), "DEBUG_CODED", "")
+	ErrDebugPlain = errors.New("debug plain")
+)
+
+func get(t *testing.T, target string, accept string) *httptest.ResponseRecorder {
+	t.Helper()
+	mux := http.NewServeMux()
+	Register(mux)
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestHandler_FiltersJSON(t *testing.T) {
+	doterrstats.Report(doterr.NewErr(ErrDebugCoded, "user", "alice"))
+	doterrstats.Report(doterr.NewErr(ErrDebugPlain, "user", "<bob>"))
+
+	var all Report
+	rec := get(t, Path+"?format=json", "")
+	if err := json.Unmarshal(rec.Body.Bytes(), &all); err != nil {
+		t.Fatal(err)
+	}
+	if len(all.Recent) < 2 || all.Recent[0].Sentinels[0] != "debug plain" {
+		t.Fatalf("expected newest first, got %+v", all.Recent)
+	}
+	// The recent buffer is global, so earlier runs may have left coded errors.
+	coded := 0
+	for _, re := range all.Recent {
+		if len(re.Codes) > 0 && re.Codes[0] == "DEBUG_CODED" {
+			coded++
+		}
+	}
+
+	for name, query := range map[string]string{
+		"code":        "code=DEBUG_CODED",
+		"sentinel":    "sentinel=debug+coded",
+		"fingerprint": "fingerprint=" + all.Recent[1].Fingerprint[:6],
+	} {
+		var filtered Report
+		rec := get(t, Path+"?"+query, "application/json")
+		if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+			t.Errorf("%s: content type %q", name, ct)
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &filtered); err != nil {
+			t.Fatal(err)
+		}
+		if len(filtered.Recent) != coded || filtered.Recent[0].Codes[0] != "DEBUG_CODED" {
+			t.Errorf("%s: unexpected recent %+v", name, filtered.Recent)
+		}
+		if filtered.Stats.Total != all.Stats.Total {
+			t.Errorf("%s: counters should be unfiltered", name)
+		}
+	}
+}
+
+func TestHandler_HTMLEscapes(t *testing.T) {
+	doterrstats.Report(doterr.NewErr(ErrDebugPlain, "user", "<script>"))
+	rec := get(t, Path, "text/html")
+	body := rec.Body.String()
+	if !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/html") {
+		t.Errorf("expected HTML, got %q", rec.Header().Get("Content-Type"))
+	}
+	if strings.Contains(body, "<script>") || !strings.Contains(body, "&lt;script&gt;") {
+		t.Errorf("expected metadata to be escaped")
+	}
+}
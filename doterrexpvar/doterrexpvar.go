@@ -0,0 +1,53 @@
+// Package doterrexpvar publishes the doterrstats counters and recent error
+// fingerprints via expvar, so existing /debug/vars scrapers pick up error
+// health without new infrastructure.
+//
+// Publication is opt-in: importing this package registers nothing until
+// Publish is called. Note that importing expvar itself registers the
+// /debug/vars handler on http.DefaultServeMux.
+package doterrexpvar
+
+import (
+	"expvar"
+
+	"github.com/mikeschinkel/go-doterr/doterrstats"
+)
+
+// DefaultName is the expvar name used when Publish is called with "".
+const DefaultName = "doterr"
+
+// Publish registers an expvar.Func under name (DefaultName if empty) whose
+// value is computed on every read from doterrstats. Like expvar.Publish, it
+// panics if name is already registered.
+//
+// The published value has the shape:
+//
+//	{
+//	  "total": 12,
+//	  "by_sentinel": {"not found": 10, ...},
+//	  "by_fingerprint": {"3f0c...": 10, ...},
+//	  "recent": ["3f0c...", ...]  // oldest first
+//	}
+func Publish(name string) {
+	if name == "" {
+		name = DefaultName
+	}
+	expvar.Publish(name, expvar.Func(Value))
+}
+
+// Value returns the value Publish exposes. It is exported so the same view
+// can be embedded into other expvar maps or debug endpoints.
+func Value() any {
+	s := doterrstats.ErrStats()
+	recent := doterrstats.RecentErrs()
+	fps := make([]string, len(recent))
+	for i, re := range recent {
+		fps[i] = re.Fingerprint
+	}
+	return map[string]any{
+		"total":          s.Total,
+		"by_sentinel":    s.BySentinel,
+		"by_fingerprint": s.ByFingerprint,
+		"recent":         fps,
+	}
+}
@@ -0,0 +1,35 @@
+package doterrexpvar
+
+import (
+	"encoding/json"
+	"errors"
+	"expvar"
+	"testing"
+
+	"github.com/mikeschinkel/go-doterr"
+	"github.com/mikeschinkel/go-doterr/doterrstats"
+)
+
+func TestPublish_ExposesStats(t *testing.T) {
+	doterrstats.ResetStats()
+	doterrstats.Report(doterr.NewErr(errors.New("boom"), "id", 1))
+
+	if expvar.Get("doterr_test") == nil { // expvar names cannot be unpublished
+		Publish("doterr_test")
+	}
+	v := expvar.Get("doterr_test")
+	if v == nil {
+		t.Fatal("expected expvar to be published")
+	}
+	var got struct {
+		Total      int64            `json:"total"`
+		BySentinel map[string]int64 `json:"by_sentinel"`
+		Recent     []string         `json:"recent"`
+	}
+	if err := json.Unmarshal([]byte(v.String()), &got); err != nil {
+		t.Fatalf("expected JSON value, got %q: %v", v.String(), err)
+	}
+	if got.Total != 1 || got.BySentinel["boom"] != 1 || len(got.Recent) == 0 {
+		t.Errorf("unexpected published value %+v", got)
+	}
+}
@@ -0,0 +1,71 @@
+// Package doterrfields selects the metadata keys each doterr encoder emits,
+// so one error can be serialized for several audiences without filtering at
+// each call site:
+//
+//	doterrfields.SetPolicy("doterrwire", doterrfields.Policy{Deny: []string{"stack", "internal.*"}})
+//
+// Encoders ask Emits before writing a key and doterr.EmitValue for the value
+// to write; the encoders in this module, such as doterrwire and doterrslog,
+// do both. Error() text and programmatic access such as doterr.ErrValue are
+// not filtered.
+package doterrfields
+
+import (
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Policy selects the metadata keys an encoder emits. A pattern is an exact
+// key or a prefix followed by "*". A key is emitted if it matches no Deny
+// pattern and, when Allow is non-empty, some Allow pattern. The zero Policy
+// emits everything.
+type Policy struct {
+	Allow []string
+	Deny  []string
+}
+
+var (
+	mu       sync.RWMutex
+	policies = make(map[string]Policy) // by emitter
+)
+
+// SetPolicy sets the Policy of the encoder named emitter (the name it passes
+// to Emits, e.g. "doterrwire" or "doterrslog") and returns the previous one.
+// The zero Policy removes it.
+func SetPolicy(emitter string, p Policy) Policy {
+	mu.Lock()
+	defer mu.Unlock()
+	prev := policies[emitter]
+	if len(p.Allow) == 0 && len(p.Deny) == 0 {
+		delete(policies, emitter)
+		return prev
+	}
+	policies[emitter] = Policy{Allow: slices.Clone(p.Allow), Deny: slices.Clone(p.Deny)}
+	return prev
+}
+
+// Emits reports whether the encoder named emitter should output metadata key
+// under its Policy. Encoders call it before doterr.EmitValue and skip the key
+// when it reports false.
+func Emits(emitter, key string) bool {
+	mu.RLock()
+	p, ok := policies[emitter]
+	mu.RUnlock()
+	return !ok || p.emits(key)
+}
+
+// emits reports whether p lets key through.
+func (p Policy) emits(key string) bool {
+	match := func(pattern string) bool {
+		prefix, wild := strings.CutSuffix(pattern, "*")
+		if wild {
+			return strings.HasPrefix(key, prefix)
+		}
+		return key == pattern
+	}
+	if slices.ContainsFunc(p.Deny, match) {
+		return false
+	}
+	return len(p.Allow) == 0 || slices.ContainsFunc(p.Allow, match)
+}
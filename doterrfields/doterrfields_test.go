@@ -0,0 +1,38 @@
+package doterrfields
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mikeschinkel/go-doterr"
+)
+
+func TestPolicy(t *testing.T) {
+	prev := SetPolicy("test-json", Policy{Deny: []string{"stack", "internal.*"}})
+	t.Cleanup(func() { SetPolicy("test-json", prev) })
+	SetPolicy("test-public", Policy{Allow: []string{"user", "order"}, Deny: []string{"order"}})
+	t.Cleanup(func() { SetPolicy("test-public", Policy{}) })
+
+	cases := []struct {
+		emitter, key string
+		want         bool
+	}{
+		{"test-json", "stack", false},
+		{"test-json", "internal.db", false},
+		{"test-json", "internal", true},
+		{"test-json", "user", true},
+		{"test-public", "user", true},
+		{"test-public", "order", false},
+		{"test-public", "other", false},
+		{"test-logs", "stack", true},
+	}
+	for _, c := range cases {
+		if got := Emits(c.emitter, c.key); got != c.want {
+			t.Errorf("Emits(%q, %q) = %v, want %v", c.emitter, c.key, got, c.want)
+		}
+	}
+
+	if got := doterr.NewErr(errors.New("test"), "stack", "main.go:1").Error(); got != "test; meta: stack=main.go:1" {
+		t.Errorf("expected Error() unfiltered, got %q", got)
+	}
+}
@@ -0,0 +1,85 @@
+// Package doterrgin plugs doterrhttp into gin-gonic/gin. It is a separate
+// module so the doterr module itself stays free of the gin dependency.
+//
+//	r := gin.New()
+//	r.Use(doterrgin.Recovery(), doterrgin.Errors())
+//	r.NoRoute(doterrgin.NoRoute)
+//	r.NoMethod(doterrgin.NoMethod)
+//	r.GET("/users/:id", doterrgin.Handler(getUser))
+//
+// Handlers either return an error (Handler) or record it with c.Error as gin
+// handlers usually do; Errors renders the last recorded error as problem
+// details with doterrhttp.WriteProblem once the chain has run.
+package doterrgin
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mikeschinkel/go-doterr"
+	"github.com/mikeschinkel/go-doterr/doterrhttp"
+)
+
+// Handler adapts a gin handler that may fail. A non-nil returned error is
+// counted against the request's doterr.ErrBudget, if any, recorded with
+// c.Error and rendered with doterrhttp.WriteProblem, and the chain is
+// aborted.
+func Handler(fn func(*gin.Context) error) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		err := fn(c)
+		if err == nil {
+			return
+		}
+		err = doterr.TrackErr(c.Request.Context(), err)
+		_ = c.Error(err)
+		c.Abort()
+		doterrhttp.WriteProblem(c.Writer, c.Request, err)
+	}
+}
+
+// Errors is middleware that renders the last error recorded with c.Error
+// once the rest of the chain has run, unless a response was already written.
+func Errors() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+		last := c.Errors.Last()
+		if last == nil || c.Writer.Written() {
+			return
+		}
+		doterrhttp.WriteProblem(c.Writer, c.Request, last.Err)
+	}
+}
+
+// Recovery is middleware that turns a panic into a doterr.ErrPanic error (see
+// doterr.RecoverErr) rendered as a 500 problem. It replaces gin.Recovery.
+// http.ErrAbortHandler is re-panicked, as net/http expects.
+func Recovery() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			rec := recover()
+			if rec == nil {
+				return
+			}
+			//goland:noinspection GoDirectComparisonOfErrors
+			if rec == http.ErrAbortHandler {
+				panic(rec)
+			}
+			err := doterr.RecoverErr(rec)
+			_ = c.Error(err)
+			c.Abort()
+			doterrhttp.WriteProblem(c.Writer, c.Request, err)
+		}()
+		c.Next()
+	}
+}
+
+// NoRoute renders a 404 problem; pass it to gin's Engine.NoRoute.
+func NoRoute(c *gin.Context) {
+	doterrhttp.WriteProblem(c.Writer, c.Request, doterr.NewErr(doterrhttp.ErrRouteNotFound, "path", c.Request.URL.Path))
+}
+
+// NoMethod renders a 405 problem; pass it to gin's Engine.NoMethod (which
+// requires Engine.HandleMethodNotAllowed).
+func NoMethod(c *gin.Context) {
+	doterrhttp.WriteProblem(c.Writer, c.Request, doterr.NewErr(doterrhttp.ErrMethodNotAllowed, "method", c.Request.Method))
+}
@@ -0,0 +1,103 @@
+package doterrgin
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mikeschinkel/go-doterr"
+	"github.com/mikeschinkel/go-doterr/doterrhttp"
+)
+
+var ErrGinTest = errors.New("gin test")
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+func decode(t *testing.T, rec *httptest.ResponseRecorder) doterrhttp.Problem {
+	t.Helper()
+	if ct := rec.Header().Get("Content-Type"); ct != doterrhttp.ProblemContentType {
+		t.Fatalf("unexpected content type %q", ct)
+	}
+	var p doterrhttp.Problem
+	if err := json.Unmarshal(rec.Body.Bytes(), &p); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func serve(r *gin.Engine, method, path string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	req.Header.Set(doterrhttp.RequestIDHeader, "abc")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestHandler_RendersProblem(t *testing.T) {
+	r := gin.New()
+	r.GET("/", Handler(func(c *gin.Context) error {
+		return doterr.NewErr(doterr.ErrRateLimited, doterr.RetryAfterKey, 0)
+	}))
+	rec := serve(r, http.MethodGet, "/")
+
+	p := decode(t, rec)
+	if rec.Code != http.StatusTooManyRequests || p.Status != http.StatusTooManyRequests {
+		t.Errorf("expected 429, got %d / %d", rec.Code, p.Status)
+	}
+	if p.RequestID != "abc" || rec.Header().Get(doterrhttp.RequestIDHeader) != "abc" {
+		t.Errorf("expected request ID echo, got %q", p.RequestID)
+	}
+}
+
+func TestErrors_RendersRecordedError(t *testing.T) {
+	r := gin.New()
+	r.Use(Errors())
+	r.GET("/", func(c *gin.Context) {
+		_ = c.Error(doterr.NewErr(ErrGinTest, "k", 1))
+	})
+	r.GET("/written", func(c *gin.Context) {
+		_ = c.Error(doterr.NewErr(ErrGinTest))
+		c.String(http.StatusAccepted, "ok")
+	})
+
+	rec := serve(r, http.MethodGet, "/")
+	if p := decode(t, rec); rec.Code != http.StatusInternalServerError || p.Status != http.StatusInternalServerError {
+		t.Errorf("expected 500, got %d / %d", rec.Code, p.Status)
+	}
+	rec = serve(r, http.MethodGet, "/written")
+	if rec.Code != http.StatusAccepted || rec.Body.String() != "ok" {
+		t.Errorf("expected the written response kept, got %d %q", rec.Code, rec.Body.String())
+	}
+}
+
+func TestRecovery(t *testing.T) {
+	r := gin.New()
+	r.Use(Recovery())
+	r.GET("/", func(*gin.Context) {
+		panic("boom")
+	})
+	rec := serve(r, http.MethodGet, "/")
+	if p := decode(t, rec); p.Status != http.StatusInternalServerError {
+		t.Errorf("expected 500, got %d", p.Status)
+	}
+}
+
+func TestNoRouteAndNoMethod(t *testing.T) {
+	r := gin.New()
+	r.HandleMethodNotAllowed = true
+	r.NoRoute(NoRoute)
+	r.NoMethod(NoMethod)
+	r.GET("/", func(c *gin.Context) {})
+
+	if rec := serve(r, http.MethodGet, "/nope"); rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404, got %d", rec.Code)
+	}
+	if rec := serve(r, http.MethodDelete, "/"); rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected 405, got %d", rec.Code)
+	}
+}
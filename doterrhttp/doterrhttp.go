@@ -0,0 +1,132 @@
+// Package doterrhttp renders doterr errors as HTTP responses. Handlers return
+// an error instead of writing failures themselves:
+//
+//	http.Handle("/users", doterrhttp.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+//	  u, err := repo.Find(r.Context(), r.URL.Query().Get("id"))
+//	  if err != nil {
+//	    return err
+//	  }
+//	  return json.NewEncoder(w).Encode(u)
+//	}))
+//
+// The status code comes from the sentinels in the error (see RegisterStatus),
+// and protocol headers such as Retry-After are derived from its metadata.
+package doterrhttp
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/mikeschinkel/go-doterr"
+)
+
+// HandlerFunc is an http.Handler that may fail. A non-nil returned error is
+// counted against the request's doterr.ErrBudget, if any, and rendered with
+// WriteErr, so it must be returned before anything is written.
+type HandlerFunc func(http.ResponseWriter, *http.Request) error
+
+func (f HandlerFunc) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	err := f(w, r)
+	if err != nil {
+		WriteErr(w, r, doterr.TrackErr(r.Context(), err))
+	}
+}
+
+// Budget is middleware that gives each request a doterr.ErrBudget allowing
+// limit errors (see doterr.WithErrBudget). Handlers and the code they call
+// read it with doterr.ErrBudgetFrom(r.Context()) to bail out of an error
+// storm early.
+func Budget(limit int, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, _ := doterr.WithErrBudget(r.Context(), limit)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Router sentinels for the not-found and method-not-allowed hooks of the
+// router adapters (doterrchi, doterrgin), mapped to 404 and 405.
+var (
+	ErrRouteNotFound    = errors.New("route not found")
+	ErrMethodNotAllowed = errors.New("method not allowed")
+)
+
+type statusMapping struct {
+	sentinel error
+	status   int
+}
+
+var (
+	statusMu       sync.RWMutex
+	statusMappings = []statusMapping{
+		{doterr.ErrRateLimited, http.StatusTooManyRequests},
+		{doterr.ErrUnavailable, http.StatusServiceUnavailable},
+		{doterr.ErrInvalidField, http.StatusUnprocessableEntity},
+		{ErrRouteNotFound, http.StatusNotFound},
+		{ErrMethodNotAllowed, http.StatusMethodNotAllowed},
+	}
+)
+
+// RegisterStatus maps sentinel to an HTTP status code. Mappings are checked
+// most-recently-registered first with errors.Is, so registering a sentinel
+// again overrides its previous mapping.
+func RegisterStatus(sentinel error, status int) {
+	statusMu.Lock()
+	defer statusMu.Unlock()
+	statusMappings = append(statusMappings, statusMapping{sentinel, status})
+}
+
+// StatusFor returns the HTTP status code for err: the registered status of
+// the first matching sentinel, or 500 if none match.
+func StatusFor(err error) int {
+	statusMu.RLock()
+	defer statusMu.RUnlock()
+	for i := len(statusMappings) - 1; i >= 0; i-- {
+		if errors.Is(err, statusMappings[i].sentinel) {
+			return statusMappings[i].status
+		}
+	}
+	return http.StatusInternalServerError
+}
+
+// ErrNoStatus is reported by CheckStatus for a sentinel without a mapping.
+var ErrNoStatus = errors.New("no HTTP status mapping")
+
+// CheckStatus is a doterrcatalog.Check reporting registered sentinels that
+// RegisterStatus has not mapped, which StatusFor would render as 500.
+func CheckStatus(info doterr.SentinelInfo) error {
+	statusMu.RLock()
+	defer statusMu.RUnlock()
+	for _, m := range statusMappings {
+		if errors.Is(info.Sentinel, m.sentinel) {
+			return nil
+		}
+	}
+	return ErrNoStatus
+}
+
+// WriteErr writes err as an HTTP error response. The body is the standard
+// status text so internal details are not leaked to clients. For rate-limit
+// and unavailable errors carrying doterr.RetryAfterKey, a Retry-After header
+// is emitted in whole seconds (rounded up).
+func WriteErr(w http.ResponseWriter, r *http.Request, err error) {
+	status := StatusFor(err)
+	writeRetryAfter(w.Header(), err)
+	http.Error(w, http.StatusText(status), status)
+}
+
+func writeRetryAfter(h http.Header, err error) {
+	if !errors.Is(err, doterr.ErrRateLimited) && !errors.Is(err, doterr.ErrUnavailable) {
+		return
+	}
+	d, ok := doterr.RetryAfter(err)
+	if !ok || d <= 0 {
+		return
+	}
+	secs := int64(d.Seconds())
+	if float64(secs) < d.Seconds() {
+		secs++
+	}
+	h.Set("Retry-After", strconv.FormatInt(secs, 10))
+}
@@ -0,0 +1,98 @@
+package doterrhttp
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mikeschinkel/go-doterr"
+	"github.com/mikeschinkel/go-doterr/doterrcatalog"
+)
+
+func serve(t *testing.T, err error) *httptest.ResponseRecorder {
+	t.Helper()
+	h := HandlerFunc(func(http.ResponseWriter, *http.Request) error { return err })
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	return rec
+}
+
+func TestWriteErr_RetryAfterForRateLimit(t *testing.T) {
+	err := doterr.WithRetryAfter(doterr.NewErr(doterr.ErrRateLimited, "client", "c1"), 1500*time.Millisecond)
+	rec := serve(t, err)
+	if rec.Code != http.StatusTooManyRequests {
+		t.Errorf("expected 429, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "2" {
+		t.Errorf("expected Retry-After 2, got %q", got)
+	}
+}
+
+func TestWriteErr_NoRetryAfterForOtherSentinels(t *testing.T) {
+	err := doterr.WithRetryAfter(doterr.NewErr(errors.New("boom")), time.Second)
+	rec := serve(t, err)
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("expected 500, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "" {
+		t.Errorf("expected no Retry-After, got %q", got)
+	}
+}
+
+func TestRegisterStatus_Overrides(t *testing.T) {
+	errNotFound := errors.New("not found")
+	RegisterStatus(errNotFound, http.StatusNotFound)
+	if got := StatusFor(doterr.NewErr(errNotFound, "id", 1)); got != http.StatusNotFound {
+		t.Errorf("expected 404, got %d", got)
+	}
+	if got := serve(t, doterr.NewErr(doterr.ErrUnavailable)).Code; got != http.StatusServiceUnavailable {
+		t.Errorf("expected 503, got %d", got)
+	}
+}
+
+var (
+	errMapped   = doterr.MustRegisterSentinel(errors.New("http mapped"), "HTTP_MAPPED", "")
+	errUnmapped = doterr.MustRegisterSentinel(errors.New("http unmapped"), "HTTP_UNMAPPED", "")
+)
+
+func TestCheckStatus(t *testing.T) {
+	mapped, unmapped := errMapped, errUnmapped
+	RegisterStatus(mapped, http.StatusConflict)
+
+	if err := CheckStatus(doterr.SentinelInfo{Sentinel: mapped}); err != nil {
+		t.Errorf("expected mapped sentinel to pass, got %v", err)
+	}
+	problems := doterrcatalog.Validate(CheckStatus).(interface{ Unwrap() []error }).Unwrap()
+	var flagged []string
+	for _, problem := range problems {
+		if errors.Is(problem, ErrNoStatus) {
+			code, _ := doterr.ErrValue[string](problem, "code")
+			flagged = append(flagged, code)
+		}
+	}
+	if len(flagged) != 1 || flagged[0] != "HTTP_UNMAPPED" {
+		t.Errorf("expected only %v flagged, got %v", unmapped, flagged)
+	}
+}
+
+func TestBudget_ExposesTallyToHandlers(t *testing.T) {
+	var exceeded error
+	h := Budget(1, HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		ctx := r.Context()
+		for range 2 {
+			_ = doterr.TrackErr(ctx, errors.New("flaky"))
+		}
+		exceeded = doterr.ErrBudgetFrom(ctx).Err()
+		return exceeded
+	}))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if !errors.Is(exceeded, doterr.ErrBudgetExceeded) {
+		t.Errorf("expected ErrBudgetExceeded, got %v", exceeded)
+	}
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("expected 500, got %d", rec.Code)
+	}
+}
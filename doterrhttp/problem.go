@@ -0,0 +1,91 @@
+package doterrhttp
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/mikeschinkel/go-doterr"
+)
+
+// ProblemContentType is the media type of RFC 9457 problem details.
+const ProblemContentType = "application/problem+json"
+
+// RequestIDHeader is the header WriteProblem reads the request ID from by
+// default and echoes it back in.
+const RequestIDHeader = "X-Request-Id"
+
+// Problem is an RFC 9457 problem details body. Code is the doterr registered
+// code of the error, if any, Fields lists the invalid fields of a
+// doterr.NewValidationErr error, and RequestID echoes the request's ID so
+// clients can quote it in support requests.
+type Problem struct {
+	Type      string            `json:"type"`
+	Title     string            `json:"title"`
+	Status    int               `json:"status"`
+	Code      string            `json:"code,omitempty"`
+	Fields    []doterr.FieldErr `json:"fields,omitempty"`
+	RequestID string            `json:"request_id,omitempty"`
+}
+
+var requestIDFunc atomic.Pointer[func(*http.Request) string]
+
+// SetRequestIDFunc replaces how WriteProblem finds a request's ID, e.g. to
+// read the one a router's request-ID middleware stored in the context, and
+// returns the previous function. The default reads RequestIDHeader. Passing
+// nil restores the default.
+func SetRequestIDFunc(fn func(*http.Request) string) func(*http.Request) string {
+	var next *func(*http.Request) string
+	if fn != nil {
+		next = &fn
+	}
+	prev := requestIDFunc.Swap(next)
+	if prev == nil {
+		return headerRequestID
+	}
+	return *prev
+}
+
+// RequestID returns the ID of r as found by the SetRequestIDFunc function.
+func RequestID(r *http.Request) string {
+	if r == nil {
+		return ""
+	}
+	if fn := requestIDFunc.Load(); fn != nil {
+		return (*fn)(r)
+	}
+	return headerRequestID(r)
+}
+
+func headerRequestID(r *http.Request) string {
+	return r.Header.Get(RequestIDHeader)
+}
+
+// ProblemFor builds the Problem WriteProblem would send for err.
+func ProblemFor(err error) Problem {
+	status := StatusFor(err)
+	return Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Code:   doterr.ErrCode(err),
+		Fields: doterr.ValidationFields(err),
+	}
+}
+
+// WriteProblem is WriteErr with an application/problem+json body. Like
+// WriteErr it never includes the error message, only its status, registered
+// code and validation fields. The request ID, if any, is echoed in the body
+// and in the RequestIDHeader response header.
+func WriteProblem(w http.ResponseWriter, r *http.Request, err error) {
+	p := ProblemFor(err)
+	p.RequestID = RequestID(r)
+	if p.RequestID != "" {
+		w.Header().Set(RequestIDHeader, p.RequestID)
+	}
+	writeRetryAfter(w.Header(), err)
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(p.Status)
+	_ = json.NewEncoder(w).Encode(p)
+}
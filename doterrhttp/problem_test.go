@@ -0,0 +1,67 @@
+package doterrhttp
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mikeschinkel/go-doterr"
+)
+
+var ErrSignup = errors.New("signup rejected")
+
+func TestWriteProblem_ValidationFields(t *testing.T) {
+	err := doterr.NewValidationErr(ErrSignup, map[string]string{
+		"email":    "required",
+		"password": "too short",
+	})
+	rec := httptest.NewRecorder()
+	WriteProblem(rec, httptest.NewRequest(http.MethodPost, "/signup", nil), err)
+
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected 422, got %d", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != ProblemContentType {
+		t.Errorf("unexpected content type %q", ct)
+	}
+	var p Problem
+	if err := json.Unmarshal(rec.Body.Bytes(), &p); err != nil {
+		t.Fatal(err)
+	}
+	want := []doterr.FieldErr{
+		{Field: "email", Reason: "required"},
+		{Field: "password", Reason: "too short"},
+	}
+	if len(p.Fields) != 2 || p.Fields[0] != want[0] || p.Fields[1] != want[1] {
+		t.Errorf("unexpected fields %+v", p.Fields)
+	}
+	if p.Status != 422 || p.Title != "Unprocessable Entity" {
+		t.Errorf("unexpected problem %+v", p)
+	}
+}
+
+func TestWriteProblem_EchoesRequestID(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(RequestIDHeader, "req-42")
+	rec := httptest.NewRecorder()
+	WriteProblem(rec, req, doterr.NewErr(ErrSignup))
+
+	if got := rec.Header().Get(RequestIDHeader); got != "req-42" {
+		t.Errorf("expected echoed header, got %q", got)
+	}
+	var p Problem
+	if err := json.Unmarshal(rec.Body.Bytes(), &p); err != nil {
+		t.Fatal(err)
+	}
+	if p.RequestID != "req-42" {
+		t.Errorf("expected request ID in body, got %q", p.RequestID)
+	}
+
+	prev := SetRequestIDFunc(func(*http.Request) string { return "from-ctx" })
+	defer SetRequestIDFunc(prev)
+	if got := RequestID(req); got != "from-ctx" {
+		t.Errorf("expected custom request ID, got %q", got)
+	}
+}
@@ -0,0 +1,40 @@
+package doterrhttp
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/mikeschinkel/go-doterr"
+	"github.com/mikeschinkel/go-doterr/doterrwire"
+)
+
+// TrailerKey is the HTTP trailer that carries an encoded error summary for
+// responses that fail after the status line and headers were already sent.
+const TrailerKey = "Doterr-Error"
+
+// ErrInvalidTrailer is returned by ErrTrailer when the trailer is present but
+// cannot be decoded; the raw value is attached as "value".
+var ErrInvalidTrailer = errors.New("invalid error trailer")
+
+// SetErrTrailer records err in the TrailerKey response trailer. It may be
+// called after the body has started streaming; the trailer is sent when the
+// handler returns. The value is doterrwire.EncodeHeader's, so it follows
+// doterrwire.SetHeaderCodec. Does nothing for a nil error.
+func SetErrTrailer(w http.ResponseWriter, err error) {
+	if err == nil {
+		return
+	}
+	w.Header().Set(http.TrailerPrefix+TrailerKey, doterrwire.EncodeHeader(err))
+}
+
+// ErrTrailer returns the error a server recorded with SetErrTrailer, or nil if
+// there is none. Trailers are only available after resp.Body has been read to
+// EOF. A trailer that cannot be decoded yields an ErrInvalidTrailer error.
+func ErrTrailer(resp *http.Response) error {
+	v := resp.Trailer.Get(TrailerKey)
+	decoded, err := doterrwire.DecodeHeader(v)
+	if err != nil {
+		return doterr.NewErr(ErrInvalidTrailer, "value", v, err)
+	}
+	return decoded
+}
@@ -0,0 +1,52 @@
+package doterrhttp
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mikeschinkel/go-doterr"
+	"github.com/mikeschinkel/go-doterr/doterrwire"
+)
+
+func TestErrTrailer_RoundTripsMidStreamFailure(t *testing.T) {
+	errStream := errors.New("stream broken")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "partial body")
+		w.(http.Flusher).Flush()
+		SetErrTrailer(w, doterr.NewErr(errStream, "row", 42))
+	}))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	_, _ = io.ReadAll(resp.Body)
+
+	got := ErrTrailer(resp)
+	if !errors.Is(got, doterrwire.ErrRemote) {
+		t.Fatalf("expected remote error, got %v", got)
+	}
+	if row, _ := doterr.ErrValue[string](got, "row"); row != "42" {
+		t.Errorf("expected row=42, got %q", row)
+	}
+	sentinels := doterr.Errors(got)
+	if len(sentinels) != 2 || sentinels[1].Error() != "stream broken" {
+		t.Errorf("unexpected sentinels %v", sentinels)
+	}
+}
+
+func TestErrTrailer_AbsentAndInvalid(t *testing.T) {
+	resp := &http.Response{Trailer: http.Header{}}
+	if err := ErrTrailer(resp); err != nil {
+		t.Errorf("expected nil without trailer, got %v", err)
+	}
+	resp.Trailer.Set(TrailerKey, "!!!")
+	if err := ErrTrailer(resp); !errors.Is(err, ErrInvalidTrailer) {
+		t.Errorf("expected ErrInvalidTrailer, got %v", err)
+	}
+}
@@ -0,0 +1,93 @@
+// Package doterrio decorates io.Reader and io.Writer so the errors they
+// surface deep inside a streaming pipeline carry the context of the code
+// that set the pipeline up, plus how far the stream got:
+//
+//	r := doterrio.WrapReader(resp.Body, ErrDownload, "url", u)
+//	_, err := io.Copy(dst, r) // err has ErrDownload, url, io_op and offset
+//
+// io.EOF is passed through unwrapped so readers can still detect it with ==.
+package doterrio
+
+import (
+	"io"
+
+	"github.com/mikeschinkel/go-doterr"
+)
+
+// Metadata keys attached to wrapped errors.
+const (
+	OpKey     = "io_op"  // "read", "write" or "close"
+	OffsetKey = "offset" // int64 bytes transferred before the failure
+)
+
+// WrapReader returns r as an io.ReadCloser whose Read and Close errors,
+// other than io.EOF, are doterr errors with sentinel, kvs, OpKey and
+// OffsetKey, and the original error as the trailing cause. Close closes r if
+// it is an io.Closer and is a no-op otherwise.
+func WrapReader(r io.Reader, sentinel error, kvs ...any) io.ReadCloser {
+	return &reader{r: r, wrap: wrap{sentinel: sentinel, kvs: kvs}}
+}
+
+// WrapWriter returns w as an io.WriteCloser whose Write and Close errors are
+// doterr errors built as for WrapReader. Close closes w if it is an
+// io.Closer and is a no-op otherwise.
+func WrapWriter(w io.Writer, sentinel error, kvs ...any) io.WriteCloser {
+	return &writer{w: w, wrap: wrap{sentinel: sentinel, kvs: kvs}}
+}
+
+// wrap holds the context added to a stream's errors.
+type wrap struct {
+	sentinel error
+	kvs      []any
+	offset   int64
+}
+
+func (w *wrap) err(op string, cause error) error {
+	//goland:noinspection GoDirectComparisonOfErrors
+	if cause == nil || op == "read" && cause == io.EOF {
+		return cause
+	}
+	parts := make([]any, 0, len(w.kvs)+6)
+	parts = append(parts, w.sentinel)
+	parts = append(parts, w.kvs...)
+	parts = append(parts, OpKey, op, OffsetKey, w.offset, cause)
+	return doterr.NewErr(parts...)
+}
+
+type reader struct {
+	r io.Reader
+	wrap
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.offset += int64(n)
+	return n, r.err("read", err)
+}
+
+func (r *reader) Close() error {
+	c, ok := r.r.(io.Closer)
+	if !ok {
+		return nil
+	}
+	return r.err("close", c.Close())
+}
+
+type writer struct {
+	w io.Writer
+	wrap
+}
+
+func (w *writer) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.offset += int64(n)
+	return n, w.err("write", err)
+}
+
+func (w *writer) Close() error {
+	c, ok := w.w.(io.Closer)
+	if !ok {
+		return nil
+	}
+	return w.err("close", c.Close())
+}
@@ -0,0 +1,83 @@
+package doterrio
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/mikeschinkel/go-doterr"
+)
+
+var ErrTest = errors.New("stream failed")
+
+func TestWrapReader(t *testing.T) {
+	cause := errors.New("connection reset")
+	src := io.MultiReader(strings.NewReader("hello"), iotest.ErrReader(cause))
+	r := WrapReader(src, ErrTest, "url", "http://x")
+
+	_, err := io.Copy(io.Discard, r)
+	if !errors.Is(err, ErrTest) || !errors.Is(err, cause) {
+		t.Fatalf("expected sentinel and cause, got %v", err)
+	}
+	if off, _ := doterr.ErrValue[int64](err, OffsetKey); off != 5 {
+		t.Errorf("offset = %d, want 5", off)
+	}
+	if op, _ := doterr.ErrValue[string](err, OpKey); op != "read" {
+		t.Errorf("op = %q", op)
+	}
+	if url, _ := doterr.ErrValue[string](err, "url"); url != "http://x" {
+		t.Errorf("url = %q", url)
+	}
+	if r.Close() != nil {
+		t.Error("expected Close of a non-Closer to be a no-op")
+	}
+
+	r = WrapReader(strings.NewReader("x"), ErrTest)
+	_, err = r.Read(make([]byte, 4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	//goland:noinspection GoDirectComparisonOfErrors
+	if _, err = r.Read(make([]byte, 4)); err != io.EOF {
+		t.Errorf("expected bare io.EOF, got %v", err)
+	}
+}
+
+type failingWriter struct {
+	limit int
+	buf   bytes.Buffer
+}
+
+var errFull = errors.New("disk full")
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	room := w.limit - w.buf.Len()
+	if len(p) <= room {
+		return w.buf.Write(p)
+	}
+	n, _ := w.buf.Write(p[:room])
+	return n, errFull
+}
+
+func (w *failingWriter) Close() error { return errors.New("close failed") }
+
+func TestWrapWriter(t *testing.T) {
+	w := WrapWriter(&failingWriter{limit: 6}, ErrTest, "path", "/tmp/out")
+	if _, err := w.Write([]byte("abcd")); err != nil {
+		t.Fatal(err)
+	}
+	n, err := w.Write([]byte("efgh"))
+	if n != 2 || !errors.Is(err, errFull) || !errors.Is(err, ErrTest) {
+		t.Fatalf("Write = %d, %v", n, err)
+	}
+	if off, _ := doterr.ErrValue[int64](err, OffsetKey); off != 6 {
+		t.Errorf("offset = %d, want 6", off)
+	}
+	err = w.Close()
+	if op, _ := doterr.ErrValue[string](err, OpKey); op != "close" || !errors.Is(err, ErrTest) {
+		t.Errorf("unexpected Close error %v", err)
+	}
+}
@@ -0,0 +1,9 @@
+// Package doterrjs converts doterr errors to and from JavaScript Error objects
+// so Go-in-WASM frontends can surface structured errors to JavaScript callers.
+//
+// The adapter is only built for GOOS=js GOARCH=wasm. A converted error is a
+// regular JS Error with two extra properties:
+//
+//	err.sentinels // array of sentinel messages, e.g. ["not found"]
+//	err.meta      // object with one property per metadata key
+package doterrjs
@@ -0,0 +1,109 @@
+//go:build js && wasm
+
+package doterrjs
+
+import (
+	"errors"
+	"fmt"
+	"syscall/js"
+
+	"github.com/mikeschinkel/go-doterr"
+	"github.com/mikeschinkel/go-doterr/doterrfields"
+)
+
+// ErrJS marks errors converted from JavaScript values by FromJS.
+var ErrJS = errors.New("javascript error")
+
+// ErrorName is the JS Error.name given to errors created by ToJS.
+const ErrorName = "DotErr"
+
+// ToJS converts err to a JavaScript Error whose message is err.Error(), with
+// sentinel messages in a "sentinels" array and metadata as properties of a
+// "meta" object. Values js.ValueOf accepts are passed through; anything else
+// is rendered with %v. Returns js.Null() for a nil error.
+func ToJS(err error) js.Value {
+	if err == nil {
+		return js.Null()
+	}
+	v := js.Global().Get("Error").New(err.Error())
+	v.Set("name", ErrorName)
+
+	sentinels := doterr.ErrSentinels(err)
+	arr := js.Global().Get("Array").New(len(sentinels))
+	for i, s := range sentinels {
+		arr.SetIndex(i, s.Error())
+	}
+	v.Set("sentinels", arr)
+
+	meta := js.Global().Get("Object").New()
+	for _, pair := range doterr.ErrMeta(err) {
+		if !doterrfields.Emits("doterrjs", pair.Key()) {
+			continue
+		}
+		meta.Set(pair.Key(), jsValue(doterr.EmitValue("doterrjs", pair.Key(), pair.Value())))
+	}
+	v.Set("meta", meta)
+	return v
+}
+
+// FromJS converts a JavaScript value to a doterr error. Objects contribute
+// their "sentinels" and "meta" properties (as produced by ToJS) and their
+// "message" as the trailing cause; any other value is rendered with String().
+// The result always matches ErrJS. Returns nil for null or undefined.
+func FromJS(v js.Value) error {
+	if v.IsNull() || v.IsUndefined() {
+		return nil
+	}
+	if v.Type() != js.TypeObject {
+		return doterr.NewErr(ErrJS, errors.New(v.String()))
+	}
+	parts := []any{ErrJS}
+	sentinels := v.Get("sentinels")
+	if sentinels.Type() == js.TypeObject {
+		for i := 0; i < sentinels.Length(); i++ {
+			parts = append(parts, errors.New(sentinels.Index(i).String()))
+		}
+	}
+	meta := v.Get("meta")
+	if meta.Type() == js.TypeObject {
+		keys := js.Global().Get("Object").Call("keys", meta)
+		for i := 0; i < keys.Length(); i++ {
+			k := keys.Index(i).String()
+			parts = append(parts, k, goValue(meta.Get(k)))
+		}
+	}
+	msg := v.Get("message")
+	if msg.Type() == js.TypeString {
+		parts = append(parts, errors.New(msg.String()))
+	}
+	return doterr.NewErr(parts...)
+}
+
+// jsValue converts a metadata value to something js.ValueOf accepts.
+func jsValue(v any) any {
+	switch t := v.(type) {
+	case nil, bool, string, js.Value,
+		int, int8, int16, int32, int64,
+		uint, uint8, uint16, uint32, uint64, uintptr,
+		float32, float64:
+		return t
+	case error:
+		return t.Error()
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+// goValue converts a JS property value back to a Go metadata value.
+func goValue(v js.Value) any {
+	switch v.Type() {
+	case js.TypeBoolean:
+		return v.Bool()
+	case js.TypeNumber:
+		return v.Float()
+	case js.TypeString:
+		return v.String()
+	case js.TypeNull, js.TypeUndefined:
+		return nil
+	}
+	return v.String()
+}
@@ -0,0 +1,34 @@
+//go:build js && wasm
+
+package doterrjs
+
+import (
+	"errors"
+	"syscall/js"
+	"testing"
+
+	"github.com/mikeschinkel/go-doterr"
+)
+
+func TestToJS_FromJS_RoundTrip(t *testing.T) {
+	errNotFound := errors.New("not found")
+	v := ToJS(doterr.NewErr(errNotFound, "id", 7, "name", "x"))
+
+	if got := v.Get("name").String(); got != ErrorName {
+		t.Errorf("expected name %q, got %q", ErrorName, got)
+	}
+	if got := v.Get("meta").Get("id").Int(); got != 7 {
+		t.Errorf("expected meta.id 7, got %d", got)
+	}
+
+	back := FromJS(v)
+	if !errors.Is(back, ErrJS) {
+		t.Fatalf("expected ErrJS, got %v", back)
+	}
+	if name, _ := doterr.ErrValue[string](back, "name"); name != "x" {
+		t.Errorf("expected name=x, got %q", name)
+	}
+	if FromJS(js.Null()) != nil {
+		t.Error("expected nil for null")
+	}
+}
@@ -0,0 +1,71 @@
+// Package doterrkeys defines canonical metadata keys so that independent
+// teams attach the same facts under the same names:
+//
+//	return doterr.NewErr(ErrCharge, doterrkeys.UserID(uid), doterrkeys.Attempt(n), err)
+//
+// Keys are snake_case like the rest of doterr's metadata. Where OpenTelemetry
+// has a semantic convention for the same fact, OTelKey gives its attribute
+// name, so exporters can translate without a per-team mapping table.
+package doterrkeys
+
+import (
+	"time"
+
+	"github.com/mikeschinkel/go-doterr"
+)
+
+// Canonical metadata keys.
+const (
+	UserIDKey     = "user_id"
+	RequestIDKey  = "request_id"
+	TenantKey     = "tenant"
+	RegionKey     = "region"
+	AttemptKey    = "attempt"          // int, 1 for the first try
+	DurationMSKey = doterr.DurationKey // int64 milliseconds
+)
+
+// otelKeys maps canonical keys to OpenTelemetry semantic-convention
+// attribute names. Keys without a convention are absent.
+var otelKeys = map[string]string{
+	UserIDKey: "user.id",
+	RegionKey: "cloud.region",
+}
+
+// OTelKey returns the OpenTelemetry attribute name for a canonical key, or
+// false if the key has no such convention (or is not canonical).
+func OTelKey(key string) (string, bool) {
+	name, ok := otelKeys[key]
+	return name, ok
+}
+
+// Keys returns every canonical key, in declaration order.
+func Keys() []string {
+	return []string{UserIDKey, RequestIDKey, TenantKey, RegionKey, AttemptKey, DurationMSKey}
+}
+
+// UserID returns the UserIDKey pair.
+func UserID(id string) doterr.KV { return kv{UserIDKey, id} }
+
+// RequestID returns the RequestIDKey pair.
+func RequestID(id string) doterr.KV { return kv{RequestIDKey, id} }
+
+// Tenant returns the TenantKey pair.
+func Tenant(tenant string) doterr.KV { return kv{TenantKey, tenant} }
+
+// Region returns the RegionKey pair.
+func Region(region string) doterr.KV { return kv{RegionKey, region} }
+
+// Attempt returns the AttemptKey pair.
+func Attempt(n int) doterr.KV { return kv{AttemptKey, n} }
+
+// Duration returns the DurationMSKey pair for d, in whole milliseconds.
+func Duration(d time.Duration) doterr.KV { return kv{DurationMSKey, d.Milliseconds()} }
+
+// kv implements doterr.KV.
+type kv struct {
+	k string
+	v any
+}
+
+func (p kv) Key() string { return p.k }
+func (p kv) Value() any  { return p.v }
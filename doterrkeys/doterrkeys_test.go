@@ -0,0 +1,44 @@
+package doterrkeys
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mikeschinkel/go-doterr"
+)
+
+func TestHelpers(t *testing.T) {
+	sentinel := errors.New("charge failed")
+	err := doterr.NewErr(sentinel,
+		UserID("u1"), RequestID("r1"), Tenant("acme"), Region("eu-west-1"),
+		Attempt(2), Duration(1500*time.Millisecond),
+	)
+	var keys []string
+	for _, kv := range doterr.ErrMeta(err) {
+		keys = append(keys, kv.Key())
+	}
+	if len(keys) != len(Keys()) {
+		t.Fatalf("keys = %v, want %v", keys, Keys())
+	}
+	for i, k := range Keys() {
+		if keys[i] != k {
+			t.Errorf("key %d = %q, want %q", i, keys[i], k)
+		}
+	}
+	if ms, _ := doterr.ErrValue[int64](err, DurationMSKey); ms != 1500 {
+		t.Errorf("duration_ms = %d", ms)
+	}
+	if n, _ := doterr.ErrValue[int](err, AttemptKey); n != 2 {
+		t.Errorf("attempt = %d", n)
+	}
+}
+
+func TestOTelKey(t *testing.T) {
+	if name, ok := OTelKey(UserIDKey); !ok || name != "user.id" {
+		t.Errorf("OTelKey(user_id) = %q, %v", name, ok)
+	}
+	if _, ok := OTelKey("custom"); ok {
+		t.Error("expected no mapping for a non-canonical key")
+	}
+}
@@ -0,0 +1,183 @@
+// Package doterrpromote escalates doterr errors matching a rule to another
+// sentinel, so repeated transient failures can be reclassified in one place
+// instead of at every call site:
+//
+//	doterrpromote.When(doterrpromote.Meta("attempts").AtLeast(5), ErrPersistentFailure)
+//
+// Rules are evaluated at report time, by Apply, which doterrstats.Report
+// calls on every error it is given; call Apply at other exit points to
+// promote there too.
+package doterrpromote
+
+import (
+	"errors"
+	"reflect"
+	"slices"
+	"sync"
+	"sync/atomic"
+
+	"github.com/mikeschinkel/go-doterr"
+)
+
+// Cond reports whether a promotion rule applies to an error; see When.
+type Cond func(err error) bool
+
+// MetaCond builds Conds over the metadata value stored under a key, read
+// from the first doterr entry in the tree that carries it. As with
+// doterr.ErrValue, doterr.MergeLayer keeps the earliest value of a repeated
+// key, so counters that are re-set with doterr.WithErr want
+// doterr.MergeOverride. Create one with Meta.
+type MetaCond string
+
+// Meta returns the MetaCond for key:
+//
+//	doterrpromote.Meta("attempts").AtLeast(5)
+func Meta(key string) MetaCond { return MetaCond(key) }
+
+// Exists holds when the key is present, whatever its value.
+func (m MetaCond) Exists() Cond {
+	return func(err error) bool {
+		_, ok := m.value(err)
+		return ok
+	}
+}
+
+// Equals holds when the value is == v.
+func (m MetaCond) Equals(v any) Cond {
+	return func(err error) bool {
+		got, ok := m.value(err)
+		if !ok || reflect.TypeOf(got) != reflect.TypeOf(v) {
+			return false
+		}
+		return v == nil || reflect.TypeOf(v).Comparable() && got == v
+	}
+}
+
+// AtLeast holds when the value is a number (of any integer or float kind,
+// including types such as time.Duration) >= n.
+func (m MetaCond) AtLeast(n float64) Cond {
+	return func(err error) bool {
+		f, ok := m.number(err)
+		return ok && f >= n
+	}
+}
+
+// AtMost holds when the value is a number <= n; see AtLeast.
+func (m MetaCond) AtMost(n float64) Cond {
+	return func(err error) bool {
+		f, ok := m.number(err)
+		return ok && f <= n
+	}
+}
+
+// value returns the first value stored under m in the tree of err.
+func (m MetaCond) value(err error) (any, bool) {
+	for k, v := range doterr.MetaStream(err) {
+		if k == string(m) {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+func (m MetaCond) number(err error) (float64, bool) {
+	v, ok := m.value(err)
+	if !ok || v == nil {
+		return 0, false
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	}
+	return 0, false
+}
+
+// rule is one When rule.
+type rule struct {
+	cond     Cond
+	sentinel error
+}
+
+var (
+	rulesMu sync.Mutex
+	rules   atomic.Pointer[[]*rule] // copy-on-write; read on every Apply
+)
+
+// When adds a rule escalating errors matching cond to sentinel. A matching
+// rule merges sentinel into the error as doterr.WithBase would, unless the
+// error already matches it; rules run in registration order and each sees
+// the previous ones' promotions. The returned func removes the rule.
+func When(cond Cond, sentinel error) (remove func()) {
+	r := &rule{cond: cond, sentinel: sentinel}
+	update(func(rs []*rule) []*rule {
+		return append(rs, r)
+	})
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			update(func(rs []*rule) []*rule {
+				return slices.DeleteFunc(rs, func(q *rule) bool { return q == r })
+			})
+		})
+	}
+}
+
+func update(fn func([]*rule) []*rule) {
+	rulesMu.Lock()
+	defer rulesMu.Unlock()
+	var rs []*rule
+	if cur := rules.Load(); cur != nil {
+		rs = slices.Clone(*cur)
+	}
+	rs = fn(rs)
+	rules.Store(&rs)
+}
+
+// Apply applies the When rules to err and returns the result, which is err
+// itself if no rule applies. Errors doterr.WithErr treats as sealed (see
+// doterr.Seal) are never promoted, and a panicking cond is reported with
+// doterr.RunHook and treated as false. Returns nil if err is nil.
+func Apply(err error) error {
+	rs := rules.Load()
+	if err == nil || rs == nil || sealed(err) {
+		return err
+	}
+	for _, r := range *rs {
+		if errors.Is(err, r.sentinel) {
+			continue
+		}
+		var match bool
+		doterr.RunHook("doterrpromote.When", err, func() { match = r.cond(err) })
+		if match {
+			err = doterr.WithBase(err, r.sentinel)
+		}
+	}
+	return err
+}
+
+// sealed reports whether err is, or holds outside any doterr entry, a sealed
+// error, which doterr.WithBase would not enrich.
+func sealed(err error) bool {
+	if doterr.IsSealed(err) {
+		return true
+	}
+	//goland:noinspection GoTypeAssertionOnErrors
+	switch u := err.(type) {
+	case doterr.ErrNode:
+		return false // enriching an entry never touches the errors it holds
+	case interface{ Unwrap() []error }:
+		for _, kid := range u.Unwrap() {
+			if kid != nil && sealed(kid) {
+				return true
+			}
+		}
+	case interface{ Unwrap() error }:
+		return sealed(u.Unwrap())
+	}
+	return false
+}
@@ -0,0 +1,67 @@
+package doterrpromote
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mikeschinkel/go-doterr"
+)
+
+var errTest = errors.New("test")
+
+func TestWhen(t *testing.T) {
+	ErrPersistent := errors.New("persistent failure")
+	remove := When(Meta("attempts").AtLeast(5), ErrPersistent)
+	t.Cleanup(remove)
+
+	err := doterr.NewErr(errTest, "attempts", 4)
+	if errors.Is(Apply(err), ErrPersistent) {
+		t.Fatalf("promoted too early: %v", err)
+	}
+	err = Apply(doterr.NewErr(errTest, "attempts", uint8(9)))
+	if !errors.Is(err, ErrPersistent) || !errors.Is(err, errTest) {
+		t.Fatalf("expected promotion at attempts=9, got %v", err)
+	}
+	if got := Apply(err); len(doterr.ErrSentinels(got)) != 2 {
+		t.Errorf("expected a single promotion, got sentinels %v", doterr.ErrSentinels(got))
+	}
+	fresh := doterr.NewErr(errTest, "attempts", 5)
+	if errors.Is(Apply(doterr.Seal(fresh)), ErrPersistent) {
+		t.Error("expected sealed errors to be left alone")
+	}
+	if errors.Is(Apply(errors.Join(errors.New("other"), doterr.Seal(fresh))), ErrPersistent) {
+		t.Error("expected errors holding a sealed error to be left alone")
+	}
+
+	remove()
+	if errors.Is(Apply(fresh), ErrPersistent) {
+		t.Error("expected no promotion after remove")
+	}
+	if Apply(nil) != nil {
+		t.Error("expected nil for nil error")
+	}
+}
+
+func TestMetaCond(t *testing.T) {
+	err := doterr.NewErr(errTest, "region", "eu", "wait", 3*time.Second, "ratio", 0.25, "tags", []string{"a"})
+	cases := []struct {
+		name string
+		cond Cond
+		want bool
+	}{
+		{"exists", Meta("region").Exists(), true},
+		{"missing", Meta("zone").Exists(), false},
+		{"equals", Meta("region").Equals("eu"), true},
+		{"equals other type", Meta("ratio").Equals(float32(0.25)), false},
+		{"equals uncomparable", Meta("tags").Equals([]string{"a"}), false},
+		{"duration at least", Meta("wait").AtLeast(float64(2 * time.Second)), true},
+		{"float at most", Meta("ratio").AtMost(0.2), false},
+		{"string is no number", Meta("region").AtLeast(0), false},
+	}
+	for _, tc := range cases {
+		if got := tc.cond(err); got != tc.want {
+			t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
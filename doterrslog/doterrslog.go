@@ -0,0 +1,81 @@
+// Package doterrslog is the blessed way to log doterr errors with log/slog:
+// one record per error, with the error expanded into a consistent group
+// layout that log pipelines can index.
+package doterrslog
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+	"time"
+
+	"github.com/mikeschinkel/go-doterr"
+	"github.com/mikeschinkel/go-doterr/doterrfields"
+)
+
+// Key is the name of the group Attr and LogErr add to a record.
+const Key = "error"
+
+// LogErr emits a single record at level with the error expanded by Attr.
+// The record's source location is the caller of LogErr. Nothing is logged
+// if logger is not enabled for level; a nil err is logged without the group.
+func LogErr(ctx context.Context, logger *slog.Logger, level slog.Level, msg string, err error) {
+	if !logger.Enabled(ctx, level) {
+		return
+	}
+	var pcs [1]uintptr
+	runtime.Callers(2, pcs[:]) // skip runtime.Callers and LogErr
+	r := slog.NewRecord(time.Now(), level, msg, pcs[0])
+	if err != nil {
+		r.AddAttrs(Attr(err))
+	}
+	_ = logger.Handler().Handle(ctx, r)
+}
+
+// Attr expands err into a group under Key:
+//
+//	error.message             err.Error()
+//	error.sentinels           []string, from doterr.ErrSentinels
+//	error.code                registered code, from doterr.ErrCode (omitted if none)
+//	error.meta.*              the entry's metadata, from doterr.ErrMeta
+//	error.job.*               queue, id and attempt, from doterr.ErrJob (omitted if none)
+//	error.origin.ops          logical op trace, from doterr.ErrOpTrace (omitted if none)
+//	error.origin.fingerprint  doterr.ErrFingerprint
+//
+// Use it to attach an error to records built some other way, e.g.
+// logger.Warn("retrying", doterrslog.Attr(err)).
+func Attr(err error) slog.Attr {
+	attrs := []slog.Attr{slog.String("message", err.Error())}
+	sentinels := doterr.ErrSentinels(err)
+	if len(sentinels) > 0 {
+		msgs := make([]string, len(sentinels))
+		for i, s := range sentinels {
+			msgs[i] = s.Error()
+		}
+		attrs = append(attrs, slog.Any("sentinels", msgs))
+	}
+	if code := doterr.ErrCode(err); code != "" {
+		attrs = append(attrs, slog.String("code", code))
+	}
+	var metaAttrs []slog.Attr
+	for _, pair := range doterr.ErrMeta(err) {
+		if doterrfields.Emits("doterrslog", pair.Key()) {
+			metaAttrs = append(metaAttrs, slog.Any(pair.Key(), doterr.EmitValue("doterrslog", pair.Key(), pair.Value())))
+		}
+	}
+	if len(metaAttrs) > 0 {
+		attrs = append(attrs, slog.Attr{Key: "meta", Value: slog.GroupValue(metaAttrs...)})
+	}
+	if job, ok := doterr.ErrJob(err); ok {
+		attrs = append(attrs, slog.Group("job",
+			slog.String("queue", job.Queue),
+			slog.String("id", job.ID),
+			slog.Int("attempt", job.Attempt)))
+	}
+	origin := []slog.Attr{slog.String("fingerprint", doterr.ErrFingerprint(err))}
+	if ops := doterr.ErrOpTrace(err); ops != "" {
+		origin = append([]slog.Attr{slog.String("ops", ops)}, origin...)
+	}
+	attrs = append(attrs, slog.Attr{Key: "origin", Value: slog.GroupValue(origin...)})
+	return slog.Attr{Key: Key, Value: slog.GroupValue(attrs...)}
+}
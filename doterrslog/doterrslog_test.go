@@ -0,0 +1,74 @@
+package doterrslog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/mikeschinkel/go-doterr"
+)
+
+var ErrSlogTest = doterr.MustRegisterSentinel(errors.New("slog test"), "SLOG_TEST", "")
+
+func TestLogErr_ExpandsGroups(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{AddSource: true}))
+	err := doterr.NewOpErr("store.Save", ErrSlogTest, "user", "alice", "attempt", 2)
+
+	LogErr(context.Background(), logger, slog.LevelError, "save failed", err)
+
+	var rec map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &rec); err != nil {
+		t.Fatalf("%v: %s", err, buf.String())
+	}
+	if rec["msg"] != "save failed" || rec["level"] != "ERROR" {
+		t.Errorf("unexpected record %v", rec)
+	}
+	source, _ := rec["source"].(map[string]any)
+	if file, _ := source["file"].(string); !strings.HasSuffix(file, "doterrslog_test.go") {
+		t.Errorf("expected caller as source, got %v", source)
+	}
+	group := rec[Key].(map[string]any)
+	if group["code"] != "SLOG_TEST" {
+		t.Errorf("unexpected code %v", group["code"])
+	}
+	if s := group["sentinels"].([]any); len(s) != 1 || s[0] != "slog test" {
+		t.Errorf("unexpected sentinels %v", s)
+	}
+	meta := group["meta"].(map[string]any)
+	if meta["user"] != "alice" || meta["attempt"] != float64(2) || meta["op"] != "store.Save" {
+		t.Errorf("unexpected meta %v", meta)
+	}
+	origin := group["origin"].(map[string]any)
+	if origin["ops"] != "store.Save" || origin["fingerprint"] != doterr.ErrFingerprint(err) {
+		t.Errorf("unexpected origin %v", origin)
+	}
+}
+
+func TestAttr_Job(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	logger.Error("job failed", Attr(doterr.WithJob(doterr.NewErr(ErrSlogTest), "emails", "j-7", 2)))
+
+	var rec map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &rec); err != nil {
+		t.Fatalf("%v: %s", err, buf.String())
+	}
+	job, _ := rec[Key].(map[string]any)["job"].(map[string]any)
+	if job["queue"] != "emails" || job["id"] != "j-7" || job["attempt"] != float64(2) {
+		t.Errorf("unexpected job group %v", job)
+	}
+}
+
+func TestLogErr_RespectsLevel(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelWarn}))
+	LogErr(context.Background(), logger, slog.LevelInfo, "ignored", doterr.NewErr(ErrSlogTest))
+	if buf.Len() != 0 {
+		t.Errorf("expected nothing logged below level, got %q", buf.String())
+	}
+}
@@ -0,0 +1,87 @@
+package doterrstats
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// HealthStatus is the outcome of evaluating reported error rates.
+type HealthStatus int
+
+const (
+	Healthy HealthStatus = iota
+	Degraded
+	Unhealthy
+)
+
+func (s HealthStatus) String() string {
+	switch s {
+	case Healthy:
+		return "healthy"
+	case Degraded:
+		return "degraded"
+	case Unhealthy:
+		return "unhealthy"
+	}
+	return "unknown"
+}
+
+// DefaultHealthWindow is used when HealthEvaluator.Window is zero.
+const DefaultHealthWindow = time.Minute
+
+// HealthEvaluator reports degraded/unhealthy status when the rate of reported
+// errors over Window reaches the configured thresholds (errors per second).
+// A zero threshold disables that level. The zero value is always Healthy.
+//
+// HealthEvaluator implements http.Handler for use as a readiness probe: it
+// responds 200 when Healthy or Degraded and 503 when Unhealthy, with a JSON
+// body describing the evaluation.
+type HealthEvaluator struct {
+	Window        time.Duration
+	DegradedRate  float64
+	UnhealthyRate float64
+}
+
+// Health is the detail behind a HealthStatus.
+type Health struct {
+	Status HealthStatus
+	Rate   float64       // errors per second over Window
+	Window time.Duration // window actually evaluated
+}
+
+// Evaluate computes the current health from doterrstats.ErrRate.
+func (h HealthEvaluator) Evaluate() Health {
+	window := h.Window
+	if window <= 0 {
+		window = DefaultHealthWindow
+	}
+	out := Health{
+		Status: Healthy,
+		Rate:   ErrRate(window),
+		Window: window,
+	}
+	switch {
+	case h.UnhealthyRate > 0 && out.Rate >= h.UnhealthyRate:
+		out.Status = Unhealthy
+	case h.DegradedRate > 0 && out.Rate >= h.DegradedRate:
+		out.Status = Degraded
+	}
+	return out
+}
+
+func (h HealthEvaluator) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	health := h.Evaluate()
+	code := http.StatusOK
+	if health.Status == Unhealthy {
+		code = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"status":         health.Status.String(),
+		"rate":           health.Rate,
+		"window_seconds": health.Window.Seconds(),
+	})
+}
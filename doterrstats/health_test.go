@@ -0,0 +1,72 @@
+package doterrstats
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mikeschinkel/go-doterr"
+)
+
+// fakeClock pins now() for the duration of a test and resets windowed rates.
+func fakeClock(t *testing.T, start time.Time) *time.Time {
+	t.Helper()
+	clock := start
+	now = func() time.Time { return clock }
+	rates = newRateCounter()
+	t.Cleanup(func() {
+		now = time.Now
+		rates = newRateCounter()
+	})
+	return &clock
+}
+
+func TestErrRate_CountsOnlyWithinWindow(t *testing.T) {
+	clock := fakeClock(t, time.Unix(1_000_000, 0))
+	for i := 0; i < 10; i++ {
+		Report(doterr.NewErr(ErrTest))
+	}
+	*clock = clock.Add(30 * time.Second)
+	for i := 0; i < 5; i++ {
+		Report(doterr.NewErr(ErrTest))
+	}
+	if n := ErrCount(10 * time.Second); n != 5 {
+		t.Errorf("expected 5 in 10s window, got %d", n)
+	}
+	if n := ErrCount(time.Minute); n != 15 {
+		t.Errorf("expected 15 in 1m window, got %d", n)
+	}
+	if r := ErrRate(10 * time.Second); r != 0.5 {
+		t.Errorf("expected rate 0.5/s, got %v", r)
+	}
+}
+
+func TestHealthEvaluator_Thresholds(t *testing.T) {
+	fakeClock(t, time.Unix(2_000_000, 0))
+	h := HealthEvaluator{Window: 10 * time.Second, DegradedRate: 0.5, UnhealthyRate: 2}
+
+	if s := h.Evaluate().Status; s != Healthy {
+		t.Errorf("expected healthy, got %v", s)
+	}
+	for i := 0; i < 5; i++ {
+		Report(doterr.NewErr(ErrTest))
+	}
+	if s := h.Evaluate().Status; s != Degraded {
+		t.Errorf("expected degraded, got %v", s)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ready", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected 200 while degraded, got %d", rec.Code)
+	}
+
+	for i := 0; i < 15; i++ {
+		Report(doterr.NewErr(ErrTest))
+	}
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ready", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 when unhealthy, got %d", rec.Code)
+	}
+}
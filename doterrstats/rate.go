@@ -0,0 +1,82 @@
+package doterrstats
+
+import (
+	"sync"
+	"time"
+)
+
+// MaxRateWindow is the longest window ErrRate and ErrCount can look back.
+// Longer windows are clamped to it.
+const MaxRateWindow = time.Hour
+
+// rateCounter counts reported errors in one-second buckets covering the
+// last MaxRateWindow, so windowed rates cost O(window) to read and O(1) to
+// record.
+type rateCounter struct {
+	mu      sync.Mutex
+	buckets []int64 // buckets[sec % len] counts errors reported in second sec
+	secs    []int64 // secs[i] is the unix second buckets[i] currently holds
+}
+
+var rates = newRateCounter()
+
+func newRateCounter() *rateCounter {
+	n := int(MaxRateWindow / time.Second)
+	return &rateCounter{
+		buckets: make([]int64, n),
+		secs:    make([]int64, n),
+	}
+}
+
+// ErrCount returns the number of errors reported within the trailing window.
+func ErrCount(window time.Duration) int64 {
+	return rates.count(now(), window)
+}
+
+// ErrRate returns the average number of errors reported per second within
+// the trailing window. Returns 0 for a non-positive window.
+func ErrRate(window time.Duration) float64 {
+	secs := windowSecs(window)
+	if secs == 0 {
+		return 0
+	}
+	return float64(rates.count(now(), window)) / float64(secs)
+}
+
+func (r *rateCounter) add(t time.Time) {
+	sec := t.Unix()
+	i := int(sec % int64(len(r.buckets)))
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.secs[i] != sec {
+		r.secs[i] = sec
+		r.buckets[i] = 0
+	}
+	r.buckets[i]++
+}
+
+func (r *rateCounter) count(t time.Time, window time.Duration) (n int64) {
+	secs := windowSecs(window)
+	end := t.Unix()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for sec := end - secs + 1; sec <= end; sec++ {
+		i := int(sec % int64(len(r.buckets)))
+		if r.secs[i] == sec {
+			n += r.buckets[i]
+		}
+	}
+	return n
+}
+
+// windowSecs converts window to whole seconds, rounding up and clamping to
+// MaxRateWindow.
+func windowSecs(window time.Duration) int64 {
+	if window <= 0 {
+		return 0
+	}
+	if window > MaxRateWindow {
+		window = MaxRateWindow
+	}
+	return int64((window + time.Second - 1) / time.Second)
+}
@@ -0,0 +1,131 @@
+// Package doterrstats keeps in-process records of reported errors so that
+// support tooling and debug endpoints can answer "what has gone wrong lately"
+// without a log query. Errors are fed in with Report(); everything else in the
+// package is read-only views over what has been reported.
+package doterrstats
+
+import (
+	"sync"
+	"time"
+
+	"github.com/mikeschinkel/go-doterr"
+	"github.com/mikeschinkel/go-doterr/doterrpromote"
+)
+
+// DefaultRecentErrsSize is the capacity of the recent-errors ring buffer
+// until SetRecentErrsSize is called.
+const DefaultRecentErrsSize = 100
+
+// RecentErr is one reported error as retained by the ring buffer.
+type RecentErr struct {
+	Err         error
+	Fingerprint string
+	Time        time.Time
+	SampleRate  float64 // rate it was retained at; weigh it by 1/SampleRate
+}
+
+// ring is a fixed-capacity circular buffer of RecentErr values.
+type ring struct {
+	mu    sync.Mutex
+	items []RecentErr
+	next  int  // index the next item will be written to
+	full  bool // true once the buffer has wrapped at least once
+}
+
+var recent = newRing(DefaultRecentErrsSize)
+
+// now is replaced in tests to make timestamps deterministic.
+var now = time.Now
+
+func newRing(size int) *ring {
+	if size < 1 {
+		size = 1
+	}
+	return &ring{items: make([]RecentErr, size)}
+}
+
+// Report records err as a reported error: it is counted by the stats
+// collector, included in windowed error rates, delivered to subscribers as a
+// classified Failure and, if sampled (see SetSampleRate), added to the
+// recent-errors ring buffer. err is first passed through
+// doterrpromote.Apply, so doterrpromote.When rules apply. nil errors are
+// ignored.
+func Report(err error) {
+	if err == nil {
+		return
+	}
+	err = doterrpromote.Apply(err)
+	fp := doterr.ErrFingerprint(err)
+	t := now()
+	stats.add(err, fp)
+	rates.add(t)
+	publish(err, fp, t)
+	rate, keep := sample(err)
+	if !keep {
+		return
+	}
+	recent.add(RecentErr{
+		Err:         err,
+		Fingerprint: fp,
+		Time:        t,
+		SampleRate:  rate,
+	})
+}
+
+// RecentErrs returns the most recently reported errors, oldest first.
+// The returned slice is a copy and holds at most the configured capacity.
+func RecentErrs() []RecentErr {
+	return recent.snapshot()
+}
+
+// SetRecentErrsSize changes the capacity of the ring buffer. The most recent
+// errors that still fit are kept. Sizes below 1 are treated as 1.
+func SetRecentErrsSize(n int) {
+	recent.resize(n)
+}
+
+func (r *ring) add(item RecentErr) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.items[r.next] = item
+	r.next++
+	if r.next == len(r.items) {
+		r.next = 0
+		r.full = true
+	}
+}
+
+func (r *ring) snapshot() []RecentErr {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.ordered()
+}
+
+// ordered returns the buffered items oldest first. Caller must hold r.mu.
+func (r *ring) ordered() []RecentErr {
+	if !r.full {
+		out := make([]RecentErr, r.next)
+		copy(out, r.items[:r.next])
+		return out
+	}
+	out := make([]RecentErr, 0, len(r.items))
+	out = append(out, r.items[r.next:]...)
+	out = append(out, r.items[:r.next]...)
+	return out
+}
+
+func (r *ring) resize(n int) {
+	if n < 1 {
+		n = 1
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	kept := r.ordered()
+	if len(kept) > n {
+		kept = kept[len(kept)-n:]
+	}
+	r.items = make([]RecentErr, n)
+	copy(r.items, kept)
+	r.next = len(kept) % n
+	r.full = len(kept) == n
+}
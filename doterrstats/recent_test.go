@@ -0,0 +1,85 @@
+package doterrstats
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mikeschinkel/go-doterr"
+	"github.com/mikeschinkel/go-doterr/doterrpromote"
+)
+
+var ErrTest = errors.New("test")
+
+func resetRecent(t *testing.T, size int) {
+	t.Helper()
+	recent = newRing(size)
+	t.Cleanup(func() { recent = newRing(DefaultRecentErrsSize) })
+}
+
+func TestRecentErrs_KeepsLastN(t *testing.T) {
+	resetRecent(t, 3)
+	for i := 0; i < 5; i++ {
+		Report(doterr.NewErr(ErrTest, "i", i))
+	}
+	got := RecentErrs()
+	if len(got) != 3 {
+		t.Fatalf("expected 3 recent errors, got %d", len(got))
+	}
+	for n, re := range got {
+		i, _ := doterr.ErrValue[int](re.Err, "i")
+		if i != n+2 {
+			t.Errorf("position %d: expected i=%d, got %d", n, n+2, i)
+		}
+	}
+}
+
+func TestRecentErrs_RecordsFingerprintAndTime(t *testing.T) {
+	resetRecent(t, 10)
+	Report(nil)
+	err := doterr.NewErr(ErrTest, "id", 1)
+	Report(err)
+	got := RecentErrs()
+	if len(got) != 1 {
+		t.Fatalf("expected 1 recent error, got %d", len(got))
+	}
+	if got[0].Fingerprint != doterr.ErrFingerprint(err) {
+		t.Errorf("unexpected fingerprint %q", got[0].Fingerprint)
+	}
+	if got[0].Time.IsZero() {
+		t.Error("expected timestamp to be set")
+	}
+}
+
+func TestSetRecentErrsSize_KeepsNewest(t *testing.T) {
+	resetRecent(t, 5)
+	for i := 0; i < 5; i++ {
+		Report(doterr.NewErr(ErrTest, "i", i))
+	}
+	SetRecentErrsSize(2)
+	got := RecentErrs()
+	if len(got) != 2 {
+		t.Fatalf("expected 2 recent errors, got %d", len(got))
+	}
+	i, _ := doterr.ErrValue[int](got[1].Err, "i")
+	if i != 4 {
+		t.Errorf("expected newest error last, got i=%d", i)
+	}
+	Report(doterr.NewErr(ErrTest, "i", 5))
+	got = RecentErrs()
+	i, _ = doterr.ErrValue[int](got[0].Err, "i")
+	if len(got) != 2 || i != 4 {
+		t.Errorf("expected ring to keep rotating after resize, got %v", got)
+	}
+}
+
+func TestReport_AppliesPromotions(t *testing.T) {
+	resetRecent(t, 10)
+	ErrEscalated := errors.New("escalated")
+	t.Cleanup(doterrpromote.When(doterrpromote.Meta("attempts").AtLeast(3), ErrEscalated))
+
+	Report(doterr.NewErr(ErrTest, "attempts", 3))
+	got := RecentErrs()
+	if len(got) != 1 || !errors.Is(got[0].Err, ErrEscalated) {
+		t.Errorf("expected the reported error to be promoted, got %v", got)
+	}
+}
@@ -0,0 +1,65 @@
+package doterrstats
+
+import (
+	"math/rand/v2"
+	"sync"
+
+	"github.com/mikeschinkel/go-doterr"
+)
+
+var (
+	sampleMu    sync.RWMutex
+	sampleRates = make(map[doterr.Severity]float64) // absent means 1
+)
+
+// draw returns a uniform value in [0, 1); replaced in tests.
+var draw = rand.Float64
+
+// SetSampleRate sets the fraction, from 0 to 1, of reported errors of
+// severity sev (see doterr.ErrSeverity) that Report retains in the
+// recent-errors buffer, and returns the previous rate. Rates outside [0, 1]
+// are clamped; every severity starts at 1. Counters, windowed rates and
+// subscribers always see every error, so they stay exact while retention
+// cost scales with importance:
+//
+//	doterrstats.SetSampleRate(doterr.SeverityWarn, 0.01)
+func SetSampleRate(sev doterr.Severity, rate float64) float64 {
+	rate = min(max(rate, 0), 1)
+	sampleMu.Lock()
+	defer sampleMu.Unlock()
+	prev := rateFor(sev)
+	if rate == 1 {
+		delete(sampleRates, sev)
+	} else {
+		sampleRates[sev] = rate
+	}
+	return prev
+}
+
+// SampleRate returns the sampling rate of severity sev.
+func SampleRate(sev doterr.Severity) float64 {
+	sampleMu.RLock()
+	defer sampleMu.RUnlock()
+	return rateFor(sev)
+}
+
+// rateFor returns the rate of sev. Caller must hold sampleMu.
+func rateFor(sev doterr.Severity) float64 {
+	if rate, ok := sampleRates[sev]; ok {
+		return rate
+	}
+	return 1
+}
+
+// sample decides whether err is retained, returning the rate it was sampled
+// at.
+func sample(err error) (rate float64, keep bool) {
+	sampleMu.RLock()
+	sampling := len(sampleRates) > 0
+	sampleMu.RUnlock()
+	if !sampling {
+		return 1, true
+	}
+	rate = SampleRate(doterr.ErrSeverity(err))
+	return rate, rate == 1 || draw() < rate
+}
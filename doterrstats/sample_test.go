@@ -0,0 +1,43 @@
+package doterrstats
+
+import (
+	"testing"
+
+	"github.com/mikeschinkel/go-doterr"
+)
+
+func TestSampleRate_WeightsRetentionBySeverity(t *testing.T) {
+	resetRecent(t, 100)
+	ResetStats()
+	draws := []float64{0.5, 0.005, 0.9}
+	prevDraw := draw
+	draw = func() float64 { d := draws[0]; draws = draws[1:]; return d }
+	prevWarn := SetSampleRate(doterr.SeverityWarn, 0.01)
+	t.Cleanup(func() { draw = prevDraw; SetSampleRate(doterr.SeverityWarn, prevWarn) })
+
+	var got []Failure
+	unsubscribe := Subscribe(func(f Failure) { got = append(got, f) })
+	defer unsubscribe()
+
+	for i := range 3 {
+		Report(doterr.NewErr(ErrTest, doterr.SeverityWarn, "i", i))
+	}
+	Report(doterr.NewErr(ErrTest, doterr.SeverityCritical))
+
+	if len(got) != 4 {
+		t.Fatalf("expected every failure delivered to subscribers, got %d", len(got))
+	}
+	recent := RecentErrs()
+	if len(recent) != 2 || recent[0].SampleRate != 0.01 || recent[1].SampleRate != 1 {
+		t.Fatalf("expected the second warn and the critical error retained, got %+v", recent)
+	}
+	if i, _ := doterr.ErrValue[int](recent[0].Err, "i"); i != 1 {
+		t.Errorf("expected the warn drawn under its rate, got i=%d", i)
+	}
+	if total := ErrStats().Total; total != 4 {
+		t.Errorf("expected exact counters, got total %d", total)
+	}
+	if SetSampleRate(doterr.SeverityWarn, 7) != 0.01 || SampleRate(doterr.SeverityWarn) != 1 {
+		t.Error("expected the previous rate back and the new one clamped to 1")
+	}
+}
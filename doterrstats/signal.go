@@ -0,0 +1,112 @@
+package doterrstats
+
+import (
+	"sync"
+	"time"
+
+	"github.com/mikeschinkel/go-doterr"
+)
+
+// Failure is a reported error classified for consumers such as circuit
+// breakers, which can base trip decisions on the kind of failure (e.g.
+// errors.Is(f.Err, ErrTimeout) or !f.Retryable) rather than raw counts.
+type Failure struct {
+	Err         error
+	Sentinels   []error // doterr.ErrSentinels(Err)
+	Retryable   bool    // doterr.IsRetryable(Err)
+	Severity    doterr.Severity
+	Fingerprint string
+	Time        time.Time
+}
+
+type subscriber struct {
+	fn func(Failure)
+}
+
+var (
+	subsMu sync.RWMutex
+	subs   []*subscriber
+)
+
+// Subscribe registers fn to be called synchronously, from the reporting
+// goroutine, with every Failure passed to Report. fn must be safe for
+// concurrent use and should return quickly; a panic in fn is reported with
+// doterr.RunHook rather than unwinding Report. The returned func unsubscribes.
+func Subscribe(fn func(Failure)) (unsubscribe func()) {
+	sub := &subscriber{fn: fn}
+	subsMu.Lock()
+	subs = append(subs, sub)
+	subsMu.Unlock()
+	var once sync.Once
+	return func() {
+		once.Do(func() { removeSubscriber(sub) })
+	}
+}
+
+// SubscribeChan returns a channel receiving every Failure passed to Report.
+// Sends never block reporting: when the channel's buffer of size buf is full
+// the Failure is dropped for this subscriber. The returned func unsubscribes
+// and closes the channel.
+func SubscribeChan(buf int) (<-chan Failure, func()) {
+	ch := make(chan Failure, buf)
+	var mu sync.Mutex
+	closed := false
+	unsubscribe := Subscribe(func(f Failure) {
+		mu.Lock()
+		defer mu.Unlock()
+		if closed {
+			return
+		}
+		select {
+		case ch <- f:
+		default:
+		}
+	})
+	return ch, func() {
+		unsubscribe()
+		mu.Lock()
+		defer mu.Unlock()
+		if !closed {
+			closed = true
+			close(ch)
+		}
+	}
+}
+
+func removeSubscriber(sub *subscriber) {
+	subsMu.Lock()
+	defer subsMu.Unlock()
+	for i, s := range subs {
+		if s == sub {
+			subs = append(subs[:i:i], subs[i+1:]...)
+			return
+		}
+	}
+}
+
+// hasSubscribers lets Report skip classification when nobody is listening.
+func hasSubscribers() bool {
+	subsMu.RLock()
+	defer subsMu.RUnlock()
+	return len(subs) > 0
+}
+
+func publish(err error, fingerprint string, t time.Time) {
+	if !hasSubscribers() {
+		return
+	}
+	f := Failure{
+		Err:         err,
+		Sentinels:   doterr.ErrSentinels(err),
+		Retryable:   doterr.IsRetryable(err),
+		Severity:    doterr.ErrSeverity(err),
+		Fingerprint: fingerprint,
+		Time:        t,
+	}
+	subsMu.RLock()
+	current := subs
+	subsMu.RUnlock()
+	for _, sub := range current {
+		doterr.RunHook("doterrstats.Subscribe", err, func() { sub.fn(f) })
+	}
+}
@@ -0,0 +1,63 @@
+package doterrstats
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mikeschinkel/go-doterr"
+)
+
+func TestSubscribe_ReceivesClassifiedFailures(t *testing.T) {
+	errTimeout := errors.New("timeout")
+	var got []Failure
+	unsubscribe := Subscribe(func(f Failure) { got = append(got, f) })
+
+	Report(doterr.NewErr(errTimeout, doterr.ErrRetryable, doterr.SeverityWarn))
+	Report(doterr.NewErr(ErrTest, doterr.SeverityCritical))
+	unsubscribe()
+	Report(doterr.NewErr(ErrTest))
+
+	if len(got) != 2 {
+		t.Fatalf("expected 2 failures before unsubscribe, got %d", len(got))
+	}
+	if !got[0].Retryable || got[0].Severity != doterr.SeverityWarn || !errors.Is(got[0].Err, errTimeout) {
+		t.Errorf("unexpected first failure %+v", got[0])
+	}
+	if got[1].Retryable || got[1].Severity != doterr.SeverityCritical {
+		t.Errorf("unexpected second failure %+v", got[1])
+	}
+}
+
+func TestSubscribeChan_DropsWhenFull(t *testing.T) {
+	ch, unsubscribe := SubscribeChan(1)
+	Report(doterr.NewErr(ErrTest, "i", 1))
+	Report(doterr.NewErr(ErrTest, "i", 2))
+	unsubscribe()
+
+	var n int
+	for f := range ch {
+		n++
+		if i, _ := doterr.ErrValue[int](f.Err, "i"); i != 1 {
+			t.Errorf("expected first failure to be kept, got i=%d", i)
+		}
+	}
+	if n != 1 {
+		t.Errorf("expected 1 buffered failure, got %d", n)
+	}
+}
+
+func TestSubscribe_PanicIsReported(t *testing.T) {
+	var reported []error
+	prev := doterr.SetHookErrHandler(func(err error) { reported = append(reported, err) })
+	defer doterr.SetHookErrHandler(prev)
+	unsubscribe := Subscribe(func(Failure) { panic("subscriber broke") })
+	defer unsubscribe()
+
+	Report(doterr.NewErr(ErrTest))
+	if len(reported) != 1 || !errors.Is(reported[0], doterr.ErrHookFailed) {
+		t.Fatalf("expected one hook failure, got %v", reported)
+	}
+	if hook, _ := doterr.ErrValue[string](reported[0], doterr.HookKey); hook != "doterrstats.Subscribe" {
+		t.Errorf("unexpected hook %q", hook)
+	}
+}
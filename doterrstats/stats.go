@@ -0,0 +1,73 @@
+package doterrstats
+
+import (
+	"maps"
+	"sync"
+
+	"github.com/mikeschinkel/go-doterr"
+)
+
+// Stats is a point-in-time snapshot of the counters kept for reported errors.
+// The maps are copies and may be modified freely by the caller.
+type Stats struct {
+	Total         int64            // number of errors reported
+	BySentinel    map[string]int64 // keyed by sentinel message
+	ByFingerprint map[string]int64 // keyed by doterr.ErrFingerprint
+}
+
+// collector accumulates counters for every reported error.
+type collector struct {
+	mu            sync.Mutex
+	total         int64
+	bySentinel    map[string]int64
+	byFingerprint map[string]int64
+}
+
+var stats = newCollector()
+
+func newCollector() *collector {
+	return &collector{
+		bySentinel:    make(map[string]int64),
+		byFingerprint: make(map[string]int64),
+	}
+}
+
+// ErrStats returns a snapshot of the counters for all errors reported since
+// process start or the last ResetStats.
+func ErrStats() Stats {
+	return stats.snapshot()
+}
+
+// ResetStats zeroes all counters. The recent-errors buffer is not affected.
+func ResetStats() {
+	stats.reset()
+}
+
+func (c *collector) add(err error, fingerprint string) {
+	sentinels := doterr.ErrSentinels(err)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.total++
+	c.byFingerprint[fingerprint]++
+	for _, s := range sentinels {
+		c.bySentinel[s.Error()]++
+	}
+}
+
+func (c *collector) snapshot() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return Stats{
+		Total:         c.total,
+		BySentinel:    maps.Clone(c.bySentinel),
+		ByFingerprint: maps.Clone(c.byFingerprint),
+	}
+}
+
+func (c *collector) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.total = 0
+	clear(c.bySentinel)
+	clear(c.byFingerprint)
+}
@@ -0,0 +1,34 @@
+package doterrstats
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mikeschinkel/go-doterr"
+)
+
+func TestErrStats_CountsBySentinelAndFingerprint(t *testing.T) {
+	ResetStats()
+	t.Cleanup(ResetStats)
+	errOther := errors.New("other")
+	a := doterr.NewErr(ErrTest, "id", 1)
+	Report(a)
+	Report(doterr.NewErr(ErrTest, "id", 2))
+	Report(doterr.NewErr(errOther, ErrTest))
+
+	s := ErrStats()
+	if s.Total != 3 {
+		t.Errorf("expected total 3, got %d", s.Total)
+	}
+	if s.BySentinel["test"] != 3 || s.BySentinel["other"] != 1 {
+		t.Errorf("unexpected sentinel counts %v", s.BySentinel)
+	}
+	if s.ByFingerprint[doterr.ErrFingerprint(a)] != 2 {
+		t.Errorf("unexpected fingerprint counts %v", s.ByFingerprint)
+	}
+
+	ResetStats()
+	if s := ErrStats(); s.Total != 0 || len(s.BySentinel) != 0 {
+		t.Errorf("expected reset stats, got %+v", s)
+	}
+}
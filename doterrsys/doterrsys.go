@@ -0,0 +1,29 @@
+// Package doterrsys classifies operating-system error codes (errnos) found in
+// an error chain, wrapping them with canonical sentinels and attaching the
+// numeric code and its platform name/message as metadata, for system-level
+// tooling built on doterr.
+package doterrsys
+
+import (
+	"errors"
+)
+
+// Canonical sentinels for common OS-level failures. The same sentinel is used
+// on every platform, so callers can write portable checks such as
+// errors.Is(err, doterrsys.ErrAccessDenied).
+var (
+	ErrSyscall          = errors.New("system error")
+	ErrAccessDenied     = errors.New("access denied")
+	ErrNotFound         = errors.New("not found")
+	ErrSharingViolation = errors.New("sharing violation")
+	ErrConnReset        = errors.New("connection reset")
+	ErrConnRefused      = errors.New("connection refused")
+	ErrTooManyFiles     = errors.New("too many open files")
+)
+
+// Metadata keys attached by ClassifyErrno.
+const (
+	ErrnoKey        = "errno"         // numeric code, as int64
+	ErrnoNameKey    = "errno_name"    // symbolic name, e.g. "ENOENT" or "ERROR_ACCESS_DENIED"
+	ErrnoMessageKey = "errno_message" // platform message text
+)
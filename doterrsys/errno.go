@@ -0,0 +1,39 @@
+//go:build unix || windows
+
+package doterrsys
+
+import (
+	"errors"
+	"syscall"
+
+	"github.com/mikeschinkel/go-doterr"
+)
+
+type errnoInfo struct {
+	name     string
+	sentinel error
+}
+
+// ClassifyErrno looks for a syscall.Errno anywhere in the chain of err. If one
+// is found, it returns a doterr entry with the canonical sentinel for the code
+// (ErrSyscall if it has none), ErrnoKey, ErrnoNameKey when the code is known,
+// and ErrnoMessageKey holding the platform message (strerror on Unix,
+// FormatMessage on Windows), with err as the trailing cause. Otherwise err is
+// returned unchanged.
+func ClassifyErrno(err error) error {
+	var errno syscall.Errno
+	if !errors.As(err, &errno) {
+		return err
+	}
+	info, known := errnos[errno]
+	sentinel := info.sentinel
+	if !known {
+		sentinel = ErrSyscall
+	}
+	parts := []any{sentinel, ErrnoKey, int64(errno)}
+	if known {
+		parts = append(parts, ErrnoNameKey, info.name)
+	}
+	parts = append(parts, ErrnoMessageKey, errno.Error(), err)
+	return doterr.NewErr(parts...)
+}
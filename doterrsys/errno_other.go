@@ -0,0 +1,8 @@
+//go:build !unix && !windows
+
+package doterrsys
+
+// ClassifyErrno returns err unchanged on platforms without errno support.
+func ClassifyErrno(err error) error {
+	return err
+}
@@ -0,0 +1,18 @@
+//go:build unix
+
+package doterrsys
+
+import (
+	"syscall"
+)
+
+var errnos = map[syscall.Errno]errnoInfo{
+	syscall.EACCES:       {"EACCES", ErrAccessDenied},
+	syscall.EPERM:        {"EPERM", ErrAccessDenied},
+	syscall.ENOENT:       {"ENOENT", ErrNotFound},
+	syscall.ECONNRESET:   {"ECONNRESET", ErrConnReset},
+	syscall.ECONNREFUSED: {"ECONNREFUSED", ErrConnRefused},
+	syscall.EMFILE:       {"EMFILE", ErrTooManyFiles},
+	syscall.ENFILE:       {"ENFILE", ErrTooManyFiles},
+	syscall.ETXTBSY:      {"ETXTBSY", ErrSharingViolation},
+}
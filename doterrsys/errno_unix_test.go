@@ -0,0 +1,52 @@
+//go:build unix
+
+package doterrsys
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/mikeschinkel/go-doterr"
+)
+
+func TestClassifyErrno_NotFound(t *testing.T) {
+	_, cause := os.Open("/definitely/not/here")
+	err := ClassifyErrno(cause)
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Error("expected original error as cause")
+	}
+	if name, _ := doterr.ErrValue[string](err, ErrnoNameKey); name != "ENOENT" {
+		t.Errorf("expected ENOENT, got %q", name)
+	}
+	if n, _ := doterr.ErrValue[int64](err, ErrnoKey); n != int64(syscall.ENOENT) {
+		t.Errorf("expected errno %d, got %d", syscall.ENOENT, n)
+	}
+}
+
+func TestClassifyErrno_Mappings(t *testing.T) {
+	tests := []struct {
+		errno    syscall.Errno
+		sentinel error
+	}{
+		{syscall.EACCES, ErrAccessDenied},
+		{syscall.ECONNRESET, ErrConnReset},
+		{syscall.EMFILE, ErrTooManyFiles},
+		{syscall.EDOM, ErrSyscall},
+	}
+	for _, tt := range tests {
+		err := ClassifyErrno(tt.errno)
+		if !errors.Is(err, tt.sentinel) {
+			t.Errorf("%v: expected %v, got %v", tt.errno, tt.sentinel, err)
+		}
+	}
+	plain := errors.New("plain")
+	//goland:noinspection GoDirectComparisonOfErrors
+	if ClassifyErrno(plain) != plain {
+		t.Error("expected non-errno error unchanged")
+	}
+}
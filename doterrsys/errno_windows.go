@@ -0,0 +1,26 @@
+//go:build windows
+
+package doterrsys
+
+import (
+	"syscall"
+)
+
+// Win32 error codes. Declared locally because syscall does not export all of
+// them; golang.org/x/sys/windows uses the same syscall.Errno values, so errors
+// from that package are recognized too.
+const (
+	errorFileNotFound     syscall.Errno = 2
+	errorPathNotFound     syscall.Errno = 3
+	errorAccessDenied     syscall.Errno = 5
+	errorSharingViolation syscall.Errno = 32
+	errorLockViolation    syscall.Errno = 33
+)
+
+var errnos = map[syscall.Errno]errnoInfo{
+	errorFileNotFound:     {"ERROR_FILE_NOT_FOUND", ErrNotFound},
+	errorPathNotFound:     {"ERROR_PATH_NOT_FOUND", ErrNotFound},
+	errorAccessDenied:     {"ERROR_ACCESS_DENIED", ErrAccessDenied},
+	errorSharingViolation: {"ERROR_SHARING_VIOLATION", ErrSharingViolation},
+	errorLockViolation:    {"ERROR_LOCK_VIOLATION", ErrSharingViolation},
+}
@@ -0,0 +1,41 @@
+//go:build windows
+
+package doterrsys
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/mikeschinkel/go-doterr"
+)
+
+func TestClassifyErrno_AccessDenied(t *testing.T) {
+	cause := &os.PathError{Op: "open", Path: `C:\secret`, Err: syscall.Errno(5)}
+	err := ClassifyErrno(cause)
+	if !errors.Is(err, ErrAccessDenied) {
+		t.Fatalf("expected ErrAccessDenied, got %v", err)
+	}
+	if !errors.Is(err, cause) {
+		t.Error("expected original error as cause")
+	}
+	if n, _ := doterr.ErrValue[int64](err, ErrnoKey); n != 5 {
+		t.Errorf("expected errno 5, got %d", n)
+	}
+	if msg, _ := doterr.ErrValue[string](err, ErrnoMessageKey); msg == "" {
+		t.Error("expected FormatMessage text")
+	}
+}
+
+func TestClassifyErrno_Unknown(t *testing.T) {
+	err := ClassifyErrno(syscall.Errno(1234))
+	if !errors.Is(err, ErrSyscall) {
+		t.Errorf("expected ErrSyscall, got %v", err)
+	}
+	plain := errors.New("plain")
+	//goland:noinspection GoDirectComparisonOfErrors
+	if ClassifyErrno(plain) != plain {
+		t.Error("expected non-errno error unchanged")
+	}
+}
@@ -0,0 +1,53 @@
+// Package doterrtea runs the doterrtui error-tree browser as a Bubble Tea
+// program. It is a separate module so the doterr module itself stays free of
+// the bubbletea dependency.
+//
+//	m := doterrtea.New(err)
+//	m.Browser.Copy = func(text string) { clipboard.Write(text) }
+//	_, err = tea.NewProgram(m).Run()
+//
+// Key bindings are those of doterrtui.Model.Key; the browser's height
+// follows the terminal's.
+package doterrtea
+
+import (
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mikeschinkel/go-doterr/doterrtui"
+)
+
+// Model is a tea.Model wrapping a doterrtui.Model. Create one with New.
+type Model struct {
+	// Browser holds the tree state; set its Copy to receive copied text.
+	Browser *doterrtui.Model
+}
+
+var _ tea.Model = Model{}
+
+// New returns a Model browsing err.
+func New(err error) Model {
+	return Model{Browser: doterrtui.New(err)}
+}
+
+// Init implements tea.Model. It starts no command.
+func (m Model) Init() tea.Cmd {
+	return nil
+}
+
+// Update implements tea.Model: key presses go to the browser, quitting when
+// it asks to, and window resizes set its height.
+func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		if m.Browser.Key(msg.String()) {
+			return m, tea.Quit
+		}
+	case tea.WindowSizeMsg:
+		m.Browser.Height = msg.Height
+	}
+	return m, nil
+}
+
+// View implements tea.Model.
+func (m Model) View() string {
+	return m.Browser.View()
+}
@@ -0,0 +1,50 @@
+package doterrtea
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mikeschinkel/go-doterr"
+)
+
+var ErrTeaTest = errors.New("tea test")
+
+func update(t *testing.T, m tea.Model, msg tea.Msg) (tea.Model, tea.Cmd) {
+	t.Helper()
+	next, cmd := m.Update(msg)
+	if _, ok := next.(Model); !ok {
+		t.Fatalf("unexpected model %T", next)
+	}
+	return next, cmd
+}
+
+func TestModel(t *testing.T) {
+	var m tea.Model = New(doterr.NewErr(ErrTeaTest, "k", 1, "j", 2))
+	if m.Init() != nil {
+		t.Error("expected no initial command")
+	}
+	if !strings.HasPrefix(m.View(), "> ▾ tea test\n") {
+		t.Fatalf("unexpected view:\n%s", m.View())
+	}
+
+	m, _ = update(t, m, tea.KeyMsg{Type: tea.KeyDown})
+	m, _ = update(t, m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("c")})
+	if got := m.(Model).Browser.Copied(); got != "1" {
+		t.Errorf("expected the field copied, got %q", got)
+	}
+
+	m, _ = update(t, m, tea.WindowSizeMsg{Width: 80, Height: 2})
+	if lines := strings.Split(m.View(), "\n"); len(lines) != 2 {
+		t.Errorf("expected the view sized to the window:\n%s", m.View())
+	}
+
+	_, cmd := update(t, m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("q")})
+	if cmd == nil {
+		t.Fatal("expected a quit command")
+	}
+	if _, ok := cmd().(tea.QuitMsg); !ok {
+		t.Error("expected q to quit")
+	}
+}
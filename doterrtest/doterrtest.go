@@ -0,0 +1,101 @@
+// Package doterrtest holds test assertions for doterr errors.
+package doterrtest
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/mikeschinkel/go-doterr"
+)
+
+// classifiers are doterr's own classification sentinels, checked by
+// AssertOnly whether or not they are registered.
+var classifiers = []error{
+	doterr.ErrRetryable,
+	doterr.ErrRateLimited,
+	doterr.ErrUnavailable,
+	doterr.ErrPanic,
+	doterr.ErrInvalidField,
+}
+
+// AssertOnly fails t unless err matches (with errors.Is) every sentinel in
+// want and no other registered sentinel (see doterr.RegisterSentinel) or
+// doterr classification sentinel such as doterr.ErrRetryable. It catches
+// accidental extra classifications that plain errors.Is checks never
+// notice. AssertOnly(t, err) with no sentinels asserts err matches none.
+func AssertOnly(t testing.TB, err error, want ...error) {
+	t.Helper()
+	if err == nil {
+		if len(want) > 0 {
+			t.Errorf("expected an error matching %s, got nil", names(want))
+		}
+		return
+	}
+	var missing []error
+	for _, w := range want {
+		if !errors.Is(err, w) {
+			missing = append(missing, w)
+		}
+	}
+	var extra []error
+	for _, s := range candidates() {
+		if !slices.Contains(want, s) && errors.Is(err, s) {
+			extra = append(extra, s)
+		}
+	}
+	if len(missing) > 0 {
+		t.Errorf("error does not match %s: %v", names(missing), err)
+	}
+	if len(extra) > 0 {
+		t.Errorf("error unexpectedly matches %s: %v", names(extra), err)
+	}
+}
+
+// CheckHandlers fails t naming every member of K's typed sentinel set (see
+// doterr.NewOf) that has no handler in handlers, so a test fails as soon as
+// a member is added without one:
+//
+//	doterrtest.CheckHandlers(t, authHandlers)
+func CheckHandlers[K comparable](t testing.TB, handlers map[K]func(error) error) {
+	t.Helper()
+	var missing []string
+	for _, m := range doterr.Members[K]() {
+		if handlers[m.Kind()] == nil {
+			missing = append(missing, fmt.Sprintf("%v", m.Kind()))
+		}
+	}
+	if len(missing) > 0 {
+		t.Errorf("unhandled sentinel kinds: %s", strings.Join(missing, ", "))
+	}
+}
+
+// candidates returns the registered sentinels followed by any classifiers
+// not registered.
+func candidates() []error {
+	infos := doterr.RegisteredSentinels()
+	out := make([]error, 0, len(infos)+len(classifiers))
+	for _, info := range infos {
+		out = append(out, info.Sentinel)
+	}
+	for _, c := range classifiers {
+		if !slices.Contains(out, c) {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// names renders sentinels for failure messages, with codes when registered.
+func names(errs []error) string {
+	parts := make([]string, len(errs))
+	for i, err := range errs {
+		parts[i] = fmt.Sprintf("%q", err.Error())
+		if info, ok := doterr.LookupSentinel(err); ok && info.Code != "" {
+			parts[i] += " (" + info.Code + ")"
+		}
+	}
+	return strings.Join(parts, ", ")
+}
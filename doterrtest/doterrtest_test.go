@@ -0,0 +1,86 @@
+package doterrtest
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/mikeschinkel/go-doterr"
+)
+
+var (
+	ErrOrderNotFound = errors.New("order not found")
+	ErrOrderLocked   = errors.New("order locked")
+)
+
+func init() {
+	doterr.MustRegisterSentinel(ErrOrderNotFound, "ORDER_NOT_FOUND", "order_not_found")
+	doterr.MustRegisterSentinel(ErrOrderLocked, "ORDER_LOCKED", "order_locked")
+}
+
+// recorder captures failures instead of failing the test.
+type recorder struct {
+	testing.TB
+	failures []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...any) {
+	r.failures = append(r.failures, fmt.Sprintf(format, args...))
+}
+
+func TestAssertOnly(t *testing.T) {
+	err := doterr.NewErr(ErrOrderNotFound, "id", 7)
+	AssertOnly(t, err, ErrOrderNotFound)
+
+	r := &recorder{TB: t}
+	AssertOnly(r, doterr.NewErr(ErrOrderNotFound, doterr.ErrRetryable, "id", 7), ErrOrderNotFound)
+	if len(r.failures) != 1 || !strings.Contains(r.failures[0], `unexpectedly matches "retryable"`) {
+		t.Errorf("expected a stray ErrRetryable to fail, got %q", r.failures)
+	}
+
+	r = &recorder{TB: t}
+	AssertOnly(r, fmt.Errorf("wrap: %w", doterr.NewErr(ErrOrderLocked)), ErrOrderNotFound)
+	if len(r.failures) != 2 || !strings.Contains(r.failures[1], "(ORDER_LOCKED)") {
+		t.Errorf("expected missing and extra failures, got %q", r.failures)
+	}
+
+	r = &recorder{TB: t}
+	AssertOnly(r, nil)
+	AssertOnly(r, errors.New("unclassified"))
+	if len(r.failures) != 0 {
+		t.Errorf("unexpected failures %q", r.failures)
+	}
+}
+
+type orderKind int
+
+const (
+	orderPending orderKind = iota
+	orderShipped
+)
+
+var (
+	ErrOrderPending = doterr.NewOf(orderPending, "order pending")
+	ErrOrderShipped = doterr.NewOf(orderShipped, "order shipped")
+)
+
+func TestCheckHandlers(t *testing.T) {
+	handlers := map[orderKind]func(error) error{
+		orderPending: func(error) error { return nil },
+	}
+	r := &recorder{TB: t}
+	CheckHandlers(r, handlers)
+	if len(r.failures) != 1 || r.failures[0] != "unhandled sentinel kinds: 1" {
+		t.Errorf("unexpected failures %q", r.failures)
+	}
+
+	handlers[orderShipped] = func(error) error { return nil }
+	r = &recorder{TB: t}
+	CheckHandlers(r, handlers)
+	if len(r.failures) != 0 {
+		t.Errorf("expected no failures for complete handlers, got %q", r.failures)
+	}
+}
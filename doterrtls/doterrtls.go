@@ -0,0 +1,105 @@
+// Package doterrtls classifies TLS certificate verification failures, whose
+// stock messages are hard to act on, wrapping them with dedicated sentinels
+// and the certificate details needed to fix them (subject, expiry, DNS names).
+package doterrtls
+
+import (
+	"crypto/x509"
+	"errors"
+	"time"
+
+	"github.com/mikeschinkel/go-doterr"
+)
+
+// ErrCertVerification is attached to every classified failure, alongside one
+// of the more specific sentinels below.
+var ErrCertVerification = errors.New("certificate verification failed")
+
+var (
+	ErrCertExpired      = errors.New("certificate expired or not yet valid")
+	ErrHostnameMismatch = errors.New("certificate hostname mismatch")
+	ErrUnknownAuthority = errors.New("certificate signed by unknown authority")
+	ErrCertInvalid      = errors.New("certificate invalid")
+)
+
+// Metadata keys attached by ClassifyTLS.
+const (
+	SubjectKey   = "cert_subject"
+	IssuerKey    = "cert_issuer"
+	NotBeforeKey = "cert_not_before" // time.Time
+	NotAfterKey  = "cert_not_after"  // time.Time
+	DNSNamesKey  = "cert_dns_names"  // []string
+	HostKey      = "host"
+	ReasonKey    = "reason"
+)
+
+// ClassifyTLS looks for an x509 verification error anywhere in the chain of
+// err (including inside *tls.CertificateVerificationError) and, if found,
+// returns a doterr entry with ErrCertVerification, a specific sentinel, and
+// certificate metadata, with err as the trailing cause. Otherwise err is
+// returned unchanged.
+func ClassifyTLS(err error) error {
+	var hostErr x509.HostnameError
+	var invalidErr x509.CertificateInvalidError
+	var authorityErr x509.UnknownAuthorityError
+
+	switch {
+	case errors.As(err, &hostErr):
+		return classified(err, ErrHostnameMismatch, hostErr.Certificate,
+			HostKey, hostErr.Host,
+		)
+	case errors.As(err, &invalidErr):
+		sentinel := ErrCertInvalid
+		if invalidErr.Reason == x509.Expired {
+			sentinel = ErrCertExpired
+		}
+		return classified(err, sentinel, invalidErr.Cert,
+			ReasonKey, invalidReason(invalidErr.Reason),
+		)
+	case errors.As(err, &authorityErr):
+		return classified(err, ErrUnknownAuthority, authorityErr.Cert)
+	}
+	return err
+}
+
+func classified(err, sentinel error, cert *x509.Certificate, kvs ...any) error {
+	parts := []any{ErrCertVerification, sentinel}
+	parts = append(parts, kvs...)
+	if cert != nil {
+		parts = append(parts,
+			SubjectKey, cert.Subject.String(),
+			IssuerKey, cert.Issuer.String(),
+			NotBeforeKey, cert.NotBefore.UTC().Truncate(time.Second),
+			NotAfterKey, cert.NotAfter.UTC().Truncate(time.Second),
+			DNSNamesKey, cert.DNSNames,
+		)
+	}
+	parts = append(parts, err)
+	return doterr.NewErr(parts...)
+}
+
+func invalidReason(r x509.InvalidReason) string {
+	switch r {
+	case x509.NotAuthorizedToSign:
+		return "not_authorized_to_sign"
+	case x509.Expired:
+		return "expired"
+	case x509.CANotAuthorizedForThisName:
+		return "ca_not_authorized_for_name"
+	case x509.TooManyIntermediates:
+		return "too_many_intermediates"
+	case x509.IncompatibleUsage:
+		return "incompatible_usage"
+	case x509.NameMismatch:
+		return "name_mismatch"
+	case x509.NameConstraintsWithoutSANs:
+		return "name_constraints_without_sans"
+	case x509.UnconstrainedName:
+		return "unconstrained_name"
+	case x509.TooManyConstraints:
+		return "too_many_constraints"
+	case x509.CANotAuthorizedForExtKeyUsage:
+		return "ca_not_authorized_for_ext_key_usage"
+	}
+	return "unknown"
+}
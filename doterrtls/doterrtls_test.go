@@ -0,0 +1,86 @@
+package doterrtls
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/mikeschinkel/go-doterr"
+)
+
+func selfSigned(t *testing.T, notAfter time.Time, dnsNames ...string) *x509.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "example"},
+		NotBefore:    notAfter.Add(-24 * time.Hour),
+		NotAfter:     notAfter,
+		DNSNames:     dnsNames,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func TestClassifyTLS_HostnameMismatch(t *testing.T) {
+	cert := selfSigned(t, time.Now().Add(time.Hour), "a.example.com")
+	cause := &tls.CertificateVerificationError{
+		UnverifiedCertificates: []*x509.Certificate{cert},
+		Err:                    cert.VerifyHostname("b.example.com"),
+	}
+	err := ClassifyTLS(cause)
+	if !errors.Is(err, ErrHostnameMismatch) || !errors.Is(err, ErrCertVerification) {
+		t.Fatalf("expected hostname mismatch, got %v", err)
+	}
+	if host, _ := doterr.ErrValue[string](err, HostKey); host != "b.example.com" {
+		t.Errorf("expected host, got %q", host)
+	}
+	names, _ := doterr.ErrValue[[]string](err, DNSNamesKey)
+	if len(names) != 1 || names[0] != "a.example.com" {
+		t.Errorf("expected dns names, got %v", names)
+	}
+}
+
+func TestClassifyTLS_Expired(t *testing.T) {
+	cert := selfSigned(t, time.Now().Add(-time.Hour))
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	_, cause := cert.Verify(x509.VerifyOptions{Roots: pool})
+	err := ClassifyTLS(cause)
+	if !errors.Is(err, ErrCertExpired) {
+		t.Fatalf("expected ErrCertExpired, got %v", err)
+	}
+	if na, _ := doterr.ErrValue[time.Time](err, NotAfterKey); !na.Equal(cert.NotAfter) {
+		t.Errorf("expected not_after %v, got %v", cert.NotAfter, na)
+	}
+}
+
+func TestClassifyTLS_UnknownAuthorityAndPassthrough(t *testing.T) {
+	cert := selfSigned(t, time.Now().Add(time.Hour))
+	_, cause := cert.Verify(x509.VerifyOptions{Roots: x509.NewCertPool()})
+	if err := ClassifyTLS(cause); !errors.Is(err, ErrUnknownAuthority) {
+		t.Errorf("expected ErrUnknownAuthority, got %v", err)
+	}
+	plain := errors.New("plain")
+	//goland:noinspection GoDirectComparisonOfErrors
+	if ClassifyTLS(plain) != plain {
+		t.Error("expected unrelated error unchanged")
+	}
+}
@@ -0,0 +1,357 @@
+// Package doterrtui is an interactive error-tree browser for terminal UIs:
+// nodes of the canonical doterrwire tree can be expanded and collapsed, and
+// a node's metadata, or a single value, copied out.
+//
+// Like doterr itself it has no dependencies. Model follows the Bubble Tea
+// shape (key strings in, a View string out); the doterrtea module wraps it
+// as a tea.Model.
+//
+// Metadata is taken from doterrwire.Encode, so redaction and the
+// "doterrwire" doterrfields.Policy apply to what is shown and copied.
+package doterrtui
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/mikeschinkel/go-doterr/doterrwire"
+)
+
+// Keys understood by Model.Key, named as Bubble Tea's KeyMsg.String()
+// renders them.
+const (
+	KeyUp          = "up"    // also "k"
+	KeyDown        = "down"  // also "j"
+	KeyCollapse    = "left"  // also "h"; on a field, moves to its node
+	KeyExpand      = "right" // also "l"
+	KeyToggle      = "enter" // also " "
+	KeyExpandAll   = "e"
+	KeyCollapseAll = "E"
+	KeyCopy        = "c" // the node's metadata, or the field's value, decompressed
+	KeyQuit        = "q" // also "esc" and "ctrl+c"
+)
+
+// Help is the key summary View shows on its last line.
+const Help = "↑/↓ move  ←/→ collapse/expand  e/E all  c copy  q quit"
+
+// Model is the browser's state. Create one with New.
+type Model struct {
+	// Height limits View to this many lines, scrolling to keep the cursor
+	// visible. Zero shows everything.
+	Height int
+
+	// Copy, if set, receives copied text, e.g. to write OSC52(text) to the
+	// terminal. The text is also available from Copied.
+	Copy func(text string)
+
+	root     *doterrwire.Node
+	expanded map[*doterrwire.Node]bool
+	rows     []row
+	cursor   int
+	offset   int // first row shown when Height is set
+	copied   string
+	status   string
+}
+
+// row is one visible line: a node, or one of its metadata fields.
+type row struct {
+	node  *doterrwire.Node
+	field int // index into node.Meta, or -1 for the node itself
+	depth int
+}
+
+// New returns a browser for err with the root and its children expanded.
+// A nil err shows an empty tree.
+func New(err error) *Model {
+	m := &Model{root: doterrwire.Encode(err), expanded: map[*doterrwire.Node]bool{}}
+	if m.root != nil {
+		m.expanded[m.root] = true
+		for _, child := range m.root.Children {
+			m.expanded[child] = true
+		}
+	}
+	m.layout()
+	return m
+}
+
+// Key handles one key press and reports whether the browser should quit.
+// Unknown keys are ignored.
+func (m *Model) Key(key string) (quit bool) {
+	m.status = ""
+	switch key {
+	case KeyUp, "k":
+		m.move(-1)
+	case KeyDown, "j":
+		m.move(1)
+	case "home", "g":
+		m.move(-len(m.rows))
+	case "end", "G":
+		m.move(len(m.rows))
+	case KeyCollapse, "h":
+		m.collapse()
+	case KeyExpand, "l":
+		m.setExpanded(true)
+	case KeyToggle, " ":
+		if r, ok := m.current(); ok && r.field < 0 {
+			m.setExpanded(!m.expanded[r.node])
+		}
+	case KeyExpandAll:
+		m.setAll(true)
+	case KeyCollapseAll:
+		m.setAll(false)
+	case KeyCopy:
+		m.copy()
+	case KeyQuit, "esc", "ctrl+c":
+		return true
+	}
+	return false
+}
+
+// Selected returns the node under the cursor (the owner, on a field line),
+// or nil for an empty tree.
+func (m *Model) Selected() *doterrwire.Node {
+	r, ok := m.current()
+	if !ok {
+		return nil
+	}
+	return r.node
+}
+
+// Copied returns the text of the last copy, or "" if nothing was copied.
+func (m *Model) Copied() string {
+	return m.copied
+}
+
+// View renders the visible lines, the cursor marked with ">", followed by a
+// status line.
+func (m *Model) View() string {
+	var sb strings.Builder
+	first, last := 0, len(m.rows)
+	if m.Height > 1 {
+		first = m.offset
+		last = min(len(m.rows), first+m.Height-1)
+	}
+	for i := first; i < last; i++ {
+		if i == m.cursor {
+			sb.WriteString("> ")
+		} else {
+			sb.WriteString("  ")
+		}
+		sb.WriteString(m.line(m.rows[i]))
+		sb.WriteByte('\n')
+	}
+	if m.root == nil {
+		sb.WriteString("  (no error)\n")
+	}
+	if m.status != "" {
+		sb.WriteString(m.status)
+	} else {
+		sb.WriteString(Help)
+	}
+	return sb.String()
+}
+
+// OSC52 returns the terminal escape sequence that asks the terminal to put
+// text on the system clipboard. Most modern terminals honor it, including
+// over SSH.
+func OSC52(text string) string {
+	return "\x1b]52;c;" + base64.StdEncoding.EncodeToString([]byte(text)) + "\a"
+}
+
+func (m *Model) line(r row) string {
+	indent := strings.Repeat("  ", r.depth)
+	n := r.node
+	if r.field >= 0 {
+		f := n.Meta[r.field]
+		return indent + "    " + f.Key + " = " + fieldText(f)
+	}
+	marker := "  "
+	if expandable(n) {
+		marker = "▸ "
+		if m.expanded[n] {
+			marker = "▾ "
+		}
+	}
+	return indent + marker + label(n)
+}
+
+// label is a node's one-line description.
+func label(n *doterrwire.Node) string {
+	switch n.Kind {
+	case doterrwire.KindEntry:
+		parts := make([]string, len(n.Sentinels))
+		for i, s := range n.Sentinels {
+			parts[i] = s.Message
+			if s.Code != "" {
+				parts[i] = "[" + s.Code + "] " + s.Message
+			}
+		}
+		if len(parts) == 0 {
+			return "entry"
+		}
+		return strings.Join(parts, ", ")
+	case doterrwire.KindJoin:
+		return fmt.Sprintf("%d errors", len(n.Children))
+	}
+	return n.Message
+}
+
+func fieldText(f doterrwire.Field) string {
+	if f.Compression != "" {
+		return "<" + f.Compression + ">"
+	}
+	return fmt.Sprintf("%v", f.Value)
+}
+
+func expandable(n *doterrwire.Node) bool {
+	return len(n.Meta) > 0 || len(n.Children) > 0
+}
+
+// layout rebuilds the visible rows from the expansion state, keeping the
+// cursor on the same node or field where possible.
+func (m *Model) layout() {
+	prev, hadPrev := m.current()
+	m.rows = m.rows[:0]
+	var walk func(n *doterrwire.Node, depth int)
+	walk = func(n *doterrwire.Node, depth int) {
+		m.rows = append(m.rows, row{node: n, field: -1, depth: depth})
+		if !m.expanded[n] {
+			return
+		}
+		for i := range n.Meta {
+			m.rows = append(m.rows, row{node: n, field: i, depth: depth})
+		}
+		for _, child := range n.Children {
+			walk(child, depth+1)
+		}
+	}
+	if m.root != nil {
+		walk(m.root, 0)
+	}
+	m.cursor = 0
+	if hadPrev {
+		for i, r := range m.rows {
+			if r.node != prev.node {
+				continue
+			}
+			if r.field < 0 {
+				m.cursor = i // the node's line, unless the field is still shown
+			}
+			if r.field == prev.field {
+				m.cursor = i
+				break
+			}
+		}
+	}
+	m.scroll()
+}
+
+func (m *Model) current() (row, bool) {
+	if m.cursor < 0 || m.cursor >= len(m.rows) {
+		return row{}, false
+	}
+	return m.rows[m.cursor], true
+}
+
+func (m *Model) move(delta int) {
+	m.cursor = max(0, min(len(m.rows)-1, m.cursor+delta))
+	m.scroll()
+}
+
+// scroll keeps the cursor inside the Height window.
+func (m *Model) scroll() {
+	visible := m.Height - 1 // the status line
+	if visible < 1 {
+		m.offset = 0
+		return
+	}
+	if m.cursor < m.offset {
+		m.offset = m.cursor
+	}
+	if m.cursor >= m.offset+visible {
+		m.offset = m.cursor - visible + 1
+	}
+}
+
+func (m *Model) setExpanded(on bool) {
+	r, ok := m.current()
+	if !ok || r.field >= 0 || !expandable(r.node) {
+		return
+	}
+	m.expanded[r.node] = on
+	m.layout()
+}
+
+// collapse closes the node under the cursor, or moves from a field (or an
+// already collapsed node) to the owning node's line.
+func (m *Model) collapse() {
+	r, ok := m.current()
+	if !ok {
+		return
+	}
+	if r.field < 0 && m.expanded[r.node] {
+		m.setExpanded(false)
+		return
+	}
+	for i := m.cursor - 1; i >= 0; i-- {
+		p := m.rows[i]
+		if p.field < 0 && (p.node == r.node && r.field >= 0 || p.depth < r.depth) {
+			m.cursor = i
+			m.scroll()
+			return
+		}
+	}
+}
+
+func (m *Model) setAll(on bool) {
+	var walk func(n *doterrwire.Node)
+	walk = func(n *doterrwire.Node) {
+		if expandable(n) {
+			m.expanded[n] = on
+		}
+		for _, child := range n.Children {
+			walk(child)
+		}
+	}
+	if m.root != nil {
+		walk(m.root)
+		if !on {
+			m.expanded[m.root] = true // keep the top level in view
+		}
+	}
+	m.layout()
+}
+
+func (m *Model) copy() {
+	r, ok := m.current()
+	if !ok {
+		return
+	}
+	fields := r.node.Meta
+	if r.field >= 0 {
+		fields = fields[r.field : r.field+1]
+	}
+	lines := make([]string, len(fields))
+	for i, f := range fields {
+		f, err := doterrwire.DecompressField(f)
+		if err != nil {
+			m.status = "cannot copy " + f.Key + ": " + err.Error()
+			return
+		}
+		lines[i] = fieldText(f)
+		if r.field < 0 {
+			lines[i] = f.Key + "=" + lines[i]
+		}
+	}
+	text := strings.Join(lines, "\n")
+	if text == "" {
+		m.status = "nothing to copy"
+		return
+	}
+	m.copied = text
+	if m.Copy != nil {
+		m.Copy(text)
+	}
+	m.status = fmt.Sprintf("copied %d bytes", len(text))
+}
@@ -0,0 +1,132 @@
+package doterrtui
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/mikeschinkel/go-doterr"
+	"github.com/mikeschinkel/go-doterr/doterrwire"
+)
+
+var (
+	ErrSync  = doterr.MustRegisterSentinel(errors.New("sync failed"), "TUI_SYNC", "")
+	ErrFetch = errors.New("fetch failed")
+)
+
+func sampleErr() error {
+	return doterr.NewErr(ErrSync, "batch", 7, doterr.CombineErrs([]error{
+		doterr.NewErr(ErrFetch, "url", "https://a.example", "status", 502),
+		errors.New("disk full"),
+	}))
+}
+
+func keys(m *Model, ks ...string) {
+	for _, k := range ks {
+		m.Key(k)
+	}
+}
+
+func TestModel_View(t *testing.T) {
+	m := New(sampleErr())
+	want := strings.Join([]string{
+		"> ▾ 2 errors",
+		"    ▾ [TUI_SYNC] sync failed",
+		"        batch = 7",
+		"    ▾ 2 errors",
+		"      ▸ fetch failed",
+		"        disk full",
+		Help,
+	}, "\n")
+	if got := m.View(); got != want {
+		t.Errorf("View:\n%s\nwant:\n%s", got, want)
+	}
+
+	keys(m, KeyDown, KeyCollapse)
+	if got := m.View(); !strings.Contains(got, ">   ▸ [TUI_SYNC] sync failed\n    ▾ 2 errors\n") {
+		t.Errorf("expected the entry to collapse:\n%s", got)
+	}
+	keys(m, KeyExpand, KeyDown, KeyCollapse)
+	if got := m.Selected(); got == nil || got.Sentinels[0].Message != "sync failed" {
+		t.Errorf("expected left on a field to select its node, got %+v", got)
+	}
+	if !strings.Contains(m.View(), "batch = 7") {
+		t.Errorf("expected left on a field to leave the node expanded:\n%s", m.View())
+	}
+	keys(m, KeyToggle)
+	if strings.Contains(m.View(), "batch = 7") {
+		t.Errorf("expected enter to toggle the entry:\n%s", m.View())
+	}
+}
+
+func TestModel_ExpandAllAndCopy(t *testing.T) {
+	var clipboard string
+	m := New(sampleErr())
+	m.Copy = func(text string) { clipboard = text }
+
+	keys(m, KeyExpandAll, "end", "up", "up")
+	if r, _ := m.current(); r.field < 0 || r.node.Meta[r.field].Key != "url" {
+		t.Fatalf("expected the cursor on the url field:\n%s", m.View())
+	}
+	m.Key(KeyCopy)
+	if clipboard != "https://a.example" || m.Copied() != clipboard {
+		t.Errorf("expected the field value copied, got %q", clipboard)
+	}
+	if !strings.HasSuffix(m.View(), "copied 17 bytes") {
+		t.Errorf("expected a copy status:\n%s", m.View())
+	}
+
+	m.Key(KeyCollapse)
+	m.Key(KeyCopy)
+	if clipboard != "url=https://a.example\nstatus=502" {
+		t.Errorf("expected the node's metadata copied, got %q", clipboard)
+	}
+
+	keys(m, "end", KeyCopy)
+	if !strings.HasSuffix(m.View(), "nothing to copy") || clipboard != "url=https://a.example\nstatus=502" {
+		t.Errorf("expected nothing copied from a leaf:\n%s", m.View())
+	}
+	if m.Key(KeyQuit) != true || m.Key("x") != false {
+		t.Error("expected only quit keys to quit")
+	}
+}
+
+func TestModel_CopyCompressed(t *testing.T) {
+	doterrwire.SetCompressThreshold(64)
+	t.Cleanup(func() { doterrwire.SetCompressThreshold(0) })
+	body := strings.Repeat("request body ", 20)
+	m := New(doterr.NewErr(ErrFetch, "body", body))
+
+	keys(m, KeyDown)
+	if !strings.Contains(m.View(), "body = <gzip>") {
+		t.Fatalf("expected a compressed field:\n%s", m.View())
+	}
+	m.Key(KeyCopy)
+	if m.Copied() != body {
+		t.Errorf("expected the decompressed value copied, got %q", m.Copied())
+	}
+}
+
+func TestModel_Height(t *testing.T) {
+	m := New(sampleErr())
+	m.Height = 3
+	keys(m, KeyExpandAll, "end")
+	lines := strings.Split(m.View(), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[1], "> ") {
+		t.Errorf("expected a two-line window ending at the cursor:\n%s", m.View())
+	}
+}
+
+func TestModel_Nil(t *testing.T) {
+	m := New(nil)
+	keys(m, KeyDown, KeyExpand, KeyCopy)
+	if m.Selected() != nil || !strings.Contains(m.View(), "(no error)") {
+		t.Errorf("unexpected view of nil:\n%s", m.View())
+	}
+}
+
+func TestOSC52(t *testing.T) {
+	if got := OSC52("hi"); got != "\x1b]52;c;aGk=\a" {
+		t.Errorf("OSC52 = %q", got)
+	}
+}
@@ -0,0 +1,123 @@
+package doterrwire
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/mikeschinkel/go-doterr"
+)
+
+// Codec serializes errors for the transport helpers, so an organization can
+// carry errors in its preferred encoding (msgpack, CBOR, ...) without forking
+// this package. Decode failures should be returned wrapped with ErrDecode.
+// Implementations must be safe for concurrent use.
+type Codec interface {
+	Encode(err error) ([]byte, error)
+	Decode(data []byte) (error, error)
+}
+
+// Built-in codec names.
+const (
+	JSONCodec  = "json"  // ErrToJSON and ErrFromJSON
+	ProtoCodec = "proto" // ErrToProto and ErrFromProto
+)
+
+var (
+	// ErrUnknownCodec is returned when a codec name is not registered; the
+	// name is attached as "codec".
+	ErrUnknownCodec = errors.New("unknown codec")
+
+	// ErrInvalidCodec is returned by RegisterCodec for an unusable name or a
+	// nil codec, and for a name that is already registered.
+	ErrInvalidCodec = errors.New("invalid codec registration")
+)
+
+var codecs = struct {
+	sync.RWMutex
+	byName map[string]Codec
+}{byName: map[string]Codec{
+	JSONCodec:  jsonCodec{},
+	ProtoCodec: protoCodec{},
+}}
+
+// RegisterCodec makes c available under name to SetHeaderCodec, DecodeHeader,
+// EncodeWith and DecodeWith:
+//
+//	func init() { doterrwire.RegisterCodec("msgpack", msgpackCodec{}) }
+//
+// Names are lower-case letters, digits, '-' and '_', so they can prefix a
+// header token. Returns an ErrInvalidCodec error (with "codec" metadata) if
+// the name is malformed or taken, including by a built-in, or c is nil.
+func RegisterCodec(name string, c Codec) error {
+	if c == nil || !validCodecName(name) {
+		return doterr.NewErr(ErrInvalidCodec, "codec", name)
+	}
+	codecs.Lock()
+	defer codecs.Unlock()
+	if _, ok := codecs.byName[name]; ok {
+		return doterr.NewErr(ErrInvalidCodec, "codec", name, "reason", "already registered")
+	}
+	codecs.byName[name] = c
+	return nil
+}
+
+// LookupCodec returns the codec registered under name.
+func LookupCodec(name string) (Codec, bool) {
+	codecs.RLock()
+	defer codecs.RUnlock()
+	c, ok := codecs.byName[name]
+	return c, ok
+}
+
+// Codecs returns the names of every registered codec, sorted.
+func Codecs() []string {
+	codecs.RLock()
+	defer codecs.RUnlock()
+	names := make([]string, 0, len(codecs.byName))
+	for name := range codecs.byName {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// EncodeWith encodes err with the named codec. Returns an ErrUnknownCodec
+// error if the codec is not registered.
+func EncodeWith(codec string, err error) ([]byte, error) {
+	c, ok := LookupCodec(codec)
+	if !ok {
+		return nil, doterr.NewErr(ErrUnknownCodec, "codec", codec)
+	}
+	return c.Encode(err)
+}
+
+// DecodeWith reverses EncodeWith. Returns an ErrUnknownCodec error if the
+// codec is not registered.
+func DecodeWith(codec string, data []byte) (decoded error, err error) {
+	c, ok := LookupCodec(codec)
+	if !ok {
+		return nil, doterr.NewErr(ErrUnknownCodec, "codec", codec)
+	}
+	return c.Decode(data)
+}
+
+func validCodecName(name string) bool {
+	if name == "" {
+		return false
+	}
+	return !strings.ContainsFunc(name, func(r rune) bool {
+		return (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '-' && r != '_'
+	})
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Encode(err error) ([]byte, error)  { return ErrToJSON(err) }
+func (jsonCodec) Decode(data []byte) (error, error) { return ErrFromJSON(data) }
+
+type protoCodec struct{}
+
+func (protoCodec) Encode(err error) ([]byte, error)  { return ErrToProto(err), nil }
+func (protoCodec) Decode(data []byte) (error, error) { return ErrFromProto(data) }
@@ -0,0 +1,109 @@
+package doterrwire
+
+import (
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/mikeschinkel/go-doterr"
+)
+
+// hexCodec stands in for an organization's own encoding.
+type hexCodec struct{}
+
+func (hexCodec) Encode(err error) ([]byte, error) {
+	data, e := ErrToJSON(err)
+	return []byte(hex.EncodeToString(data)), e
+}
+
+func (hexCodec) Decode(data []byte) (error, error) {
+	b, err := hex.DecodeString(string(data))
+	if err != nil {
+		return nil, doterr.NewErr(ErrDecode, "format", "hex", err)
+	}
+	return ErrFromJSON(b)
+}
+
+func init() {
+	err := RegisterCodec("test-hex", hexCodec{})
+	if err != nil {
+		panic(err)
+	}
+}
+
+func TestRegisterCodec(t *testing.T) {
+	for _, name := range []string{"", "Msgpack", "a.b", JSONCodec, "test-hex"} {
+		if err := RegisterCodec(name, hexCodec{}); !errors.Is(err, ErrInvalidCodec) {
+			t.Errorf("%q: expected ErrInvalidCodec, got %v", name, err)
+		}
+	}
+	if err := RegisterCodec("nil-codec", nil); !errors.Is(err, ErrInvalidCodec) {
+		t.Errorf("expected ErrInvalidCodec for nil codec, got %v", err)
+	}
+	if got := strings.Join(Codecs(), ","); got != "json,proto,test-hex" {
+		t.Errorf("Codecs() = %s", got)
+	}
+}
+
+func TestEncodeWith_BuiltinsAndRegistered(t *testing.T) {
+	for _, name := range Codecs() {
+		data, err := EncodeWith(name, sampleErr())
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		got, err := DecodeWith(name, data)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if Encode(got).String() != Encode(sampleErr()).String() {
+			t.Errorf("%s: tree\n got %s\nwant %s", name, Encode(got), Encode(sampleErr()))
+		}
+	}
+	if _, err := EncodeWith("msgpack", sampleErr()); !errors.Is(err, ErrUnknownCodec) {
+		t.Errorf("expected ErrUnknownCodec, got %v", err)
+	}
+}
+
+func TestHeaderCodec_RoundTrip(t *testing.T) {
+	if _, err := SetHeaderCodec("msgpack"); !errors.Is(err, ErrUnknownCodec) {
+		t.Fatalf("expected ErrUnknownCodec, got %v", err)
+	}
+	prev, err := SetHeaderCodec("test-hex")
+	if err != nil || prev != "" {
+		t.Fatalf("SetHeaderCodec = %q, %v", prev, err)
+	}
+	t.Cleanup(func() { _, _ = SetHeaderCodec(prev) })
+
+	original := sampleErr()
+	v := EncodeHeader(original)
+	if !strings.HasPrefix(v, "test-hex.") {
+		t.Fatalf("expected codec prefix, got %q", v)
+	}
+	if got := EstimateSize(original, Header); got != len(v) {
+		t.Errorf("Header size: got %d, want %d", got, len(v))
+	}
+
+	// The receiver decodes by the token's prefix, not its own setting.
+	_, _ = SetHeaderCodec("")
+	got, err := DecodeHeader(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !errors.Is(got, ErrRemote) || !errors.Is(got, ErrWireTest) {
+		t.Errorf("expected ErrRemote and the original sentinel, got %v", got)
+	}
+	if doterr.ErrSeverity(got) != doterr.SeverityWarn || got.Error() != original.Error() {
+		t.Errorf("expected the full tree to survive, got %v", got)
+	}
+}
+
+func TestDecodeHeader_UnknownCodec(t *testing.T) {
+	_, err := DecodeHeader("msgpack.AAAA")
+	if !errors.Is(err, ErrDecode) || !errors.Is(err, ErrUnknownCodec) {
+		t.Fatalf("expected ErrDecode and ErrUnknownCodec, got %v", err)
+	}
+	if codec, _ := doterr.ErrValue[string](err, "codec"); codec != "msgpack" {
+		t.Errorf("expected codec metadata, got %q", codec)
+	}
+}
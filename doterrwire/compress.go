@@ -0,0 +1,116 @@
+package doterrwire
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"sync/atomic"
+
+	"github.com/mikeschinkel/go-doterr"
+)
+
+// CompressionGzip flags a Field whose Value holds gzip-compressed bytes. It is
+// the only compression offered; zstd would need a non-stdlib dependency.
+const CompressionGzip = "gzip"
+
+var compressThreshold atomic.Int64
+
+// SetCompressThreshold enables transparent gzip compression of string
+// metadata values at least n bytes long during encoding, for cases like
+// attaching a failing request body where truncation would destroy the
+// evidence. Compressed fields are flagged with CompressionGzip in the
+// envelope and decompressed by ErrFromJSON and ErrFromProto, within the
+// MaxDecompressed decode limit (see SetDecodeLimits). A value is only
+// compressed when that makes it smaller. n <= 0 disables compression, which
+// is the default.
+func SetCompressThreshold(n int) {
+	compressThreshold.Store(int64(n))
+}
+
+// compressField gzips f's value when compression is enabled and worthwhile.
+func compressField(f Field) Field {
+	threshold := compressThreshold.Load()
+	s, ok := f.Value.(string)
+	if !ok || threshold <= 0 || int64(len(s)) < threshold {
+		return f
+	}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	_, _ = zw.Write([]byte(s)) // cannot fail writing to a bytes.Buffer
+	_ = zw.Close()
+	if buf.Len() >= len(s) {
+		return f
+	}
+	f.Value = buf.Bytes()
+	f.Compression = CompressionGzip
+	return f
+}
+
+// decompress restores every compressed value in the tree to its string form.
+// The decompressed sizes are summed against budget, so a hostile payload of
+// many small, highly compressible fields cannot exhaust memory; budget <= 0
+// means no limit.
+func (n *Node) decompress(budget int) (err error) {
+	limited := budget > 0
+	n.walk(func(node *Node) {
+		for i, f := range node.Meta {
+			if err != nil || f.Compression == "" {
+				continue
+			}
+			node.Meta[i], err = decompressField(f, budget, limited)
+			if s, ok := node.Meta[i].Value.(string); ok && err == nil {
+				budget -= len(s)
+			}
+		}
+	})
+	return err
+}
+
+// DecompressField returns f with a compressed value restored to its string
+// form, or f unchanged if it is not compressed. It returns an ErrDecode error
+// for an unsupported or corrupt value, or one larger than the MaxDecompressed
+// decode limit.
+func DecompressField(f Field) (Field, error) {
+	budget := currentLimits().MaxDecompressed
+	return decompressField(f, budget, budget > 0)
+}
+
+// decompressField decompresses f, failing if it grows past budget when
+// limited.
+func decompressField(f Field, budget int, limited bool) (Field, error) {
+	if f.Compression == "" {
+		return f, nil
+	}
+	if f.Compression != CompressionGzip {
+		return f, decodeErr(f, "unsupported compression")
+	}
+	var data []byte
+	switch v := f.Value.(type) {
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return f, decodeErr(f, "compressed value is not bytes")
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return f, decodeErr(f, err.Error())
+	}
+	var r io.Reader = zr
+	if limited {
+		r = io.LimitReader(zr, int64(budget)+1)
+	}
+	plain, err := io.ReadAll(r)
+	if err != nil {
+		return f, decodeErr(f, err.Error())
+	}
+	if limited && len(plain) > budget {
+		return f, decodeErr(f, "decompressed values too large")
+	}
+	return Field{Key: f.Key, Value: string(plain)}, nil
+}
+
+func decodeErr(f Field, reason string) error {
+	return doterr.NewErr(ErrDecode, "key", f.Key, "compression", f.Compression, "reason", reason)
+}
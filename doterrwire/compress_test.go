@@ -0,0 +1,107 @@
+package doterrwire
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/mikeschinkel/go-doterr"
+)
+
+func TestCompression_RoundTrip(t *testing.T) {
+	SetCompressThreshold(1024)
+	defer SetCompressThreshold(0)
+
+	body := strings.Repeat(`{"item":"widget","qty":1},`, 500)
+	original := doterr.NewErr(ErrLocal, "body", body, "short", "kept plain")
+
+	node := Encode(original)
+	if node.Meta[0].Compression != CompressionGzip {
+		t.Fatalf("expected body to be compressed, got %+v", node.Meta[0])
+	}
+	if node.Meta[1].Compression != "" {
+		t.Errorf("short value should not be compressed")
+	}
+
+	data, err := ErrToJSON(original)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) >= len(body) {
+		t.Errorf("expected JSON smaller than body: %d >= %d", len(data), len(body))
+	}
+	if got, want := EstimateSize(original, JSON), len(data); got != want {
+		t.Errorf("JSON size: got %d, want %d", got, want)
+	}
+	if got, want := EstimateSize(original, Proto), len(ErrToProto(original)); got != want {
+		t.Errorf("Proto size: got %d, want %d", got, want)
+	}
+
+	fromJSON, err := ErrFromJSON(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromProto, err := ErrFromProto(ErrToProto(original))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, got := range map[string]error{"json": fromJSON, "proto": fromProto} {
+		if v, _ := doterr.ErrValue[string](got, "body"); v != body {
+			t.Errorf("%s: body not restored (%d bytes)", name, len(v))
+		}
+	}
+}
+
+func TestCompression_DisabledByDefault(t *testing.T) {
+	body := strings.Repeat("x", 10000)
+	node := Encode(doterr.NewErr(ErrLocal, "body", body))
+	if node.Meta[0].Compression != "" {
+		t.Errorf("expected no compression by default")
+	}
+}
+
+func TestCompression_CorruptValue(t *testing.T) {
+	n := &Node{Kind: KindEntry,
+		Sentinels: []Sentinel{{Message: "x"}},
+		Meta:      []Field{{Key: "body", Value: []byte("not gzip"), Compression: CompressionGzip}},
+	}
+	data := n.appendProto(nil)
+	_, err := ErrFromProto(data)
+	if !errors.Is(err, ErrDecode) {
+		t.Fatalf("expected ErrDecode, got %v", err)
+	}
+	if key, _ := doterr.ErrValue[string](err, "key"); key != "body" {
+		t.Errorf("expected key=body on %v", err)
+	}
+}
+
+func TestCompression_TotalBudget(t *testing.T) {
+	SetCompressThreshold(1024)
+	defer SetCompressThreshold(0)
+	prev := SetDecodeLimits(Limits{MaxDecompressed: 1 << 20})
+	defer SetDecodeLimits(prev)
+
+	// Each value fits the budget on its own; together they exceed it.
+	parts := []any{ErrLocal}
+	for i := range 8 {
+		parts = append(parts, "zeros_"+string(rune('a'+i)), strings.Repeat("\x00", 200<<10))
+	}
+	original := doterr.NewErr(parts...)
+	data, err := ErrToJSON(original)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, decode := range map[string]func() error{
+		"json":  func() error { _, err := ErrFromJSON(data); return err },
+		"proto": func() error { _, err := ErrFromProto(ErrToProto(original)); return err },
+	} {
+		if err := decode(); !errors.Is(err, ErrDecode) {
+			t.Errorf("%s: expected ErrDecode over the total budget, got %v", name, err)
+		}
+	}
+
+	SetDecodeLimits(Limits{MaxDecompressed: 2 << 20})
+	if _, err := ErrFromJSON(data); err != nil {
+		t.Errorf("expected the tree to fit a larger budget: %v", err)
+	}
+}
@@ -0,0 +1,94 @@
+package doterrwire
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// ErrDOT renders err's wrap/join structure as a Graphviz digraph for
+// postmortems of aggregated failures, e.g. `dot -Tsvg`. Entries are labelled
+// with their sentinels and metadata, limited to metaKeys when any are given;
+// leaves and wrapping errors with their message and Go type. Edges point from
+// an error to what it wraps. Returns an empty digraph for a nil error.
+func ErrDOT(err error, metaKeys ...string) string {
+	var sb strings.Builder
+	sb.WriteString("digraph doterr {\n")
+	sb.WriteString("\tnode [shape=box, fontname=\"monospace\"];\n")
+	if n := Encode(err); n != nil {
+		next := 0
+		writeDOT(&sb, n, metaKeys, &next)
+	}
+	sb.WriteString("}\n")
+	return sb.String()
+}
+
+// writeDOT emits n and its subtree, returning n's node ID.
+func writeDOT(sb *strings.Builder, n *Node, metaKeys []string, next *int) string {
+	id := fmt.Sprintf("n%d", *next)
+	*next++
+
+	var lines []string
+	style := ""
+	switch n.Kind {
+	case KindJoin:
+		lines = append(lines, "join")
+		style = ", shape=ellipse"
+	case KindEntry:
+		for _, s := range n.Sentinels {
+			line := s.Message
+			if s.Code != "" {
+				line += " (" + s.Code + ")"
+			}
+			lines = append(lines, line)
+		}
+		for _, f := range n.Meta {
+			if len(metaKeys) > 0 && !slices.Contains(metaKeys, f.Key) {
+				continue
+			}
+			v := fmt.Sprintf("%v", f.Value)
+			if f.Compression != "" {
+				v = "<" + f.Compression + ">"
+			}
+			lines = append(lines, f.Key+"="+v)
+		}
+		if len(lines) == 0 {
+			lines = append(lines, "entry")
+		}
+		style = ", style=rounded"
+	default:
+		lines = append(lines, n.Message, n.Type)
+	}
+	fmt.Fprintf(sb, "\t%s [label=%s%s];\n", id, dotLabel(lines), style)
+
+	for _, child := range n.Children {
+		childID := writeDOT(sb, child, metaKeys, next)
+		fmt.Fprintf(sb, "\t%s -> %s;\n", id, childID)
+	}
+	return id
+}
+
+// dotLabel quotes lines as a DOT string with left-justified line breaks.
+func dotLabel(lines []string) string {
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for _, line := range lines {
+		if line == "" {
+			continue
+		}
+		for _, r := range line {
+			switch r {
+			case '"', '\\':
+				sb.WriteByte('\\')
+				sb.WriteRune(r)
+			case '\n':
+				sb.WriteString(`\l`)
+			default:
+				sb.WriteRune(r)
+			}
+		}
+		sb.WriteString(`\l`)
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
@@ -0,0 +1,35 @@
+package doterrwire
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/mikeschinkel/go-doterr"
+)
+
+func TestErrDOT(t *testing.T) {
+	err := errors.Join(
+		doterr.NewErr(ErrWireTest, "user", "alice", "attempt", 3),
+		errors.New(`bad "quote"`),
+	)
+	got := ErrDOT(err, "user")
+	want := `digraph doterr {
+	node [shape=box, fontname="monospace"];
+	n0 [label="join\l", shape=ellipse];
+	n1 [label="wire test (WIRE_TEST)\luser=alice\l", style=rounded];
+	n0 -> n1;
+	n2 [label="bad \"quote\"\l*errors.errorString\l"];
+	n0 -> n2;
+}
+`
+	if got != want {
+		t.Errorf("ErrDOT:\n%s\nwant:\n%s", got, want)
+	}
+	if all := ErrDOT(err); !strings.Contains(all, `attempt=3\l`) {
+		t.Errorf("expected all metadata without a key filter:\n%s", all)
+	}
+	if empty := ErrDOT(nil); strings.Contains(empty, "n0") {
+		t.Errorf("expected no nodes for nil:\n%s", empty)
+	}
+}
@@ -0,0 +1,187 @@
+package doterrwire
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	"github.com/mikeschinkel/go-doterr"
+	"github.com/mikeschinkel/go-doterr/doterrfields"
+)
+
+// ErrRemote marks errors reconstructed from a peer's encoded summary.
+var ErrRemote = errors.New("remote error")
+
+// summary is the compact wire form of an error: its message, the messages of
+// its sentinels, and its metadata rendered as strings in insertion order.
+type summary struct {
+	Message   string      `json:"m"`
+	Sentinels []string    `json:"s,omitempty"`
+	Meta      [][2]string `json:"k,omitempty"`
+}
+
+var headerCodec atomic.Pointer[string]
+
+// SetHeaderCodec makes EncodeHeader carry the full error tree encoded with
+// the named codec (see RegisterCodec) instead of the compact summary, and
+// returns the previous name. The token is the codec name, a '.', and the
+// unpadded base64url encoding, so DecodeHeader picks the right codec on the
+// receiving side. "" restores the summary. An unregistered name returns an
+// ErrUnknownCodec error and leaves the setting unchanged.
+func SetHeaderCodec(name string) (string, error) {
+	if _, ok := LookupCodec(name); !ok && name != "" {
+		return currentHeaderCodec(), doterr.NewErr(ErrUnknownCodec, "codec", name)
+	}
+	prev := headerCodec.Swap(&name)
+	if prev == nil {
+		return "", nil
+	}
+	return *prev, nil
+}
+
+func currentHeaderCodec() string {
+	name := headerCodec.Load()
+	if name == nil {
+		return ""
+	}
+	return *name
+}
+
+// encodeWithHeaderCodec encodes err with the codec set by SetHeaderCodec and
+// returns the token's codec prefix and payload. It returns false if no codec
+// is set or the codec fails, in which case the summary is used instead.
+func encodeWithHeaderCodec(err error) (name string, data []byte, ok bool) {
+	name = currentHeaderCodec()
+	if name == "" {
+		return "", nil, false
+	}
+	data, e := EncodeWith(name, err)
+	if e != nil {
+		return "", nil, false
+	}
+	return name, data, true
+}
+
+// EncodeHeader encodes a compact summary of err as a single header-safe
+// token (unpadded base64url JSON). Metadata values are rendered with %v,
+// after doterr.EmitValue applies the RedactionMode.
+// When SetHeaderCodec is in effect the token carries the full tree instead,
+// falling back to the summary if the codec fails. Returns "" for a nil error.
+func EncodeHeader(err error) string {
+	if err == nil {
+		return ""
+	}
+	if name, data, ok := encodeWithHeaderCodec(err); ok {
+		return name + "." + base64.RawURLEncoding.EncodeToString(data)
+	}
+	s := summary{Message: err.Error()}
+	for _, sentinel := range doterr.ErrSentinels(err) {
+		s.Sentinels = append(s.Sentinels, sentinel.Error())
+	}
+	for _, pair := range doterr.ErrMeta(err) {
+		if !doterrfields.Emits("doterrwire", pair.Key()) {
+			continue
+		}
+		s.Meta = append(s.Meta, [2]string{pair.Key(), fmt.Sprintf("%v", doterr.EmitValue("doterrwire", pair.Key(), pair.Value()))})
+	}
+	b, _ := json.Marshal(s) // cannot fail: only strings
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// DecodeHeader reverses EncodeHeader. The decoded error matches ErrRemote via
+// errors.Is, carries one reconstructed sentinel per encoded sentinel (matched
+// by message, not identity), the metadata as string values, and the original
+// message as its trailing cause. Returns (nil, nil) for "". The decode limits
+// apply (see SetDecodeLimits); ErrDecode offsets point into v for base64
+// errors and into the decoded JSON otherwise.
+//
+// A token written under SetHeaderCodec is decoded with its codec, whatever
+// the receiver's own setting, and the result is wrapped so it still matches
+// ErrRemote; an unregistered codec fails with ErrDecode and ErrUnknownCodec.
+func DecodeHeader(v string) (decoded error, err error) {
+	if v == "" {
+		return nil, nil
+	}
+	if name, token, ok := strings.Cut(v, "."); ok {
+		return decodeCodecHeader(name, token)
+	}
+	lim := currentLimits()
+	if lim.MaxBytes > 0 && base64.RawURLEncoding.DecodedLen(len(v)) > lim.MaxBytes {
+		return nil, limitErr("header", lim.MaxBytes, "input too large", lim.MaxBytes)
+	}
+	b, err := base64.RawURLEncoding.DecodeString(v)
+	if err != nil {
+		var corrupt base64.CorruptInputError
+		offset := -1
+		if errors.As(err, &corrupt) {
+			offset = int(corrupt)
+		}
+		return nil, doterr.NewErr(ErrDecode, "format", "header", "offset", offset, err)
+	}
+	err = lim.scanJSON("header", b)
+	if err != nil {
+		return nil, err
+	}
+	var s summary
+	err = json.Unmarshal(b, &s)
+	if err != nil {
+		return nil, doterr.NewErr(ErrDecode, "format", "header", "offset", -1, err)
+	}
+	keys := make([]string, len(s.Meta))
+	for i, pair := range s.Meta {
+		keys[i] = pair[0]
+	}
+	err = lim.checkMetaKeys("header", b, keys)
+	if err != nil {
+		return nil, err
+	}
+	parts := []any{ErrRemote}
+	for _, msg := range s.Sentinels {
+		parts = append(parts, errors.New(msg))
+	}
+	for _, pair := range s.Meta {
+		parts = append(parts, pair[0], pair[1])
+	}
+	parts = append(parts, errors.New(s.Message))
+	return doterr.NewErr(parts...), nil
+}
+
+// decodeCodecHeader decodes a token written by EncodeHeader under
+// SetHeaderCodec; offsets count from the start of the whole header value.
+func decodeCodecHeader(name, token string) (decoded error, err error) {
+	base := len(name) + 1
+	c, ok := LookupCodec(name)
+	if !ok {
+		return nil, doterr.NewErr(ErrDecode, ErrUnknownCodec, "format", "header", "codec", name, "offset", 0)
+	}
+	lim := currentLimits()
+	if lim.MaxBytes > 0 && base64.RawURLEncoding.DecodedLen(len(token)) > lim.MaxBytes {
+		return nil, limitErr("header", base+lim.MaxBytes, "input too large", lim.MaxBytes)
+	}
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		var corrupt base64.CorruptInputError
+		offset := -1
+		if errors.As(err, &corrupt) {
+			offset = base + int(corrupt)
+		}
+		return nil, doterr.NewErr(ErrDecode, "format", "header", "codec", name, "offset", offset, err)
+	}
+	decoded, err = c.Decode(b)
+	if err != nil {
+		return nil, err
+	}
+	return remote{decoded}, nil
+}
+
+// remote marks an error decoded by a codec as ErrRemote.
+type remote struct {
+	err error
+}
+
+func (r remote) Error() string        { return r.err.Error() }
+func (r remote) Unwrap() error        { return r.err }
+func (r remote) Is(target error) bool { return target == ErrRemote }
@@ -0,0 +1,71 @@
+package doterrwire
+
+import (
+	"errors"
+	"slices"
+	"sync/atomic"
+	"time"
+)
+
+// Hop records one service that encoded an error while it propagated.
+type Hop struct {
+	Service string    `json:"service"`
+	Time    time.Time `json:"time"`
+}
+
+var serviceName atomic.Pointer[string]
+
+// now is the hop clock, replaced in tests.
+var now = time.Now
+
+// SetServiceName sets the name ErrToJSON and ErrToProto record as a Hop each
+// time they encode an error, and returns the previous name. Hops already on
+// a decoded error are carried along, so an error that crossed four services
+// arrives with all four in order (see Hops). The default, "", records no
+// hop of its own.
+func SetServiceName(name string) string {
+	prev := serviceName.Swap(&name)
+	if prev == nil {
+		return ""
+	}
+	return *prev
+}
+
+// Hops returns the propagation path of an error decoded by ErrFromJSON or
+// ErrFromProto, oldest first, or nil if it has none. Errors wrapping a
+// decoded error report its hops too.
+func Hops(err error) []Hop {
+	var h *hopped
+	if !errors.As(err, &h) {
+		return nil
+	}
+	return slices.Clone(h.hops)
+}
+
+// hopped is a decoded error carrying the hops from its envelope.
+type hopped struct {
+	err  error
+	hops []Hop
+}
+
+func (h *hopped) Error() string { return h.err.Error() }
+func (h *hopped) Unwrap() error { return h.err }
+
+// envelope is Encode plus the root-level hops: those of the decoded error
+// inside err, if any, followed by this service's own hop.
+func envelope(err error) *Node {
+	return envelopeAt(err, now)
+}
+
+// envelopeAt is envelope with this service's hop stamped by clock.
+func envelopeAt(err error, clock func() time.Time) *Node {
+	n := Encode(err)
+	if n == nil {
+		return nil
+	}
+	n.Hops = Hops(err)
+	if name := serviceName.Load(); name != nil && *name != "" {
+		n.Hops = append(n.Hops, Hop{Service: *name, Time: clock().UTC()})
+	}
+	return n
+}
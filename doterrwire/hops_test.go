@@ -0,0 +1,105 @@
+package doterrwire
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mikeschinkel/go-doterr"
+)
+
+func TestHops_AccumulateAcrossServices(t *testing.T) {
+	clock := time.Date(2026, 1, 2, 3, 4, 5, 6, time.UTC)
+	prevNow := now
+	now = func() time.Time { clock = clock.Add(time.Second); return clock }
+	prevName := SetServiceName("")
+	t.Cleanup(func() { now = prevNow; SetServiceName(prevName) })
+
+	var err error = sampleErr()
+	services := []string{"edge", "api", "billing", "ledger"}
+	for i, svc := range services {
+		SetServiceName(svc)
+		if i%2 == 0 {
+			data, e := ErrToJSON(err)
+			if e != nil {
+				t.Fatal(e)
+			}
+			err, e = ErrFromJSON(data)
+			if e != nil {
+				t.Fatal(e)
+			}
+		} else {
+			var e error
+			err, e = ErrFromProto(ErrToProto(err))
+			if e != nil {
+				t.Fatal(e)
+			}
+		}
+		// Wrapping between hops must not lose the path.
+		err = fmt.Errorf("hop %d: %w", i, err)
+	}
+
+	hops := Hops(err)
+	if len(hops) != len(services) {
+		t.Fatalf("hops = %v", hops)
+	}
+	for i, h := range hops {
+		want := time.Date(2026, 1, 2, 3, 4, 5+i+1, 6, time.UTC)
+		if h.Service != services[i] || !h.Time.Equal(want) {
+			t.Errorf("hop %d = %+v, want %s at %s", i, h, services[i], want)
+		}
+	}
+	if !errors.Is(err, ErrWireTest) {
+		t.Errorf("sentinel lost across hops: %v", err)
+	}
+	if got := Encode(err).Hops; got != nil {
+		t.Errorf("Encode should not carry hops, got %v", got)
+	}
+	data, e := ErrToJSON(err)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if got, want := EstimateSize(err, JSON), len(data); got != want {
+		t.Errorf("JSON size: got %d, want %d", got, want)
+	}
+	if got, want := EstimateSize(err, Proto), len(ErrToProto(err)); got != want {
+		t.Errorf("Proto size: got %d, want %d", got, want)
+	}
+}
+
+func TestHops_NoServiceName(t *testing.T) {
+	prev := SetServiceName("")
+	t.Cleanup(func() { SetServiceName(prev) })
+
+	got, err := ErrFromProto(ErrToProto(doterr.NewErr(ErrLocal)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hops := Hops(got); hops != nil {
+		t.Errorf("expected no hops, got %v", hops)
+	}
+}
+
+func TestEstimateSize_ReadsNoClock(t *testing.T) {
+	prevNow := now
+	now = func() time.Time { t.Error("EstimateSize read the clock"); return prevNow() }
+	prevName := SetServiceName("api")
+	t.Cleanup(func() { now = prevNow; SetServiceName(prevName) })
+
+	err := sampleErr()
+	if EstimateSize(err, JSON) == 0 || EstimateSize(err, Proto) == 0 {
+		t.Error("expected non-zero sizes")
+	}
+	now = func() time.Time { return time.Date(2026, 1, 2, 3, 4, 5, 123456789, time.UTC) }
+	data, e := ErrToJSON(err)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if got, want := EstimateSize(err, JSON), len(data); got != want {
+		t.Errorf("JSON size: got %d, want %d", got, want)
+	}
+	if got, want := EstimateSize(err, Proto), len(ErrToProto(err)); got != want {
+		t.Errorf("Proto size: got %d, want %d", got, want)
+	}
+}
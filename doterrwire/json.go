@@ -0,0 +1,83 @@
+package doterrwire
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+
+	"github.com/mikeschinkel/go-doterr"
+)
+
+// ErrToJSON encodes err's canonical Node tree as JSON, with its hops (see
+// SetServiceName). Returns "null" for nil.
+func ErrToJSON(err error) ([]byte, error) {
+	return json.Marshal(envelope(err))
+}
+
+// ErrFromJSON decodes JSON produced by ErrToJSON back into an error. Integral
+// metadata numbers decode as int64 and others as float64. Input is checked
+// against the decode limits (see SetDecodeLimits) before it is unmarshalled.
+// A decode failure is returned as the second value, wrapped with ErrDecode.
+func ErrFromJSON(data []byte) (decoded error, err error) {
+	lim := currentLimits()
+	err = lim.scanJSON("json", data)
+	if err != nil {
+		return nil, err
+	}
+	var n *Node
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	err = dec.Decode(&n)
+	if err != nil {
+		return nil, doterr.NewErr(ErrDecode, "format", "json", "offset", int(dec.InputOffset()), err)
+	}
+	var keys []string
+	n.walk(func(node *Node) {
+		for _, f := range node.Meta {
+			keys = append(keys, f.Key)
+		}
+	})
+	err = lim.checkMetaKeys("json", data, keys)
+	if err != nil {
+		return nil, err
+	}
+	n.walk(func(node *Node) {
+		for i, f := range node.Meta {
+			if s, ok := f.Value.(string); ok && f.Compression != "" {
+				// encoding/json renders []byte as standard base64.
+				b, err := base64.StdEncoding.DecodeString(s)
+				if err == nil {
+					node.Meta[i].Value = b
+				}
+				continue
+			}
+			num, ok := f.Value.(json.Number)
+			if !ok {
+				continue
+			}
+			if i64, err := num.Int64(); err == nil {
+				node.Meta[i].Value = i64
+			} else if f64, err := num.Float64(); err == nil {
+				node.Meta[i].Value = f64
+			} else {
+				node.Meta[i].Value = num.String()
+			}
+		}
+	})
+	err = n.decompress(lim.MaxDecompressed)
+	if err != nil {
+		return nil, err
+	}
+	return n.Err(), nil
+}
+
+// walk calls fn for n and every descendant, depth-first.
+func (n *Node) walk(fn func(*Node)) {
+	if n == nil {
+		return
+	}
+	fn(n)
+	for _, child := range n.Children {
+		child.walk(fn)
+	}
+}
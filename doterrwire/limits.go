@@ -0,0 +1,165 @@
+package doterrwire
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"sync/atomic"
+	"unicode/utf8"
+
+	"github.com/mikeschinkel/go-doterr"
+)
+
+// Limits bound what the decoders accept, since encoded errors arrive from
+// remote peers and must be treated as untrusted. A zero field means no limit.
+type Limits struct {
+	MaxBytes        int // encoded input size (for headers, after base64 decoding)
+	MaxDepth        int // nesting of nodes, and of JSON objects and arrays
+	MaxElements     int // nodes, sentinels and fields (JSON objects, proto messages)
+	MaxKeyLen       int // metadata keys and JSON object keys
+	MaxDecompressed int // compressed values once decompressed, summed over the tree
+}
+
+// DefaultLimits are in effect until SetDecodeLimits is called.
+var DefaultLimits = Limits{
+	MaxBytes:        4 << 20,
+	MaxDepth:        64,
+	MaxElements:     10000,
+	MaxKeyLen:       256,
+	MaxDecompressed: 16 << 20,
+}
+
+var decodeLimits atomic.Pointer[Limits]
+
+func init() {
+	l := DefaultLimits
+	decodeLimits.Store(&l)
+}
+
+// SetDecodeLimits replaces the limits ErrFromJSON, ErrFromProto and
+// DecodeHeader enforce and returns the previous ones. Inputs that exceed a
+// limit, hold invalid UTF-8 or are malformed fail with an ErrDecode error
+// whose "offset" metadata is the byte offset of the problem in the input.
+func SetDecodeLimits(l Limits) Limits {
+	return *decodeLimits.Swap(&l)
+}
+
+func currentLimits() Limits {
+	return *decodeLimits.Load()
+}
+
+// limitErr reports input rejected at offset for reason.
+func limitErr(format string, offset int, reason string, limit int) error {
+	return doterr.NewErr(ErrDecode,
+		"format", format,
+		"offset", offset,
+		"reason", reason,
+		"limit", limit,
+	)
+}
+
+// checkSize rejects inputs larger than MaxBytes.
+func (l Limits) checkSize(format string, data []byte) error {
+	if l.MaxBytes > 0 && len(data) > l.MaxBytes {
+		return limitErr(format, l.MaxBytes, "input too large", l.MaxBytes)
+	}
+	return nil
+}
+
+// checkKey rejects an over-long key found at offset.
+func (l Limits) checkKey(format string, key string, offset int) error {
+	if l.MaxKeyLen > 0 && len(key) > l.MaxKeyLen {
+		return limitErr(format, offset, "key too long", l.MaxKeyLen)
+	}
+	return nil
+}
+
+// scanJSON walks the JSON token stream once, before unmarshalling, enforcing
+// depth, element and key-length limits with the offset of each violation.
+func (l Limits) scanJSON(format string, data []byte) error {
+	err := l.checkSize(format, data)
+	if err != nil {
+		return err
+	}
+	if off := invalidUTF8Offset(data); off >= 0 {
+		return doterr.NewErr(ErrDecode, "format", format, "offset", off, "reason", "invalid UTF-8")
+	}
+	type frame struct{ object, expectKey bool }
+	var stack []frame
+	elements := 0
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	for {
+		offset := int(dec.InputOffset())
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			var syntax *json.SyntaxError
+			if errors.As(err, &syntax) {
+				offset = int(syntax.Offset)
+			}
+			return doterr.NewErr(ErrDecode, "format", format, "offset", offset, err)
+		}
+		if d, ok := tok.(json.Delim); ok && (d == '}' || d == ']') {
+			stack = stack[:len(stack)-1]
+			continue
+		}
+		isKey := false
+		if top := len(stack) - 1; top >= 0 && stack[top].object {
+			isKey = stack[top].expectKey
+			stack[top].expectKey = !isKey
+		}
+		switch t := tok.(type) {
+		case json.Delim:
+			stack = append(stack, frame{object: t == '{', expectKey: t == '{'})
+			if l.MaxDepth > 0 && len(stack) > l.MaxDepth {
+				return limitErr(format, offset, "nesting too deep", l.MaxDepth)
+			}
+			if t == '{' {
+				elements++
+				if l.MaxElements > 0 && elements > l.MaxElements {
+					return limitErr(format, offset, "too many elements", l.MaxElements)
+				}
+			}
+		case string:
+			if isKey {
+				err = l.checkKey(format, t, offset)
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// checkMetaKeys applies MaxKeyLen to decoded metadata keys, which JSON
+// carries as string values; the offset is where the key first appears.
+func (l Limits) checkMetaKeys(format string, data []byte, keys []string) error {
+	for _, key := range keys {
+		if l.MaxKeyLen <= 0 || len(key) <= l.MaxKeyLen {
+			continue
+		}
+		quoted, _ := json.Marshal(key)
+		return l.checkKey(format, key, bytes.Index(data, quoted))
+	}
+	return nil
+}
+
+// invalidUTF8Offset returns the offset of the first invalid UTF-8 sequence
+// in b, or -1 if b is valid.
+func invalidUTF8Offset(b []byte) int {
+	if utf8.Valid(b) {
+		return -1
+	}
+	for i := 0; i < len(b); {
+		r, size := utf8.DecodeRune(b[i:])
+		if r == utf8.RuneError && size <= 1 {
+			return i
+		}
+		i += size
+	}
+	return -1
+}
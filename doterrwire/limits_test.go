@@ -0,0 +1,102 @@
+package doterrwire
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/mikeschinkel/go-doterr"
+)
+
+func decodeOffset(t *testing.T, err error) int {
+	t.Helper()
+	if !errors.Is(err, ErrDecode) {
+		t.Fatalf("expected ErrDecode, got %v", err)
+	}
+	offset, ok := doterr.ErrValue[int](err, "offset")
+	if !ok {
+		t.Fatalf("expected offset metadata on %v", err)
+	}
+	return offset
+}
+
+func TestErrFromJSON_Limits(t *testing.T) {
+	deep := strings.Repeat(`{"kind":"join","children":[`, 100)
+	if off := decodeOffset(t, second(ErrFromJSON([]byte(deep)))); off <= 0 {
+		t.Errorf("expected offset into the document, got %d", off)
+	}
+
+	bad := []byte(`{"kind":"leaf","message":"ok` + "\xff" + `"}`)
+	if off := decodeOffset(t, second(ErrFromJSON(bad))); off != 28 {
+		t.Errorf("expected offset of invalid byte, got %d", off)
+	}
+
+	longKey := strings.Repeat("k", 300)
+	doc := `{"kind":"entry","meta":[{"key":"` + longKey + `","value":1}]}`
+	if off := decodeOffset(t, second(ErrFromJSON([]byte(doc)))); off != strings.Index(doc, `"k`+"kk") {
+		t.Errorf("unexpected key offset %d", off)
+	}
+
+	decodeOffset(t, second(ErrFromJSON([]byte(`{"kind":`))))
+}
+
+func TestErrFromProto_Limits(t *testing.T) {
+	prev := SetDecodeLimits(Limits{MaxDepth: 3, MaxElements: 5, MaxKeyLen: 4})
+	defer SetDecodeLimits(prev)
+
+	deep := Encode(errors.Join(errors.Join(errors.Join(errors.New("x")))))
+	decodeOffset(t, second(ErrFromProto(deep.appendProto(nil))))
+
+	long := doterr.NewErr(ErrLocal, "toolong", 1)
+	data := ErrToProto(long)
+	if off := decodeOffset(t, second(ErrFromProto(data))); data[off-1] != byte(len("toolong")) {
+		t.Errorf("expected offset of the key, got %d", off)
+	}
+
+	n := &Node{Kind: KindLeaf, Message: "bad \xff"}
+	data = n.appendProto(nil)
+	if off := decodeOffset(t, second(ErrFromProto(data))); data[off] != 0xff {
+		t.Errorf("expected offset of the invalid byte, got %d", off)
+	}
+
+	decodeOffset(t, second(ErrFromProto([]byte{1<<3 | wireVarint, 9})))
+}
+
+func TestDecodeHeader_Limits(t *testing.T) {
+	prev := SetDecodeLimits(Limits{MaxBytes: 16})
+	defer SetDecodeLimits(prev)
+
+	token := base64.RawURLEncoding.EncodeToString([]byte(`{"m":"` + strings.Repeat("x", 64) + `"}`))
+	decodeOffset(t, second(DecodeHeader(token)))
+	if off := decodeOffset(t, second(DecodeHeader("ab!d"))); off != 2 {
+		t.Errorf("expected offset of the corrupt base64 byte, got %d", off)
+	}
+}
+
+func FuzzErrFromJSON(f *testing.F) {
+	data, _ := ErrToJSON(sampleErr())
+	f.Add(data)
+	f.Add([]byte(`{"kind":"join","children":[{"kind":"leaf","message":"x"}]}`))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		_, err := ErrFromJSON(data)
+		if err != nil && !errors.Is(err, ErrDecode) {
+			t.Fatalf("unexpected error type: %v", err)
+		}
+	})
+}
+
+func FuzzErrFromProto(f *testing.F) {
+	f.Add(ErrToProto(sampleErr()))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		_, err := ErrFromProto(data)
+		if err != nil && !errors.Is(err, ErrDecode) {
+			t.Fatalf("unexpected error type: %v", err)
+		}
+	})
+}
+
+// second returns the decode error of a (decoded, err) pair.
+func second(_ error, err error) error {
+	return err
+}
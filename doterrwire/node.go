@@ -0,0 +1,320 @@
+// Package doterrwire is the canonical serialization of doterr errors. An
+// error is first converted into a tree of Nodes that mirrors its structure
+// (entries, joins, wrapping errors and leaves), which is then encoded as JSON
+// (ErrToJSON), protobuf wire format (ErrToProto) or a compact header token
+// (EncodeHeader), or rendered as a Graphviz digraph (ErrDOT) or a timeline
+// (ErrTimeline). Decoding rebuilds an equivalent error: sentinels registered
+// with doterr.RegisterSentinel are restored by code, so errors.Is keeps
+// working across the wire; others are recreated by message.
+package doterrwire
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mikeschinkel/go-doterr"
+	"github.com/mikeschinkel/go-doterr/doterrfields"
+)
+
+// Kind identifies what a Node was encoded from.
+type Kind int
+
+const (
+	KindLeaf  Kind = iota // any error that wraps nothing
+	KindEntry             // a doterr entry (any doterr copy, via doterr.ErrNode)
+	KindJoin              // an errors.Join tree or doterr aggregate
+	KindWrap              // an error wrapping a single error, e.g. fmt.Errorf("%w")
+)
+
+var kindNames = [...]string{"leaf", "entry", "join", "wrap"}
+
+func (k Kind) String() string {
+	if k < 0 || int(k) >= len(kindNames) {
+		return "unknown"
+	}
+	return kindNames[k]
+}
+
+func (k Kind) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+func (k *Kind) UnmarshalText(b []byte) error {
+	for i, name := range kindNames {
+		if name == string(b) {
+			*k = Kind(i)
+			return nil
+		}
+	}
+	return doterr.NewErr(ErrDecode, "kind", string(b))
+}
+
+// Node is one node of the canonical error tree.
+type Node struct {
+	Kind      Kind       `json:"kind"`
+	Message   string     `json:"message,omitempty"` // leaf and wrap nodes
+	Type      string     `json:"type,omitempty"`    // Go type of leaf and wrap nodes
+	Sentinels []Sentinel `json:"sentinels,omitempty"`
+	Meta      []Field    `json:"meta,omitempty"`
+	Children  []*Node    `json:"children,omitempty"`
+	Hops      []Hop      `json:"hops,omitempty"` // root only; see SetServiceName
+}
+
+// Sentinel is an entry's sentinel, identified by its registered code when it
+// has one so the decoder can restore the original sentinel value.
+type Sentinel struct {
+	Message string `json:"message"`
+	Code    string `json:"code,omitempty"`
+}
+
+// Field is one metadata pair. Values are normalized to nil, bool, int64,
+// float64 or string; see NormalizeValue. When Compression is set, Value holds
+// the compressed bytes of a string instead; see SetCompressThreshold.
+type Field struct {
+	Key         string `json:"key"`
+	Value       any    `json:"value"`
+	Compression string `json:"compression,omitempty"`
+}
+
+// ErrDecode marks failures to decode an encoded error.
+var ErrDecode = errors.New("cannot decode error")
+
+// Encode converts err into its canonical Node tree. Returns nil for nil.
+func Encode(err error) *Node {
+	if err == nil {
+		return nil
+	}
+	if h, ok := err.(*hopped); ok {
+		// Hops belong to the envelope, not the tree; see envelope.
+		return Encode(h.err)
+	}
+	if n, ok := err.(doterr.ErrNode); ok {
+		return encodeEntry(n)
+	}
+	switch u := err.(type) {
+	case interface{ Unwrap() []error }:
+		node := &Node{Kind: KindJoin}
+		for _, child := range u.Unwrap() {
+			if child != nil {
+				node.Children = append(node.Children, Encode(child))
+			}
+		}
+		return node
+	case interface{ Unwrap() error }:
+		node := &Node{Kind: KindWrap, Message: err.Error(), Type: fmt.Sprintf("%T", err)}
+		inner := u.Unwrap()
+		if inner != nil {
+			node.Children = []*Node{Encode(inner)}
+		}
+		return node
+	}
+	return &Node{Kind: KindLeaf, Message: err.Error(), Type: fmt.Sprintf("%T", err)}
+}
+
+func encodeEntry(n doterr.ErrNode) *Node {
+	node := &Node{Kind: KindEntry}
+	for _, s := range n.DoterrSentinels() {
+		info, registered := doterr.LookupSentinel(s)
+		_, wraps := s.(interface{ Unwrap() error })
+		_, joins := s.(interface{ Unwrap() []error })
+		if !registered && (wraps || joins) {
+			// A cause passed without metadata (NewErr(ErrX, cause)) is stored
+			// among the sentinels; keep its structure as a child.
+			node.Children = append(node.Children, Encode(s))
+			continue
+		}
+		node.Sentinels = append(node.Sentinels, Sentinel{Message: s.Error(), Code: info.Code})
+	}
+	keys, values := n.DoterrMeta()
+	for i, k := range keys {
+		if !doterrfields.Emits("doterrwire", k) {
+			continue
+		}
+		node.Meta = append(node.Meta, compressField(Field{Key: k, Value: NormalizeValue(doterr.EmitValue("doterrwire", k, values[i]))}))
+	}
+	return node
+}
+
+// Err rebuilds an error from the tree, carrying n.Hops (see Hops). Returns
+// nil for a nil Node.
+func (n *Node) Err() error {
+	if n == nil {
+		return nil
+	}
+	err := n.rebuild()
+	if err == nil || len(n.Hops) == 0 {
+		return err
+	}
+	return &hopped{err: err, hops: slices.Clone(n.Hops)}
+}
+
+func (n *Node) rebuild() error {
+	switch n.Kind {
+	case KindEntry:
+		parts := make([]any, 0, len(n.Sentinels)+len(n.Children)+2*len(n.Meta))
+		for _, s := range n.Sentinels {
+			parts = append(parts, s.Err())
+		}
+		for _, child := range n.Children {
+			if err := child.Err(); err != nil {
+				parts = append(parts, err)
+			}
+		}
+		for _, f := range n.Meta {
+			parts = append(parts, f.Key, restoreValue(f.Key, f.Value))
+		}
+		// Built as sent: no local caller, time or Enricher metadata.
+		return doterr.RawErr(parts...)
+	case KindJoin:
+		children := make([]error, 0, len(n.Children))
+		for _, child := range n.Children {
+			if err := child.Err(); err != nil {
+				children = append(children, err)
+			}
+		}
+		return errors.Join(children...)
+	case KindWrap:
+		var inner error
+		if len(n.Children) > 0 {
+			inner = n.Children[0].Err()
+		}
+		return &wrapped{msg: n.Message, err: inner}
+	}
+	return errors.New(n.Message)
+}
+
+// Err returns the registered sentinel for s.Code if there is one, otherwise a
+// new error with s.Message (which matches by message, not identity).
+func (s Sentinel) Err() error {
+	if s.Code != "" {
+		info, ok := doterr.LookupCode(s.Code)
+		if ok {
+			return info.Sentinel
+		}
+	}
+	return errors.New(s.Message)
+}
+
+// wrapped is a decoded KindWrap node: it keeps the original message while
+// still unwrapping to the decoded inner error.
+type wrapped struct {
+	msg string
+	err error
+}
+
+func (w *wrapped) Error() string { return w.msg }
+func (w *wrapped) Unwrap() error { return w.err }
+
+// NormalizeValue converts a metadata value into one of the types every
+// encoding supports: nil, bool, int64, float64 or string. Times are rendered
+// as RFC 3339, durations and Stringers with String(), errors with Error(),
+// and anything else with %v.
+func NormalizeValue(v any) any {
+	switch t := v.(type) {
+	case nil, bool, string, int64, float64:
+		return t
+	case int:
+		return int64(t)
+	case int8:
+		return int64(t)
+	case int16:
+		return int64(t)
+	case int32:
+		return int64(t)
+	case uint8:
+		return int64(t)
+	case uint16:
+		return int64(t)
+	case uint32:
+		return int64(t)
+	case uint:
+		return uintValue(uint64(t))
+	case uint64:
+		return uintValue(t)
+	case uintptr:
+		return uintValue(uint64(t))
+	case float32:
+		return float64(t)
+	case time.Time:
+		return t.UTC().Format(time.RFC3339Nano)
+	case error:
+		return t.Error()
+	case fmt.Stringer:
+		return t.String()
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+func uintValue(u uint64) any {
+	if u > 1<<63-1 {
+		return float64(u)
+	}
+	return int64(u)
+}
+
+// restoreValue gives doterr's canonical keys their Go types back after
+// decoding, so accessors like doterr.ErrSeverity work on decoded errors.
+func restoreValue(key string, v any) any {
+	if n, ok := v.(int64); ok && key == doterr.JobAttemptKey {
+		return int(n)
+	}
+	s, ok := v.(string)
+	if !ok {
+		return v
+	}
+	switch key {
+	case doterr.SeverityKey:
+		for sev := doterr.SeverityUnset; sev <= doterr.SeverityCritical; sev++ {
+			if sev.String() == s {
+				return sev
+			}
+		}
+	case doterr.OpKey:
+		return doterr.Op(s)
+	case doterr.RetryAfterKey:
+		d, err := time.ParseDuration(s)
+		if err == nil {
+			return d
+		}
+	case doterr.TimeKey:
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err == nil {
+			return t
+		}
+	}
+	return v
+}
+
+// String renders the tree for debugging, one node per line.
+func (n *Node) String() string {
+	var sb strings.Builder
+	n.render(&sb, 0)
+	return sb.String()
+}
+
+func (n *Node) render(sb *strings.Builder, depth int) {
+	if n == nil {
+		return
+	}
+	fmt.Fprintf(sb, "%s%s", strings.Repeat("  ", depth), n.Kind)
+	if n.Message != "" {
+		fmt.Fprintf(sb, " %q", n.Message)
+	}
+	for _, s := range n.Sentinels {
+		fmt.Fprintf(sb, " [%s]", s.Message)
+	}
+	for _, f := range n.Meta {
+		if f.Compression != "" {
+			fmt.Fprintf(sb, " %s=<%s>", f.Key, f.Compression)
+			continue
+		}
+		fmt.Fprintf(sb, " %s=%v", f.Key, f.Value)
+	}
+	sb.WriteByte('\n')
+	for _, child := range n.Children {
+		child.render(sb, depth+1)
+	}
+}
@@ -0,0 +1,360 @@
+package doterrwire
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/mikeschinkel/go-doterr"
+)
+
+// The protobuf encoding is hand-written so the package stays stdlib-only. It
+// is wire-compatible with this schema:
+//
+//	message Node {
+//	  int32 kind = 1;
+//	  string message = 2;
+//	  string type = 3;
+//	  repeated Sentinel sentinels = 4;
+//	  repeated Field meta = 5;
+//	  repeated Node children = 6;
+//	  repeated Hop hops = 7; // root only
+//	}
+//	message Hop {
+//	  string service = 1;
+//	  int64 unix_nano = 2;
+//	}
+//	message Sentinel {
+//	  string message = 1;
+//	  string code = 2;
+//	}
+//	message Field {
+//	  string key = 1;
+//	  oneof value {        // absent means null
+//	    string string_value = 2;
+//	    sint64 int_value = 3;
+//	    double float_value = 4;
+//	    bool bool_value = 5;
+//	  }
+//	  string compression = 6; // string_value holds compressed bytes
+//	}
+
+// Protobuf wire types.
+const (
+	wireVarint = 0
+	wire64     = 1
+	wireBytes  = 2
+	wire32     = 5
+)
+
+// ErrToProto encodes err's canonical Node tree in protobuf wire format, with
+// its hops (see SetServiceName). Returns nil for a nil error.
+func ErrToProto(err error) []byte {
+	n := envelope(err)
+	if n == nil {
+		return nil
+	}
+	return n.appendProto(nil)
+}
+
+// ErrFromProto decodes bytes produced by ErrToProto back into an error,
+// enforcing the decode limits (see SetDecodeLimits). A decode failure is
+// returned as the second value, wrapped with ErrDecode.
+func ErrFromProto(data []byte) (decoded error, err error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+	d := protoDecoder{lim: currentLimits()}
+	err = d.lim.checkSize("proto", data)
+	if err != nil {
+		return nil, err
+	}
+	var n Node
+	err = d.node(&n, data, 0, 1)
+	if err != nil {
+		return nil, err
+	}
+	err = n.decompress(d.lim.MaxDecompressed)
+	if err != nil {
+		return nil, err
+	}
+	return n.Err(), nil
+}
+
+func (n *Node) appendProto(b []byte) []byte {
+	if n.Kind != KindLeaf {
+		b = appendVarintField(b, 1, uint64(n.Kind))
+	}
+	b = appendStringField(b, 2, n.Message)
+	b = appendStringField(b, 3, n.Type)
+	for _, s := range n.Sentinels {
+		var sb []byte
+		sb = appendStringField(sb, 1, s.Message)
+		sb = appendStringField(sb, 2, s.Code)
+		b = appendBytesField(b, 4, sb)
+	}
+	for _, f := range n.Meta {
+		b = appendBytesField(b, 5, f.appendProto(nil))
+	}
+	for _, child := range n.Children {
+		b = appendBytesField(b, 6, child.appendProto(nil))
+	}
+	for _, h := range n.Hops {
+		var hb []byte
+		hb = appendStringField(hb, 1, h.Service)
+		hb = appendVarintField(hb, 2, uint64(h.Time.UnixNano()))
+		b = appendBytesField(b, 7, hb)
+	}
+	return b
+}
+
+func (f Field) appendProto(b []byte) []byte {
+	b = appendStringField(b, 1, f.Key)
+	if p, ok := f.Value.([]byte); ok && f.Compression != "" {
+		b = appendBytesField(b, 2, p)
+		return appendStringField(b, 6, f.Compression)
+	}
+	switch v := NormalizeValue(f.Value).(type) {
+	case string:
+		b = appendTag(b, 2, wireBytes)
+		b = binary.AppendUvarint(b, uint64(len(v)))
+		b = append(b, v...)
+	case int64:
+		b = appendVarintField(b, 3, uint64(v<<1)^uint64(v>>63)) // zigzag
+	case float64:
+		b = appendTag(b, 4, wire64)
+		b = binary.LittleEndian.AppendUint64(b, math.Float64bits(v))
+	case bool:
+		var u uint64
+		if v {
+			u = 1
+		}
+		b = appendVarintField(b, 5, u)
+	}
+	return b
+}
+
+// protoDecoder carries the limits and element count through one decode.
+type protoDecoder struct {
+	lim      Limits
+	elements int
+}
+
+// element counts one decoded message starting at offset against MaxElements.
+func (d *protoDecoder) element(offset int) error {
+	d.elements++
+	if d.lim.MaxElements > 0 && d.elements > d.lim.MaxElements {
+		return limitErr("proto", offset, "too many elements", d.lim.MaxElements)
+	}
+	return nil
+}
+
+// str converts a string field at offset, rejecting invalid UTF-8.
+func (d *protoDecoder) str(raw []byte, offset int) (string, error) {
+	if off := invalidUTF8Offset(raw); off >= 0 {
+		return "", protoErr(offset+off, "invalid UTF-8")
+	}
+	return string(raw), nil
+}
+
+// node decodes a Node message found at base, nested depth levels deep.
+func (d *protoDecoder) node(n *Node, data []byte, base, depth int) error {
+	if d.lim.MaxDepth > 0 && depth > d.lim.MaxDepth {
+		return limitErr("proto", base, "nesting too deep", d.lim.MaxDepth)
+	}
+	err := d.element(base)
+	if err != nil {
+		return err
+	}
+	return eachField(data, base, func(num int, v uint64, raw []byte, offset int) error {
+		var err error
+		switch num {
+		case 1:
+			if v >= uint64(len(kindNames)) {
+				return protoErr(offset, fmt.Sprintf("unknown kind %d", v))
+			}
+			n.Kind = Kind(v)
+		case 2:
+			n.Message, err = d.str(raw, offset)
+		case 3:
+			n.Type, err = d.str(raw, offset)
+		case 4:
+			var s Sentinel
+			err = d.sentinel(&s, raw, offset)
+			n.Sentinels = append(n.Sentinels, s)
+		case 5:
+			var f Field
+			err = d.field(&f, raw, offset)
+			n.Meta = append(n.Meta, f)
+		case 6:
+			child := &Node{}
+			err = d.node(child, raw, offset, depth+1)
+			n.Children = append(n.Children, child)
+		case 7:
+			var h Hop
+			err = d.hop(&h, raw, offset)
+			n.Hops = append(n.Hops, h)
+		}
+		return err
+	})
+}
+
+func (d *protoDecoder) hop(h *Hop, data []byte, base int) error {
+	err := d.element(base)
+	if err != nil {
+		return err
+	}
+	return eachField(data, base, func(num int, v uint64, raw []byte, offset int) error {
+		var err error
+		switch num {
+		case 1:
+			h.Service, err = d.str(raw, offset)
+		case 2:
+			h.Time = time.Unix(0, int64(v)).UTC()
+		}
+		return err
+	})
+}
+
+func (d *protoDecoder) sentinel(s *Sentinel, data []byte, base int) error {
+	err := d.element(base)
+	if err != nil {
+		return err
+	}
+	return eachField(data, base, func(num int, _ uint64, raw []byte, offset int) error {
+		var err error
+		switch num {
+		case 1:
+			s.Message, err = d.str(raw, offset)
+		case 2:
+			s.Code, err = d.str(raw, offset)
+		}
+		return err
+	})
+}
+
+func (d *protoDecoder) field(f *Field, data []byte, base int) error {
+	err := d.element(base)
+	if err != nil {
+		return err
+	}
+	var value []byte // string_value, kept raw until Compression is known
+	valueOffset := 0
+	err = eachField(data, base, func(num int, v uint64, raw []byte, offset int) error {
+		var err error
+		switch num {
+		case 1:
+			f.Key, err = d.str(raw, offset)
+			if err == nil {
+				err = d.lim.checkKey("proto", f.Key, offset)
+			}
+		case 2:
+			value, valueOffset = raw, offset
+			f.Value = nil
+		case 3:
+			f.Value = int64(v>>1) ^ -int64(v&1) // zigzag
+		case 4:
+			f.Value = math.Float64frombits(v)
+		case 5:
+			f.Value = v != 0
+		case 6:
+			f.Compression, err = d.str(raw, offset)
+		}
+		return err
+	})
+	if err != nil || value == nil {
+		return err
+	}
+	if f.Compression != "" {
+		// Compressed bytes are binary; decompress validates them.
+		f.Value = value
+		return nil
+	}
+	f.Value, err = d.str(value, valueOffset)
+	return err
+}
+
+// eachField iterates the fields of one protobuf message found at base in the
+// whole input. For varint and fixed-width fields v holds the value; for
+// length-delimited fields raw holds the payload. offset is the absolute
+// offset of the field's value. Unknown fields are passed to fn and may be
+// ignored.
+func eachField(data []byte, base int, fn func(num int, v uint64, raw []byte, offset int) error) error {
+	for off := 0; off < len(data); {
+		start := off
+		tag, n := binary.Uvarint(data[off:])
+		if n <= 0 {
+			return protoErr(base+start, "bad tag")
+		}
+		off += n
+		num, typ := int(tag>>3), int(tag&7)
+		valueOff := off
+		var v uint64
+		var raw []byte
+		switch typ {
+		case wireVarint:
+			v, n = binary.Uvarint(data[off:])
+			if n <= 0 {
+				return protoErr(base+off, "bad varint")
+			}
+			off += n
+		case wire64:
+			if len(data)-off < 8 {
+				return protoErr(base+off, "truncated fixed64")
+			}
+			v = binary.LittleEndian.Uint64(data[off:])
+			off += 8
+		case wire32:
+			if len(data)-off < 4 {
+				return protoErr(base+off, "truncated fixed32")
+			}
+			v = uint64(binary.LittleEndian.Uint32(data[off:]))
+			off += 4
+		case wireBytes:
+			l, n := binary.Uvarint(data[off:])
+			if n <= 0 || l > uint64(len(data)-off-n) {
+				return protoErr(base+off, "bad length")
+			}
+			off += n
+			valueOff = off
+			raw = data[off : off+int(l)]
+			off += int(l)
+		default:
+			return protoErr(base+start, fmt.Sprintf("unsupported wire type %d", typ))
+		}
+		err := fn(num, v, raw, base+valueOff)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func protoErr(offset int, reason string) error {
+	return doterr.NewErr(ErrDecode, "format", "proto", "offset", offset, "reason", reason)
+}
+
+func appendTag(b []byte, num, typ int) []byte {
+	return binary.AppendUvarint(b, uint64(num)<<3|uint64(typ))
+}
+
+func appendVarintField(b []byte, num int, v uint64) []byte {
+	b = appendTag(b, num, wireVarint)
+	return binary.AppendUvarint(b, v)
+}
+
+func appendStringField(b []byte, num int, s string) []byte {
+	if s == "" {
+		return b
+	}
+	b = appendTag(b, num, wireBytes)
+	b = binary.AppendUvarint(b, uint64(len(s)))
+	return append(b, s...)
+}
+
+func appendBytesField(b []byte, num int, p []byte) []byte {
+	b = appendTag(b, num, wireBytes)
+	b = binary.AppendUvarint(b, uint64(len(p)))
+	return append(b, p...)
+}
@@ -0,0 +1,60 @@
+package doterrwire
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"iter"
+
+	"github.com/mikeschinkel/go-doterr"
+)
+
+// RecordErr appends err to w as one line of canonical JSON (see ErrToJSON),
+// so a production error stream can be captured and later replayed against
+// handler or matcher logic with ReplayErrs. A nil err writes nothing.
+func RecordErr(w io.Writer, err error) error {
+	if err == nil {
+		return nil
+	}
+	data, encErr := ErrToJSON(err)
+	if encErr != nil {
+		return encErr
+	}
+	_, encErr = w.Write(append(data, '\n'))
+	return encErr
+}
+
+// ReplayErrs returns the errors recorded in r by RecordErr, decoded one line
+// at a time with ErrFromJSON under the decode limits (see SetDecodeLimits).
+// Blank lines are skipped. A malformed record or a read failure ends the
+// sequence with an ErrDecode error whose "record" metadata is the 1-based
+// line number.
+func ReplayErrs(r io.Reader) iter.Seq[error] {
+	return func(yield func(error) bool) {
+		sc := bufio.NewScanner(r)
+		maxLine := bufio.MaxScanTokenSize
+		if lim := currentLimits(); lim.MaxBytes > 0 {
+			maxLine = lim.MaxBytes + 1 // +1 so an oversized record reaches ErrFromJSON
+		}
+		sc.Buffer(nil, maxLine)
+		line := 0
+		for sc.Scan() {
+			line++
+			data := bytes.TrimSpace(sc.Bytes())
+			if len(data) == 0 {
+				continue
+			}
+			decoded, err := ErrFromJSON(data)
+			if err != nil {
+				yield(doterr.WithErr(err, "record", line))
+				return
+			}
+			if !yield(decoded) {
+				return
+			}
+		}
+		if err := sc.Err(); err != nil {
+			yield(doterr.NewErr(ErrDecode, "format", "json", "record", line+1, err))
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package doterrwire
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/mikeschinkel/go-doterr"
+)
+
+func TestRecordErr_ReplayErrs(t *testing.T) {
+	var buf bytes.Buffer
+	for _, err := range []error{
+		doterr.NewErr(ErrWireTest, "user", "alice"),
+		nil,
+		errors.New("plain"),
+	} {
+		if err := RecordErr(&buf, err); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := strings.Count(buf.String(), "\n"); n != 2 {
+		t.Fatalf("expected two records, got %d:\n%s", n, buf.String())
+	}
+
+	var replayed []error
+	for err := range ReplayErrs(&buf) {
+		replayed = append(replayed, err)
+	}
+	if len(replayed) != 2 {
+		t.Fatalf("expected two replayed errors, got %v", replayed)
+	}
+	if !errors.Is(replayed[0], ErrWireTest) {
+		t.Errorf("expected registered sentinel to survive replay: %v", replayed[0])
+	}
+	if user, _ := doterr.ErrValue[string](replayed[0], "user"); user != "alice" {
+		t.Errorf("expected metadata to survive replay, got %q", user)
+	}
+	if replayed[1].Error() != "plain" {
+		t.Errorf("unexpected second error %v", replayed[1])
+	}
+}
+
+func TestReplayErrs_StopsAtMalformedRecord(t *testing.T) {
+	in := "{\"kind\":\"leaf\",\"message\":\"ok\"}\n\n{not json\n{\"kind\":\"leaf\"}\n"
+	var replayed []error
+	for err := range ReplayErrs(strings.NewReader(in)) {
+		replayed = append(replayed, err)
+	}
+	if len(replayed) != 2 {
+		t.Fatalf("expected one error then a decode failure, got %v", replayed)
+	}
+	last := replayed[1]
+	if !errors.Is(last, ErrDecode) {
+		t.Errorf("expected ErrDecode, got %v", last)
+	}
+	if line, _ := doterr.ErrValue[int](last, "record"); line != 3 {
+		t.Errorf("expected record 3, got %d", line)
+	}
+}
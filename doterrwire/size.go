@@ -0,0 +1,256 @@
+package doterrwire
+
+import (
+	"encoding/base64"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"github.com/mikeschinkel/go-doterr"
+	"github.com/mikeschinkel/go-doterr/doterrfields"
+)
+
+// Encoding selects a wire format for EstimateSize.
+type Encoding int
+
+const (
+	JSON   Encoding = iota // ErrToJSON
+	Proto                  // ErrToProto
+	Header                 // EncodeHeader
+)
+
+// EstimateSize predicts the number of bytes err occupies when encoded with
+// enc, without building the encoding, so transports with hard limits (gRPC
+// metadata, Kafka headers, UDP syslog) can truncate or summarize first.
+// Proto, JSON and Header sizes are exact, except that the hop recorded under
+// SetServiceName is sized without reading the clock, at the longest its
+// JSON timestamp can be.
+// Returns 0 for a nil error or an unknown encoding.
+func EstimateSize(err error, enc Encoding) int {
+	if err == nil {
+		return 0
+	}
+	switch enc {
+	case JSON:
+		return jsonNodeSize(envelopeAt(err, sizingClock))
+	case Proto:
+		return protoNodeSize(envelopeAt(err, sizingClock))
+	case Header:
+		return headerSize(err)
+	}
+	return 0
+}
+
+// sizingClock stamps the hop EstimateSize sizes for this service, so no
+// clock is read: a time whose RFC3339Nano form has the full nine fractional
+// digits, the longest it can be, and whose UnixNano takes as many varint
+// bytes as any time from 1973 to 2262.
+func sizingClock() time.Time {
+	return time.Date(2006, 1, 2, 15, 4, 5, 999999999, time.UTC)
+}
+
+func protoNodeSize(n *Node) int {
+	size := 0
+	if n.Kind != KindLeaf {
+		size += 1 + uvarintSize(uint64(n.Kind))
+	}
+	size += protoStringSize(n.Message) + protoStringSize(n.Type)
+	for _, s := range n.Sentinels {
+		size += protoBytesSize(protoStringSize(s.Message) + protoStringSize(s.Code))
+	}
+	for _, f := range n.Meta {
+		size += protoBytesSize(protoFieldSize(f))
+	}
+	for _, child := range n.Children {
+		size += protoBytesSize(protoNodeSize(child))
+	}
+	for _, h := range n.Hops {
+		size += protoBytesSize(protoStringSize(h.Service) + 1 + uvarintSize(uint64(h.Time.UnixNano())))
+	}
+	return size
+}
+
+func protoFieldSize(f Field) int {
+	size := protoStringSize(f.Key)
+	if p, ok := f.Value.([]byte); ok && f.Compression != "" {
+		return size + protoBytesSize(len(p)) + protoStringSize(f.Compression)
+	}
+	switch v := NormalizeValue(f.Value).(type) {
+	case string:
+		size += protoBytesSize(len(v))
+	case int64:
+		size += 1 + uvarintSize(uint64(v<<1)^uint64(v>>63))
+	case float64:
+		size += 1 + 8
+	case bool:
+		size += 2
+	}
+	return size
+}
+
+// protoStringSize sizes an optional string field; empty strings are omitted.
+func protoStringSize(s string) int {
+	if s == "" {
+		return 0
+	}
+	return protoBytesSize(len(s))
+}
+
+// protoBytesSize sizes a length-delimited field with a one-byte tag.
+func protoBytesSize(n int) int {
+	return 1 + uvarintSize(uint64(n)) + n
+}
+
+func uvarintSize(v uint64) int {
+	size := 1
+	for v >= 0x80 {
+		v >>= 7
+		size++
+	}
+	return size
+}
+
+// jsonNodeSize mirrors encoding/json's output for Node, including omitempty.
+func jsonNodeSize(n *Node) int {
+	size := len(`{"kind":`) + jsonStringSize(n.Kind.String()) + 1 // closing brace
+	if n.Message != "" {
+		size += len(`,"message":`) + jsonStringSize(n.Message)
+	}
+	if n.Type != "" {
+		size += len(`,"type":`) + jsonStringSize(n.Type)
+	}
+	if len(n.Sentinels) > 0 {
+		size += len(`,"sentinels":[]`) + len(n.Sentinels) - 1
+		for _, s := range n.Sentinels {
+			size += len(`{"message":}`) + jsonStringSize(s.Message)
+			if s.Code != "" {
+				size += len(`,"code":`) + jsonStringSize(s.Code)
+			}
+		}
+	}
+	if len(n.Meta) > 0 {
+		size += len(`,"meta":[]`) + len(n.Meta) - 1
+		for _, f := range n.Meta {
+			size += len(`{"key":,"value":}`) + jsonStringSize(f.Key)
+			if p, ok := f.Value.([]byte); ok && f.Compression != "" {
+				size += 2 + base64.StdEncoding.EncodedLen(len(p))
+				size += len(`,"compression":`) + jsonStringSize(f.Compression)
+				continue
+			}
+			size += jsonValueSize(f.Value)
+		}
+	}
+	if len(n.Children) > 0 {
+		size += len(`,"children":[]`) + len(n.Children) - 1
+		for _, child := range n.Children {
+			size += jsonNodeSize(child)
+		}
+	}
+	if len(n.Hops) > 0 {
+		size += len(`,"hops":[]`) + len(n.Hops) - 1
+		for _, h := range n.Hops {
+			size += len(`{"service":,"time":""}`) + jsonStringSize(h.Service) + len(h.Time.Format(time.RFC3339Nano))
+		}
+	}
+	return size
+}
+
+func jsonValueSize(v any) int {
+	switch v := NormalizeValue(v).(type) {
+	case nil:
+		return len("null")
+	case bool:
+		if v {
+			return len("true")
+		}
+		return len("false")
+	case int64:
+		return len(strconv.FormatInt(v, 10))
+	case float64:
+		if math.IsInf(v, 0) || math.IsNaN(v) {
+			return len("null")
+		}
+		return jsonFloatSize(v)
+	case string:
+		return jsonStringSize(v)
+	}
+	return 0
+}
+
+// jsonFloatSize follows encoding/json's float formatting: 'f' notation except
+// for very small or very large magnitudes, with a one-digit exponent kept short.
+func jsonFloatSize(f float64) int {
+	abs := math.Abs(f)
+	if abs == 0 || (abs >= 1e-6 && abs < 1e21) {
+		return len(strconv.FormatFloat(f, 'f', -1, 64))
+	}
+	b := strconv.FormatFloat(f, 'e', -1, 64)
+	if n := len(b); n >= 4 && b[n-4] == 'e' && b[n-3] == '-' && b[n-2] == '0' {
+		return n - 1 // e-09 is written as e-9
+	}
+	return len(b)
+}
+
+// jsonStringSize mirrors encoding/json's string encoding: the quotes, the
+// two-byte escapes, the six-byte \u escapes of HTML characters and other
+// control characters, and a U+FFFD replacement for each invalid UTF-8 byte.
+func jsonStringSize(s string) int {
+	size := 2
+	for i := 0; i < len(s); {
+		if b := s[i]; b < utf8.RuneSelf {
+			switch {
+			case b == '"' || b == '\\' || b == '\n' || b == '\r' || b == '\t' || b == '\b' || b == '\f':
+				size += 2
+			case b < 0x20 || b == '<' || b == '>' || b == '&':
+				size += len(`\u0000`)
+			default:
+				size++
+			}
+			i++
+			continue
+		}
+		r, n := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case r == utf8.RuneError && n == 1:
+			size += len(string(utf8.RuneError))
+		case r == '\u2028' || r == '\u2029':
+			size += len(`\u2028`)
+		default:
+			size += n
+		}
+		i += n
+	}
+	return size
+}
+
+// headerSize mirrors EncodeHeader: the compact summary's JSON length expanded
+// by unpadded base64. Under SetHeaderCodec the codec has to run to be sized.
+func headerSize(err error) int {
+	if name, data, ok := encodeWithHeaderCodec(err); ok {
+		return len(name) + 1 + base64.RawURLEncoding.EncodedLen(len(data))
+	}
+	size := len(`{"m":}`) + jsonStringSize(err.Error())
+	sentinels := doterr.ErrSentinels(err)
+	if len(sentinels) > 0 {
+		size += len(`,"s":[]`) + len(sentinels) - 1
+		for _, s := range sentinels {
+			size += jsonStringSize(s.Error())
+		}
+	}
+	var meta []doterr.KV
+	for _, pair := range doterr.ErrMeta(err) {
+		if doterrfields.Emits("doterrwire", pair.Key()) {
+			meta = append(meta, pair)
+		}
+	}
+	if len(meta) > 0 {
+		size += len(`,"k":[]`) + len(meta) - 1
+		for _, pair := range meta {
+			v := doterr.EmitValue("doterrwire", pair.Key(), pair.Value())
+			size += len(`[,]`) + jsonStringSize(pair.Key()) + jsonStringSize(fmt.Sprintf("%v", v))
+		}
+	}
+	return (size*4 + 2) / 3
+}
@@ -0,0 +1,83 @@
+package doterrwire
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mikeschinkel/go-doterr"
+)
+
+// ErrTimeline renders the entries of err that carry a doterr.TimeKey (see
+// doterr.SetCaptureTime) in the order they were created, relative to the
+// earliest one:
+//
+//	t+0ms dial, t+1203ms timeout, t+1205ms request failed
+//
+// Entries are labelled with their op, if any, and sentinels; entries without
+// a timestamp and non-doterr errors are left out. Returns "" when nothing in
+// err has a timestamp.
+func ErrTimeline(err error) string {
+	var events []timelineEvent
+	collectTimeline(Encode(err), &events)
+	if len(events) == 0 {
+		return ""
+	}
+	// Causes are collected before the errors wrapping them, so equal
+	// timestamps keep cause-first order.
+	slices.SortStableFunc(events, func(a, b timelineEvent) int {
+		return a.at.Compare(b.at)
+	})
+	start := events[0].at
+	parts := make([]string, len(events))
+	for i, ev := range events {
+		parts[i] = fmt.Sprintf("t+%dms %s", ev.at.Sub(start).Milliseconds(), ev.label)
+	}
+	return strings.Join(parts, ", ")
+}
+
+type timelineEvent struct {
+	at    time.Time
+	label string
+}
+
+// collectTimeline appends the timestamped entries of n's subtree, children
+// first.
+func collectTimeline(n *Node, events *[]timelineEvent) {
+	if n == nil {
+		return
+	}
+	for _, child := range n.Children {
+		collectTimeline(child, events)
+	}
+	if n.Kind != KindEntry {
+		return
+	}
+	var at time.Time
+	var op string
+	for _, f := range n.Meta {
+		switch f.Key {
+		case doterr.TimeKey:
+			if t, ok := restoreValue(f.Key, f.Value).(time.Time); ok {
+				at = t
+			}
+		case doterr.OpKey:
+			op, _ = f.Value.(string)
+		}
+	}
+	if at.IsZero() {
+		return
+	}
+	labels := make([]string, 0, len(n.Sentinels)+1)
+	if op != "" {
+		labels = append(labels, op)
+	}
+	for _, s := range n.Sentinels {
+		labels = append(labels, s.Message)
+	}
+	if len(labels) == 0 {
+		labels = append(labels, "entry")
+	}
+	*events = append(*events, timelineEvent{at: at, label: strings.Join(labels, ": ")})
+}
@@ -0,0 +1,42 @@
+package doterrwire
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mikeschinkel/go-doterr"
+)
+
+var (
+	ErrDial    = errors.New("dial")
+	ErrTimeout = errors.New("timeout")
+)
+
+func TestErrTimeline(t *testing.T) {
+	t0 := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	dial := doterr.NewErr(ErrDial, doterr.TimeKey, t0)
+	timeout := doterr.NewErr(ErrTimeout, doterr.TimeKey, t0.Add(1203*time.Millisecond), dial)
+	err := doterr.WithErr(doterr.Op("request"), doterr.TimeKey, t0.Add(1205*time.Millisecond), timeout)
+
+	want := "t+0ms dial, t+1203ms timeout, t+1205ms request"
+	if got := ErrTimeline(err); got != want {
+		t.Errorf("ErrTimeline = %q, want %q", got, want)
+	}
+
+	data, jerr := ErrToJSON(err)
+	if jerr != nil {
+		t.Fatal(jerr)
+	}
+	decoded, jerr := ErrFromJSON(data)
+	if jerr != nil {
+		t.Fatal(jerr)
+	}
+	if got := ErrTimeline(decoded); got != want {
+		t.Errorf("ErrTimeline after JSON round trip = %q, want %q", got, want)
+	}
+
+	if got := ErrTimeline(doterr.NewErr(ErrDial)); got != "" {
+		t.Errorf("expected empty timeline without timestamps, got %q", got)
+	}
+}
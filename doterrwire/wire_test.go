@@ -0,0 +1,231 @@
+package doterrwire
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mikeschinkel/go-doterr"
+	"github.com/mikeschinkel/go-doterr/doterrfields"
+)
+
+var (
+	ErrWireTest = doterr.MustRegisterSentinel(errors.New("wire test"), "WIRE_TEST", "")
+	ErrLocal    = errors.New("local sentinel")
+)
+
+func sampleErr() error {
+	cause := fmt.Errorf("dial: %w", errors.New("refused"))
+	return doterr.NewErr(
+		ErrWireTest, ErrLocal,
+		doterr.SeverityWarn,
+		"user", "alice",
+		"attempt", 3,
+		"ratio", 0.5,
+		"ok", false,
+		cause,
+	)
+}
+
+func TestRoundTrip_JSONAndProto(t *testing.T) {
+	original := sampleErr()
+
+	data, err := ErrToJSON(original)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromJSON, err := ErrFromJSON(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromProto, err := ErrFromProto(ErrToProto(original))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, got := range map[string]error{"json": fromJSON, "proto": fromProto} {
+		if !errors.Is(got, ErrWireTest) {
+			t.Errorf("%s: registered sentinel not restored: %v", name, got)
+		}
+		if errors.Is(got, ErrLocal) {
+			t.Errorf("%s: unregistered sentinel should only match by message", name)
+		}
+		if got.Error() != original.Error() {
+			t.Errorf("%s: message\n got %q\nwant %q", name, got.Error(), original.Error())
+		}
+		if n, _ := doterr.ErrValue[int64](got, "attempt"); n != 3 {
+			t.Errorf("%s: attempt = %d", name, n)
+		}
+		if doterr.ErrSeverity(got) != doterr.SeverityWarn {
+			t.Errorf("%s: severity = %v", name, doterr.ErrSeverity(got))
+		}
+		if Encode(got).String() != Encode(original).String() {
+			t.Errorf("%s: tree\n got %s\nwant %s", name, Encode(got), Encode(original))
+		}
+	}
+}
+
+func TestRoundTrip_RetryAfter(t *testing.T) {
+	original := doterr.WithRetryAfter(doterr.NewErr(doterr.ErrRateLimited), 2*time.Second)
+	got, err := ErrFromProto(ErrToProto(original))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d, ok := doterr.RetryAfter(got); !ok || d != 2*time.Second {
+		t.Errorf("RetryAfter = %v, %v", d, ok)
+	}
+}
+
+func TestRoundTrip_Job(t *testing.T) {
+	original := doterr.WithJob(doterr.NewErr(ErrWireTest), "emails", "j-7", 2)
+	data, err := ErrToJSON(original)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromJSON, err := ErrFromJSON(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromProto, err := ErrFromProto(ErrToProto(original))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := doterr.Job{Queue: "emails", ID: "j-7", Attempt: 2}
+	for name, got := range map[string]error{"json": fromJSON, "proto": fromProto} {
+		if job, ok := doterr.ErrJob(got); !ok || job != want {
+			t.Errorf("%s: ErrJob = %+v, %v", name, job, ok)
+		}
+		if v, _ := doterr.ErrValue[int](got, doterr.JobAttemptKey); v != 2 {
+			t.Errorf("%s: attempt not restored as int", name)
+		}
+	}
+}
+
+func TestErrFromProto_Truncated(t *testing.T) {
+	data := ErrToProto(sampleErr())
+	_, err := ErrFromProto(data[:len(data)-3])
+	if !errors.Is(err, ErrDecode) {
+		t.Fatalf("expected ErrDecode, got %v", err)
+	}
+	if _, ok := doterr.ErrValue[int](err, "offset"); !ok {
+		t.Errorf("expected offset metadata on %v", err)
+	}
+}
+
+func TestHeader_RoundTrip(t *testing.T) {
+	got, err := DecodeHeader(EncodeHeader(sampleErr()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !errors.Is(got, ErrRemote) {
+		t.Errorf("expected ErrRemote, got %v", got)
+	}
+	if user, _ := doterr.ErrValue[string](got, "user"); user != "alice" {
+		t.Errorf("user = %q", user)
+	}
+}
+
+func TestEstimateSize_MatchesEncodings(t *testing.T) {
+	errs := []error{
+		sampleErr(),
+		errors.New(`quote " and \ backslash`),
+		doterr.NewErr(ErrLocal, "html", "<a href=\"x\">&</a>", "ctl", "\b\f\x01", errors.New("bad \xff utf8 \u2028")),
+		errors.Join(doterr.NewErr(ErrLocal, "n", -70000), errors.New("second")),
+		doterr.WithErr("only", "meta", "big", uint64(1)<<63),
+	}
+	for _, e := range errs {
+		data, err := ErrToJSON(e)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := EstimateSize(e, JSON), len(data); got != want {
+			t.Errorf("JSON size for %q: got %d, want %d", e, got, want)
+		}
+		if got, want := EstimateSize(e, Proto), len(ErrToProto(e)); got != want {
+			t.Errorf("Proto size for %q: got %d, want %d", e, got, want)
+		}
+		if got, want := EstimateSize(e, Header), len(EncodeHeader(e)); got != want {
+			t.Errorf("Header size for %q: got %d, want %d", e, got, want)
+		}
+	}
+	if EstimateSize(nil, JSON) != 0 {
+		t.Error("expected 0 for nil")
+	}
+}
+
+func TestFieldsPolicy_FiltersWireMeta(t *testing.T) {
+	prev := doterrfields.SetPolicy("doterrwire", doterrfields.Policy{Deny: []string{"user"}})
+	t.Cleanup(func() { doterrfields.SetPolicy("doterrwire", prev) })
+
+	original := sampleErr()
+	got, err := ErrFromProto(ErrToProto(original))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := doterr.ErrValue[string](got, "user"); ok {
+		t.Errorf("expected user to be filtered from %v", got)
+	}
+	if n, _ := doterr.ErrValue[int64](got, "attempt"); n != 3 {
+		t.Errorf("attempt = %d", n)
+	}
+	data, err := ErrToJSON(original)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := EstimateSize(original, JSON), len(data); got != want {
+		t.Errorf("JSON size: got %d, want %d", got, want)
+	}
+	if got, want := EstimateSize(original, Header), len(EncodeHeader(original)); got != want {
+		t.Errorf("Header size: got %d, want %d", got, want)
+	}
+}
+
+func TestEncodeHeader_Redacts(t *testing.T) {
+	t.Cleanup(doterr.MarkSensitive("wire_email"))
+	prev := doterr.SetRedactionMode(doterr.RedactOn)
+	t.Cleanup(func() { doterr.SetRedactionMode(prev) })
+
+	err := doterr.NewErr(ErrLocal, "wire_email", "a@example.com", "user", "alice")
+	v := EncodeHeader(err)
+	if got := EstimateSize(err, Header); got != len(v) {
+		t.Errorf("Header size: got %d, want %d", got, len(v))
+	}
+	decoded, decodeErr := DecodeHeader(v)
+	if decodeErr != nil {
+		t.Fatal(decodeErr)
+	}
+	if got, _ := doterr.ErrValue[string](decoded, "wire_email"); got != doterr.RedactedValue {
+		t.Errorf("expected the sensitive value redacted, got %q", got)
+	}
+	if got, _ := doterr.ErrValue[string](decoded, "user"); got != "alice" {
+		t.Errorf("expected other values kept, got %q", got)
+	}
+}
+
+func TestRoundTrip_NoLocalMetadata(t *testing.T) {
+	original := sampleErr()
+	data, err := ErrToJSON(original)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prev := doterr.SetEnricher(doterr.EnricherFunc(func(context.Context) []any { return []any{"region", "local"} }))
+	defer doterr.SetEnricher(prev)
+	doterr.SetCaptureCaller(true)
+	defer doterr.SetCaptureCaller(false)
+
+	decoded, err := ErrFromJSON(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k := range doterr.MetaStream(decoded) {
+		if k == "region" || k == doterr.CallerKey {
+			t.Errorf("expected no %q stamped on the decoded error: %v", k, decoded)
+		}
+	}
+	if got, want := fmt.Sprint(doterr.ErrMeta(decoded)), fmt.Sprint(doterr.ErrMeta(original)); got != want {
+		t.Errorf("expected the metadata as sent, got %s, want %s", got, want)
+	}
+}